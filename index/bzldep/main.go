@@ -0,0 +1,130 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer/cli"
+)
+
+// Creates an index defining mapping between header and the Bazel rule that defines it, based on the cc_library targets of Bazel modules.
+// Used to regenerate the builtin index embedded in gazelle_cc (language/cc/bzldep-index.json), but can be used for any set of modules.
+// Modules are passed as positional arguments in the form of <name>@<version>
+func main() {
+	registry := flag.String("registry", "", "URL of Bazel registry used to fetch modules, e.g. file:///path/to/bazel-central-registry. Uses Bazel defaults if empty")
+	flag.Parse()
+
+	deps, err := parseModuleDeps(flag.Args())
+	if err != nil {
+		log.Fatalf("Invalid list of modules: %v", err)
+	}
+	if len(deps) == 0 {
+		log.Fatalf("No modules to index, expected a list of <name>@<version> arguments")
+	}
+	outputFile := cli.ResolveOutputFile()
+
+	// Modules are fetched and queried in a temporary workspace, defining them as bazel_dep
+	workspace, err := os.MkdirTemp("", "bzldep-index")
+	if err != nil {
+		log.Fatalf("Failed to create temporary workspace: %v", err)
+	}
+	defer os.RemoveAll(workspace)
+	if err := writeWorkspace(workspace, deps, *registry); err != nil {
+		log.Fatalf("Failed to create temporary workspace: %v", err)
+	}
+
+	modules := []indexer.Module{}
+	for _, dep := range deps {
+		if *cli.Verbose {
+			log.Printf("Indexing module %v", dep)
+		}
+		// Prebuilt libraries, e.g. Windows import libraries (.lib) of DLLs, are defined using cc_import exposing headers the same way as cc_library
+		result, err := bazel.Query(workspace, fmt.Sprintf("kind('cc_library|cc_import', @%s//...)", dep.name))
+		if err != nil {
+			log.Printf("Bazel query failed for module %v, it would be skipped: %v", dep, err)
+			continue
+		}
+		modules = append(modules, indexer.NewModuleFromQuery(&result, dep.name, nil))
+	}
+
+	indexingResult, err := indexer.CreateHeaderIndexWithOptions(modules, indexer.IndexingOptions{
+		FollowTransitiveDeps: *cli.FollowDeps,
+		AmbiguityPolicy:      cli.ResolveAmbiguityPolicy(),
+		PreferReexports:      *cli.PreferReexports,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+	if err := cli.WriteIndex(indexingResult, modules, outputFile); err != nil {
+		log.Fatal(err)
+	}
+
+	if *cli.Verbose {
+		log.Println(indexingResult.String())
+	}
+}
+
+// Bazel module that should be indexed
+type moduleDep struct {
+	name    string
+	version string
+}
+
+func (dep moduleDep) String() string {
+	return dep.name + "@" + dep.version
+}
+
+// Parses list of modules defined in the form of <name>@<version>
+func parseModuleDeps(args []string) ([]moduleDep, error) {
+	deps := []moduleDep{}
+	for _, arg := range args {
+		name, version, found := strings.Cut(arg, "@")
+		if !found || name == "" || version == "" {
+			return nil, fmt.Errorf("expected <name>@<version>, got: %q", arg)
+		}
+		deps = append(deps, moduleDep{name: name, version: version})
+	}
+	return deps, nil
+}
+
+// Creates a minimal Bazel workspace depending on given modules, allowing to query their targets
+func writeWorkspace(dir string, deps []moduleDep, registry string) error {
+	var moduleFile strings.Builder
+	moduleFile.WriteString("module(name = \"bzldep_index\")\n\n")
+	for _, dep := range deps {
+		fmt.Fprintf(&moduleFile, "bazel_dep(name = %q, version = %q)\n", dep.name, dep.version)
+	}
+	files := map[string]string{
+		"MODULE.bazel": moduleFile.String(),
+		"BUILD.bazel":  "",
+	}
+	if registry != "" {
+		files[".bazelrc"] = fmt.Sprintf("common --registry=%s\n", registry)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel/proto"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/stretchr/testify/assert"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+func TestParseModuleDeps(t *testing.T) {
+	deps, err := parseModuleDeps([]string{"zlib@1.3.1", "abseil-cpp@20250127.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []moduleDep{{name: "zlib", version: "1.3.1"}, {name: "abseil-cpp", version: "20250127.1"}}, deps)
+
+	for _, invalid := range []string{"zlib", "zlib@", "@1.3.1"} {
+		_, err := parseModuleDeps([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestWriteWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	err := writeWorkspace(dir, []moduleDep{{name: "zlib", version: "1.3.1"}}, "file:///tmp/registry")
+	assert.NoError(t, err)
+
+	moduleFile, err := os.ReadFile(filepath.Join(dir, "MODULE.bazel"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(moduleFile), `bazel_dep(name = "zlib", version = "1.3.1")`)
+
+	bazelrc, err := os.ReadFile(filepath.Join(dir, ".bazelrc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "common --registry=file:///tmp/registry\n", string(bazelrc))
+}
+
+func TestGeneratedIndexIsLoadable(t *testing.T) {
+	attr := func(name string, values ...string) *proto.Attribute {
+		return &proto.Attribute{Name: protobuf.String(name), StringListValue: values}
+	}
+	query := proto.QueryResult{
+		Target: []*proto.Target{
+			{Rule: &proto.Rule{
+				Name:      protobuf.String("@@zlib+//:zlib"),
+				RuleClass: protobuf.String("cc_library"),
+				Attribute: []*proto.Attribute{
+					attr("hdrs", "@@zlib+//:zlib.h", "@@zlib+//:zconf.h"),
+					attr("includes", "."),
+				},
+			}},
+		},
+	}
+
+	module := indexer.NewModuleFromQuery(&query, "zlib", nil)
+	result := indexer.CreateHeaderIndex([]indexer.Module{module})
+	outputFile := filepath.Join(t.TempDir(), "bzldep-index.json")
+	assert.NoError(t, result.WriteToFile(outputFile))
+
+	data, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	var index map[string]string
+	assert.NoError(t, json.Unmarshal(data, &index))
+
+	expected := label.New("zlib", "", "zlib")
+	assert.Equal(t, map[string]string{"zlib.h": expected.String(), "zconf.h": expected.String()}, index)
+	for _, target := range index {
+		parsed, err := label.Parse(target)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, parsed)
+	}
+}
+
+func TestIndexWindowsImportLibrary(t *testing.T) {
+	attr := func(name string, values ...string) *proto.Attribute {
+		return &proto.Attribute{Name: protobuf.String(name), StringListValue: values}
+	}
+	stringAttr := func(name string, value string) *proto.Attribute {
+		return &proto.Attribute{Name: protobuf.String(name), StringValue: protobuf.String(value)}
+	}
+	query := proto.QueryResult{
+		Target: []*proto.Target{
+			{Rule: &proto.Rule{
+				Name:      protobuf.String("@@prebuilt+//:sdk"),
+				RuleClass: protobuf.String("cc_import"),
+				Attribute: []*proto.Attribute{
+					attr("hdrs", "@@prebuilt+//:include/sdk/api.h"),
+					attr("includes", "include"),
+					stringAttr("interface_library", "@@prebuilt+//:lib/sdk.lib"),
+					stringAttr("shared_library", "@@prebuilt+//:bin/sdk.dll"),
+				},
+			}},
+		},
+	}
+
+	module := indexer.NewModuleFromQuery(&query, "prebuilt", nil)
+	result := indexer.CreateHeaderIndex([]indexer.Module{module})
+
+	expected := label.New("prebuilt", "", "sdk")
+	assert.Equal(t, map[string]label.Label{"include/sdk/api.h": expected, "sdk/api.h": expected}, result.HeaderToRule)
+}
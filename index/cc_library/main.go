@@ -0,0 +1,82 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer/cli"
+)
+
+// Creates an index defining mapping between header and the Bazel rule that defines it, based on arbitrary cc_library targets,
+// e.g. hand-written rules of third-party libraries fetched using http_archive.
+// The created index can be used as input for gazelle_cc allowing to resolve external dependenices.
+func main() {
+	externalRepo := flag.String("external_repo", "", "Name of the external repository which cc_library targets should be indexed, e.g. zlib")
+	query := flag.String("query", "", "Bazel query selecting targets to index, defaults to all cc_library targets of --external_repo")
+	flag.Parse()
+
+	workdir, err := cli.ResolveWorkingDir()
+	if err != nil {
+		log.Fatalf("Failed to resolve working directory for indexer: %v", err)
+	}
+	outputFile := cli.ResolveOutputFile()
+
+	repoName := strings.TrimLeft(*externalRepo, "@")
+	queryExpr, err := resolveQuery(repoName, *query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *cli.Verbose {
+		log.Printf("Indexing targets matching %v", queryExpr)
+	}
+	result, err := bazel.Query(workdir, queryExpr)
+	if err != nil {
+		log.Fatalf("Bazel query failed, unable to index cc_library rules: %v", err)
+	}
+	modules := []indexer.Module{indexer.NewModuleFromQuery(&result, repoName, nil)}
+
+	indexingResult, err := indexer.CreateHeaderIndexWithOptions(modules, indexer.IndexingOptions{
+		FollowTransitiveDeps: *cli.FollowDeps,
+		AmbiguityPolicy:      cli.ResolveAmbiguityPolicy(),
+		PreferReexports:      *cli.PreferReexports,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+	if err := cli.WriteIndex(indexingResult, modules, outputFile); err != nil {
+		log.Fatal(err)
+	}
+
+	if *cli.Verbose {
+		log.Println(indexingResult.String())
+	}
+}
+
+// Selects the query used to find indexed targets, either explicitly provided or all cc_library targets of the external repository
+func resolveQuery(repoName string, query string) (string, error) {
+	if query != "" {
+		return query, nil
+	}
+	if repoName == "" {
+		return "", fmt.Errorf("either --external_repo or --query is required")
+	}
+	return fmt.Sprintf("kind(cc_library, @%s//...)", repoName), nil
+}
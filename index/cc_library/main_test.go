@@ -0,0 +1,81 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel/proto"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/stretchr/testify/assert"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+func TestResolveQuery(t *testing.T) {
+	query, err := resolveQuery("zlib", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "kind(cc_library, @zlib//...)", query)
+
+	query, err = resolveQuery("zlib", "kind(cc_library, @zlib//:all)")
+	assert.NoError(t, err)
+	assert.Equal(t, "kind(cc_library, @zlib//:all)", query)
+
+	_, err = resolveQuery("", "")
+	assert.Error(t, err)
+}
+
+func TestIndexHandWrittenLibraries(t *testing.T) {
+	attr := func(name string, values ...string) *proto.Attribute {
+		return &proto.Attribute{Name: protobuf.String(name), StringListValue: values}
+	}
+	stringAttr := func(name string, value string) *proto.Attribute {
+		return &proto.Attribute{Name: protobuf.String(name), StringValue: protobuf.String(value)}
+	}
+	query := proto.QueryResult{
+		Target: []*proto.Target{
+			{Rule: &proto.Rule{
+				Name:      protobuf.String("@@+_repo_rules+libpng//:png"),
+				RuleClass: protobuf.String("cc_library"),
+				Attribute: []*proto.Attribute{
+					attr("hdrs", "@@+_repo_rules+libpng//:src/png.h"),
+					stringAttr("strip_include_prefix", "src"),
+					stringAttr("include_prefix", "libpng"),
+					attr("deps", "@@+_repo_rules+libpng//:zlib"),
+				},
+			}},
+			{Rule: &proto.Rule{
+				Name:      protobuf.String("@@+_repo_rules+libpng//:zlib"),
+				RuleClass: protobuf.String("cc_library"),
+				Attribute: []*proto.Attribute{
+					attr("hdrs", "@@+_repo_rules+libpng//:zlib/zlib.h"),
+					attr("includes", "zlib"),
+				},
+			}},
+		},
+	}
+
+	module := indexer.NewModuleFromQuery(&query, "libpng", nil)
+	result := indexer.CreateHeaderIndex([]indexer.Module{module})
+
+	png := label.New("libpng", "", "png")
+	zlib := label.New("libpng", "", "zlib")
+	assert.Equal(t, map[string]label.Label{
+		"libpng/png.h": png,
+		"src/png.h":    png,
+		"zlib/zlib.h":  zlib,
+		"zlib.h":       zlib,
+	}, result.HeaderToRule)
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+)
+
+// Verifies consistency of index files, e.g. created by one of the indexers or maintained manually.
+// Reports hidden headers and labels that are malformed or refer to possibly internal packages.
+// Index files are passed as positional arguments, exits with non-zero code if any issue was found.
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatalf("No index files to check, expected a list of index file paths")
+	}
+
+	issuesFound := false
+	for _, indexFile := range flag.Args() {
+		// Relative paths are resolved against the workspace directory when executed using `bazel run`
+		if workspaceDir := os.Getenv("BUILD_WORKSPACE_DIRECTORY"); workspaceDir != "" && !filepath.IsAbs(indexFile) {
+			indexFile = filepath.Join(workspaceDir, indexFile)
+		}
+		issues, err := checkIndexFile(indexFile)
+		if err != nil {
+			log.Fatalf("Failed to check index %v: %v", indexFile, err)
+		}
+		for _, issue := range issues {
+			fmt.Printf("%v: %v\n", indexFile, issue)
+		}
+		issuesFound = issuesFound || len(issues) > 0
+	}
+	if issuesFound {
+		os.Exit(1)
+	}
+}
+
+// Loads the index file and returns the list of its issues
+func checkIndexFile(indexFile string) ([]string, error) {
+	file, err := indexer.LoadFromFile(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	return file.Check(), nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIndexFile(t *testing.T) {
+	for _, tc := range []struct {
+		clue     string
+		index    string
+		expected []string
+	}{
+		{
+			clue: "Clean legacy index",
+			index: `{
+  "zlib.h": "@zlib//:zlib",
+  "fmt/core.h": "@fmt//:fmt"
+}`,
+			expected: []string{},
+		},
+		{
+			clue: "Clean versioned index",
+			index: `{
+  "version": 1,
+  "mappings": {"zlib.h": "@zlib//:zlib"},
+  "ambiguous": {"common.h": ["@a//:a", "@b//lib:b"]}
+}`,
+			expected: []string{},
+		},
+		{
+			clue: "Dirty index",
+			index: `{
+  "version": 1,
+  "mappings": {
+    "zlib.h": "@zlib//:zlib",
+    ".hidden/config.h": "@config//:config",
+    "absl/base/log.h": "@abseil-cpp//absl/base/internal:raw_logging",
+    "lib/lib.h": "@lib//_private:lib",
+    "broken.h": "@broken//::broken"
+  },
+  "ambiguous": {
+    "common.h": ["@a//:a", "@b//src/impl:b"]
+  }
+}`,
+			expected: []string{
+				".hidden/config.h: header is blank or hidden",
+				`absl/base/log.h: label @abseil-cpp//absl/base/internal:raw_logging refers to possibly internal or hidden package`,
+				`broken.h: invalid label "@broken//::broken": label parse error: name has invalid characters: "@broken//::broken"`,
+				`common.h: label @b//src/impl:b refers to possibly internal or hidden package`,
+				`lib/lib.h: label @lib//_private:lib refers to possibly internal or hidden package`,
+			},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			indexFile := filepath.Join(t.TempDir(), "index.json")
+			assert.NoError(t, os.WriteFile(indexFile, []byte(tc.index), 0666))
+			issues, err := checkIndexFile(indexFile)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, issues)
+		})
+	}
+
+	_, err := checkIndexFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
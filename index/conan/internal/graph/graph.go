@@ -0,0 +1,155 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/collections"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+)
+
+type (
+	// Conan package required in the host context, exposed by the BazelDeps generator as an external repository
+	Package struct {
+		Name string
+		// Name of external Bazel repository defining rules of the package
+		Repository string
+		// Name of the cc_library target representing the whole package
+		TargetName string
+		Components []Component
+	}
+	// Component of Conan package, exposed by the BazelDeps generator as a dedicated cc_library target
+	Component struct {
+		Name       string
+		TargetName string
+		// Names of required components, either defined in the same package or using `<package>::<component>` form
+		Requires []string
+	}
+)
+
+// Subset of `conan graph info --format=json` output
+type graphInfo struct {
+	Graph struct {
+		Nodes map[string]struct {
+			Name    string                  `json:"name"`
+			Context string                  `json:"context"`
+			CppInfo map[string]cppInfoEntry `json:"cpp_info"`
+		} `json:"nodes"`
+	} `json:"graph"`
+}
+
+type cppInfoEntry struct {
+	Requires   []string       `json:"requires"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Key of cpp_info entry describing package-level information
+const rootCppInfo = "root"
+
+// Runs `conan graph info` in given directory and returns packages found in the dependency graph
+func Load(dir string) ([]Package, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("conan", "graph", "info", ".", "--format=json")
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("conan graph info failed: %w, output: %v", err, stderr.String())
+	}
+	return Parse(stdout.Bytes())
+}
+
+// Parses output of `conan graph info --format=json` into list of packages sorted by their name.
+// Consumer of the graph and packages required only in the build context, e.g. tool requirements, are skipped.
+// Names of repositories and targets follow the conventions of BazelDeps generator, unless overridden using `bazel_repository_name` and `bazel_target_name` properties.
+func Parse(data []byte) ([]Package, error) {
+	var info graphInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse conan graph info: %w", err)
+	}
+	packages := []Package{}
+	for _, node := range info.Graph.Nodes {
+		if node.Name == "" || node.Context != "host" {
+			continue
+		}
+		root := node.CppInfo[rootCppInfo]
+		pkg := Package{
+			Name:       node.Name,
+			Repository: stringProperty(root, "bazel_repository_name", node.Name),
+			TargetName: stringProperty(root, "bazel_target_name", node.Name),
+		}
+		for name, entry := range node.CppInfo {
+			if name == rootCppInfo {
+				continue
+			}
+			pkg.Components = append(pkg.Components, Component{
+				Name:       name,
+				TargetName: stringProperty(entry, "bazel_target_name", pkg.TargetName+"-"+name),
+				Requires:   entry.Requires,
+			})
+		}
+		slices.SortFunc(pkg.Components, func(a, b Component) int { return strings.Compare(a.Name, b.Name) })
+		packages = append(packages, pkg)
+	}
+	slices.SortFunc(packages, func(a, b Package) int { return strings.Compare(a.Name, b.Name) })
+	return packages, nil
+}
+
+func stringProperty(entry cppInfoEntry, name string, defaultValue string) string {
+	if value, ok := entry.Properties[name].(string); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Given set of targets defined in the package repository that define the same headers selects the one that should be indexed.
+// Prefers the target representing the whole package, otherwise selects the only component that is not required by other components in the group.
+// Returns false if the target cannot be determined based on the package structure.
+func (pkg Package) SelectTarget(targets collections.Set[*indexer.Target]) (*indexer.Target, bool) {
+	byName := make(map[string]*indexer.Target)
+	for target := range targets {
+		byName[target.Name.Name] = target
+	}
+	if target, ok := byName[pkg.TargetName]; ok {
+		return target, true
+	}
+
+	components := collections.Filter(pkg.Components, func(component Component) bool {
+		_, ok := byName[component.TargetName]
+		return ok
+	})
+	required := make(collections.Set[string])
+	for _, component := range components {
+		for _, req := range component.Requires {
+			if !strings.Contains(req, "::") {
+				req = pkg.Name + "::" + req
+			}
+			required.Add(req)
+		}
+	}
+	roots := collections.Filter(components, func(component Component) bool {
+		return !required.Contains(pkg.Name + "::" + component.Name)
+	})
+	if len(roots) != 1 {
+		return nil, false
+	}
+	return byName[roots[0].TargetName], true
+}
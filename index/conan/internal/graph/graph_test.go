@@ -0,0 +1,103 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"testing"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/collections"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/stretchr/testify/assert"
+)
+
+const graphInfoJSON = `{
+  "graph": {
+    "nodes": {
+      "0": {"ref": "conanfile", "name": null, "context": "host", "cpp_info": {"root": {}}},
+      "1": {
+        "ref": "openssl/3.2.0",
+        "name": "openssl",
+        "context": "host",
+        "cpp_info": {
+          "root": {"requires": []},
+          "crypto": {"requires": ["zlib::zlib"]},
+          "ssl": {"requires": ["crypto"]}
+        }
+      },
+      "2": {
+        "ref": "zlib/1.3.1",
+        "name": "zlib",
+        "context": "host",
+        "cpp_info": {
+          "root": {"properties": {"bazel_repository_name": "zlib_repo", "bazel_target_name": "z"}}
+        }
+      },
+      "3": {"ref": "cmake/3.28.1", "name": "cmake", "context": "build", "cpp_info": {"root": {}}}
+    }
+  }
+}`
+
+func TestParse(t *testing.T) {
+	packages, err := Parse([]byte(graphInfoJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, []Package{
+		{
+			Name:       "openssl",
+			Repository: "openssl",
+			TargetName: "openssl",
+			Components: []Component{
+				{Name: "crypto", TargetName: "openssl-crypto", Requires: []string{"zlib::zlib"}},
+				{Name: "ssl", TargetName: "openssl-ssl", Requires: []string{"crypto"}},
+			},
+		},
+		{
+			Name:       "zlib",
+			Repository: "zlib_repo",
+			TargetName: "z",
+		},
+	}, packages)
+
+	_, err = Parse([]byte("not a json"))
+	assert.Error(t, err)
+}
+
+func TestSelectTarget(t *testing.T) {
+	packages, err := Parse([]byte(graphInfoJSON))
+	assert.NoError(t, err)
+	openssl := packages[0]
+
+	target := func(name string) *indexer.Target {
+		return &indexer.Target{Name: label.New("openssl", "", name)}
+	}
+	root, crypto, ssl, other := target("openssl"), target("openssl-crypto"), target("openssl-ssl"), target("other")
+
+	for _, tc := range []struct {
+		clue     string
+		targets  collections.Set[*indexer.Target]
+		expected *indexer.Target
+	}{
+		{clue: "package target", targets: collections.SetOf(crypto, root, ssl), expected: root},
+		{clue: "component not required by others", targets: collections.SetOf(crypto, ssl), expected: ssl},
+		{clue: "single component", targets: collections.SetOf(crypto, other), expected: crypto},
+		{clue: "unknown targets", targets: collections.SetOf(other)},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			selected, ok := openssl.SelectTarget(tc.targets)
+			assert.Equal(t, tc.expected != nil, ok)
+			assert.Same(t, tc.expected, selected)
+		})
+	}
+}
@@ -69,28 +69,17 @@ func GroupTargetsByHeaders(module indexer.Module) []collections.Set[*indexer.Tar
 
 // Given set of targets that define the same headers try to select ones that contain other targets as their direct or transitive dependencies
 func SelectRootTargets(targets collections.Set[*indexer.Target]) []*indexer.Target {
-	allTargets := make(map[label.Label]*indexer.Target)
 	dependentTargets := make(collections.Set[label.Label])
 
-	// Collect all target names
-	for target := range targets {
-		allTargets[target.Name] = target
-	}
-
 	// Mark all targets that are listed as dependencies
-	for target := range targets {
-		for dep := range target.Deps {
-			dependentTargets.Add(dep)
-		}
-	}
+	targets.ForEach(func(target *indexer.Target) {
+		dependentTargets.Join(target.Deps)
+	})
 
 	// Any target not in the dependency map is a root
-	roots := make(collections.Set[*indexer.Target])
-	for name, target := range allTargets {
-		if !dependentTargets.Contains(name) {
-			roots.Add(target)
-		}
-	}
+	roots := targets.Filter(func(target *indexer.Target) bool {
+		return !dependentTargets.Contains(target.Name)
+	})
 
 	return roots.Values()
 }
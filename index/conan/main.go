@@ -16,13 +16,17 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/EngFlow/gazelle_cc/index/conan/internal/graph"
 	"github.com/EngFlow/gazelle_cc/index/conan/internal/targets"
 	"github.com/EngFlow/gazelle_cc/index/internal/bazel"
 	"github.com/EngFlow/gazelle_cc/index/internal/bazel/proto"
@@ -96,28 +100,56 @@ func main() {
 		log.Fatalf("Failed to list subdirectories in %s: %v", conanDirectory, err)
 	}
 
-	modules := []indexer.Module{}
-	for _, dir := range subdirs {
-		repoName := dir
-		// Search for cc_library in external repository
-		result, err := bazel.Query(callerRoot, fmt.Sprintf("kind(cc_library, @%s//...)", repoName))
-		if err != nil {
-			fmt.Errorf("Bazel query failed: %w", err)
+	// Conan dependency graph describes structure of packages and their components, allowing to select targets based on the components exposed by them.
+	// If it's not available, fallback to indexing all external repositories found in conan directory.
+	packages, err := graph.Load(callerRoot)
+	if err != nil {
+		log.Printf("Failed to read conan dependency graph, falling back to indexing all repositories in %v: %v", conanDirectory, err)
+		packages = collections.Map(subdirs, func(dir string) graph.Package {
+			return graph.Package{Name: dir, Repository: dir}
+		})
+	} else {
+		packages = collections.Filter(packages, func(pkg graph.Package) bool {
+			return slices.Contains(subdirs, pkg.Repository)
+		})
+	}
+
+	// Search for cc_library and cc_import, used for prebuilt libraries, in all external repositories at once.
+	// Each query needs to analyze the whole graph, so it's significantly faster than querying each repository separately.
+	var result proto.QueryResult
+	if len(packages) > 0 {
+		repositories := collections.Map(packages, func(pkg graph.Package) string { return fmt.Sprintf("@%s//...", pkg.Repository) })
+		result, err = bazel.ConfiguredQuery(callerRoot,
+			fmt.Sprintf("kind('cc_library|cc_import', %s)", strings.Join(repositories, " + ")),
+			bazel.QueryConfig{KeepGoing: true})
+		if errors.Is(err, bazel.ErrBazelNotFound) {
+			log.Fatalf("Unable to index conan repositories: %v", err)
+		} else if err != nil {
+			log.Printf("Bazel query failed, indexing only successfully queried repositories: %v", err)
 		}
-		module := extractIndexerModule(result, repoName)
+	}
+
+	modules := []indexer.Module{}
+	for _, pkg := range packages {
+		module := indexer.NewModuleFromQuery(&result, pkg.Repository, func(name label.Label) bool {
+			return isDefinedInRepository(name, pkg.Repository)
+		})
 
 		// If multiple rules refer to the same headers (typicall in Conan integration) then
 		// pick to targets that are on top of dependency chain - does not depend on other rules in group
 		selectedTargets := []*indexer.Target{}
 		// In conan most of cc_libraries defines filegroup using **/* glob pattern.
-		// We need to index only top-level target that depend on all other remaining targets
+		// We need to index only the target representing package or its top-level component, or if unknown a target that depend on all other remaining targets
 		for _, intersectingTargets := range targets.GroupTargetsByHeaders(module) {
-			roots := targets.SelectRootTargets(intersectingTargets)
-			if len(roots) != 1 {
-				log.Fatal("Incosistient state, should be only 1 root header")
+			root, ok := pkg.SelectTarget(intersectingTargets)
+			if !ok {
+				roots := targets.SelectRootTargets(intersectingTargets)
+				if len(roots) != 1 {
+					log.Fatal("Incosistient state, should be only 1 root header")
+				}
+				root = roots[0]
 			}
 			// Typically there should be exacly 1 root, but just for sanity let's merge other ones if needed
-			root := roots[0]
 			for target := range intersectingTargets {
 				if target != root {
 					root.Hdrs.Join(target.Hdrs)
@@ -130,49 +162,27 @@ func main() {
 		modules = append(modules, module)
 	}
 
-	indexingResult := indexer.CreateHeaderIndex(modules)
-	indexingResult.WriteToFile(outputFile)
+	indexingResult, err := indexer.CreateHeaderIndexWithOptions(modules, indexer.IndexingOptions{
+		FollowTransitiveDeps: *cli.FollowDeps,
+		AmbiguityPolicy:      cli.ResolveAmbiguityPolicy(),
+		PreferReexports:      *cli.PreferReexports,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+	cli.WriteIndex(indexingResult, modules, outputFile)
 
 	if *cli.Verbose {
 		log.Println(indexingResult.String())
 	}
 }
 
-// Processes bazel query result to extrct cc_library targets as a module
-func extractIndexerModule(query proto.QueryResult, moduleName string) indexer.Module {
-	targets := []*indexer.Target{}
-	for _, info := range query.GetTarget() {
-		name, err := label.Parse(info.GetRule().GetName())
-		if err != nil {
-			log.Printf("Failed to parse queried target label: %v", info.GetRule().GetName())
-			continue
-		}
-
-		tryParseLabel := func(labelString string) (label.Label, bool) {
-			if label, err := label.Parse(labelString); err == nil {
-				return label, true
-			}
-			return label.NoLabel, false
-		}
-
-		target := &indexer.Target{
-			Name: name,
-			Hdrs: collections.ToSet(collections.FilterMap(
-				bazel.GetNamedAttribute(info, "hdrs").GetStringListValue(),
-				tryParseLabel)),
-			Includes:           collections.ToSet(bazel.GetNamedAttribute(info, "includes").GetStringListValue()),
-			StripIncludePrefix: bazel.GetNamedAttribute(info, "strip_include_prefix").GetStringValue(),
-			IncludePrefix:      bazel.GetNamedAttribute(info, "include_prefix").GetStringValue(),
-			Deps: collections.ToSet(collections.FilterMap(
-				bazel.GetNamedAttribute(info, "deps").GetStringListValue(),
-				tryParseLabel)),
-		}
-		targets = append(targets, target)
-	}
-	return indexer.Module{
-		Repository: moduleName,
-		Targets:    targets,
-	}
+// Checks if target is defined in repository with given apparent name.
+// Queried labels might use canonical names of repositories created by module extensions, e.g. `+conan_extension+fmt` or `_main~conan_extension~fmt`
+func isDefinedInRepository(target label.Label, repository string) bool {
+	return target.Repo == repository ||
+		strings.HasSuffix(target.Repo, "+"+repository) ||
+		strings.HasSuffix(target.Repo, "~"+repository)
 }
 
 func listSubdirectories(root string) ([]string, error) {
@@ -0,0 +1,65 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel/proto"
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/stretchr/testify/assert"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+func TestModulesPartitionedByRepository(t *testing.T) {
+	target := func(name string, hdrs ...string) *proto.Target {
+		return &proto.Target{Rule: &proto.Rule{
+			Name:      protobuf.String(name),
+			RuleClass: protobuf.String("cc_library"),
+			Attribute: []*proto.Attribute{{Name: protobuf.String("hdrs"), StringListValue: hdrs}},
+		}}
+	}
+	query := proto.QueryResult{
+		Target: []*proto.Target{
+			target("@@+conan_extension+fmt//:fmt", "@@+conan_extension+fmt//:include/fmt/core.h"),
+			target("@@_main~conan_extension~zlib//:zlib", "@@_main~conan_extension~zlib//:include/zlib.h"),
+			target("@libiconv//:libiconv", "@libiconv//:include/iconv.h"),
+			target("@@+conan_extension+libfmt//:libfmt", "@@+conan_extension+libfmt//:include/libfmt.h"),
+		},
+	}
+
+	for _, tc := range []struct {
+		repository string
+		expected   []label.Label
+	}{
+		{repository: "fmt", expected: []label.Label{label.New("+conan_extension+fmt", "", "fmt")}},
+		{repository: "zlib", expected: []label.Label{label.New("_main~conan_extension~zlib", "", "zlib")}},
+		{repository: "libiconv", expected: []label.Label{label.New("libiconv", "", "libiconv")}},
+		{repository: "openssl", expected: []label.Label{}},
+	} {
+		t.Run(tc.repository, func(t *testing.T) {
+			module := indexer.NewModuleFromQuery(&query, tc.repository, func(name label.Label) bool {
+				return isDefinedInRepository(name, tc.repository)
+			})
+			assert.Equal(t, tc.repository, module.Repository)
+			names := []label.Label{}
+			for _, target := range module.Targets {
+				names = append(names, label.New(target.Name.Repo, target.Name.Pkg, target.Name.Name))
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
@@ -18,8 +18,11 @@ package bazel
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os/exec"
 	"slices"
+	"sync"
 
 	"github.com/EngFlow/gazelle_cc/index/internal/bazel/proto"
 	protobuf "google.golang.org/protobuf/proto"
@@ -35,7 +38,25 @@ type QueryConfig struct {
 	KeepGoing bool
 }
 
-// Execute given bazel query inside directory. Returns nil if query fails
+// Returned when bazel executable cannot be found, no query can be executed
+var ErrBazelNotFound = errors.New("bazel executable not found")
+
+// Returned when bazel query fails, contains the output of bazel explaining the failure
+type QueryError struct {
+	Query  string
+	Stderr string
+	Err    error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("bazel query %q failed: %v\n%s", e.Query, e.Err, e.Stderr)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Execute given bazel query inside directory. Returns ErrBazelNotFound if bazel is not available or QueryError if query fails
 func ConfiguredQuery(cwd string, query string, opts QueryConfig) (proto.QueryResult, error) {
 	var bufStdout bytes.Buffer
 	var bufStderr bytes.Buffer
@@ -51,8 +72,11 @@ func ConfiguredQuery(cwd string, query string, opts QueryConfig) (proto.QueryRes
 	cmd.Stdout = &bufStdout
 	cmd.Stderr = &bufStderr
 	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return proto.QueryResult{}, fmt.Errorf("%w: %w", ErrBazelNotFound, err)
+		}
 		if cmd.ProcessState.ExitCode() != 3 && !opts.KeepGoing {
-			return proto.QueryResult{}, err
+			return proto.QueryResult{}, &QueryError{Query: query, Stderr: bufStderr.String(), Err: err}
 		}
 	}
 
@@ -63,6 +87,59 @@ func ConfiguredQuery(cwd string, query string, opts QueryConfig) (proto.QueryRes
 	return result, nil
 }
 
+// Memoizes results of successful queries for the lifetime of the cache, typically a single indexing run.
+// Allows to avoid re-executing the same queries when targets are queried multiple times.
+// Results are shared by all callers executing the same query, these should not be modified.
+type QueryCache struct {
+	mu      sync.Mutex
+	results map[queryCacheKey]*proto.QueryResult
+	// Function used to execute queries that are not cached yet
+	execute func(cwd string, query string, opts QueryConfig) (*proto.QueryResult, error)
+}
+
+type queryCacheKey struct {
+	cwd   string
+	query string
+	opts  QueryConfig
+}
+
+func NewQueryCache() *QueryCache {
+	return &QueryCache{
+		results: make(map[queryCacheKey]*proto.QueryResult),
+		execute: func(cwd string, query string, opts QueryConfig) (*proto.QueryResult, error) {
+			result, err := ConfiguredQuery(cwd, query, opts)
+			if err != nil {
+				return nil, err
+			}
+			return &result, nil
+		},
+	}
+}
+
+// Execute given bazel query inside directory, unless it was already executed using the same configuration
+func (c *QueryCache) Query(cwd string, query string) (*proto.QueryResult, error) {
+	return c.ConfiguredQuery(cwd, query, QueryConfig{
+		KeepGoing: false,
+	})
+}
+
+// Execute given bazel query inside directory, unless it was already executed using the same configuration.
+// Failed queries are not cached.
+func (c *QueryCache) ConfiguredQuery(cwd string, query string, opts QueryConfig) (*proto.QueryResult, error) {
+	key := queryCacheKey{cwd: cwd, query: query, opts: opts}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if result, ok := c.results[key]; ok {
+		return result, nil
+	}
+	result, err := c.execute(cwd, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.results[key] = result
+	return result, nil
+}
+
 // Select attribute that defined with given name. Returns nil if no such attribute can be found
 func GetNamedAttribute(target *proto.Target, name string) *proto.Attribute {
 	attrs := target.GetRule().GetAttribute()
@@ -0,0 +1,85 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel/proto"
+	"github.com/stretchr/testify/assert"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// Replaces bazel executable with a script
+func fakeBazel(t *testing.T, script string) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bazel"), []byte("#!/bin/sh\n"+script), 0o755))
+	t.Setenv("PATH", dir)
+}
+
+func TestQueryErrors(t *testing.T) {
+	t.Run("bazel not found", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		_, err := Query(t.TempDir(), "//...")
+		assert.ErrorIs(t, err, ErrBazelNotFound)
+	})
+
+	t.Run("query failed", func(t *testing.T) {
+		fakeBazel(t, "echo 'ERROR: no such package' >&2; exit 7")
+		_, err := Query(t.TempDir(), "//broken/...")
+		var queryErr *QueryError
+		assert.True(t, errors.As(err, &queryErr))
+		assert.Equal(t, "//broken/...", queryErr.Query)
+		assert.Equal(t, "ERROR: no such package\n", queryErr.Stderr)
+		assert.ErrorContains(t, err, `bazel query "//broken/..." failed: exit status 7`)
+		assert.NotErrorIs(t, err, ErrBazelNotFound)
+	})
+}
+
+func TestQueryCache(t *testing.T) {
+	executed := []string{}
+	cache := NewQueryCache()
+	cache.execute = func(cwd string, query string, opts QueryConfig) (*proto.QueryResult, error) {
+		executed = append(executed, query)
+		if query == "//broken/..." {
+			return nil, errors.New("query failed")
+		}
+		return &proto.QueryResult{Target: []*proto.Target{{Rule: &proto.Rule{Name: protobuf.String(query)}}}}, nil
+	}
+
+	for range 2 {
+		result, err := cache.Query("/workspace", "//lib:lib")
+		assert.NoError(t, err)
+		assert.Equal(t, "//lib:lib", result.GetTarget()[0].GetRule().GetName())
+	}
+	assert.Equal(t, []string{"//lib:lib"}, executed)
+
+	// Queries executed in other directory or with other options are not shared
+	_, err := cache.Query("/other", "//lib:lib")
+	assert.NoError(t, err)
+	_, err = cache.ConfiguredQuery("/workspace", "//lib:lib", QueryConfig{KeepGoing: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"//lib:lib", "//lib:lib", "//lib:lib"}, executed)
+
+	// Failures are not cached
+	for range 2 {
+		_, err := cache.Query("/workspace", "//broken/...")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, []string{"//lib:lib", "//lib:lib", "//lib:lib", "//broken/...", "//broken/..."}, executed)
+}
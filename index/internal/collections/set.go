@@ -15,6 +15,7 @@
 package collections
 
 import (
+	"iter"
 	"maps"
 	"slices"
 )
@@ -47,7 +48,8 @@ func ToSet[T comparable](slice []T) Set[T] {
 	return set
 }
 
-// Diff returns a new Set containing elements that are defined in current Set but not in the other set.
+// Diff returns a new Set containing elements that are defined in current Set but not in the other set,
+// the standard set difference `s \ other`. Elements defined only in the other set are never included.
 //
 // Example:
 //
@@ -65,6 +67,23 @@ func (s Set[T]) Diff(other Set[T]) Set[T] {
 	return diff
 }
 
+// SymmetricDiff returns a new Set containing elements that are defined in exactly one of the Sets.
+//
+// Example:
+//
+//	a := SetOf(1, 2, 3)
+//	b := SetOf(2, 3, 4)
+//	a.SymmetricDiff(b) => Set[int]{1, 4}
+func (s Set[T]) SymmetricDiff(other Set[T]) Set[T] {
+	diff := s.Diff(other)
+	for elem := range other {
+		if _, exists := s[elem]; !exists {
+			diff.Add(elem)
+		}
+	}
+	return diff
+}
+
 // Add inserts an element into the Set.
 // Returns the Set to allow chaining.
 //
@@ -145,3 +164,59 @@ func (s Set[T]) Intersects(other Set[T]) bool {
 func (s Set[T]) Values() []T {
 	return slices.Collect(maps.Keys(s))
 }
+
+// All returns an iterator over all elements in the Set, without allocating intermediate slice.
+// The order is not guaranteed.
+//
+// Example:
+//
+//	s := SetOf("a", "b")
+//	for elem := range s.All() { ... }
+func (s Set[T]) All() iter.Seq[T] {
+	return maps.Keys(s)
+}
+
+// ForEach calls `fn` for each element of the Set.
+// The order is not guaranteed.
+//
+// Example:
+//
+//	s := SetOf(1, 2)
+//	s.ForEach(func(x int) { fmt.Println(x) })
+func (s Set[T]) ForEach(fn func(T)) {
+	for elem := range s {
+		fn(elem)
+	}
+}
+
+// Filter returns a new Set containing only the elements for which the `predicate` function returns true.
+//
+// Example:
+//
+//	s := SetOf(1, 2, 3, 4)
+//	s.Filter(func(x int) bool { return x%2 == 0 }) => Set[int]{2, 4}
+func (s Set[T]) Filter(predicate func(T) bool) Set[T] {
+	result := make(Set[T])
+	for elem := range s {
+		if predicate(elem) {
+			result.Add(elem)
+		}
+	}
+	return result
+}
+
+// MapSet applies the transformation function `fn` to each element of the Set and returns a new Set of the resulting values.
+// Elements transformed into the same value are collapsed, so the result might be smaller than the input.
+// It is a package-level function because Go methods cannot introduce additional type parameters.
+//
+// Example:
+//
+//	s := SetOf(1, 2, 3)
+//	MapSet(s, func(x int) bool { return x%2 == 0 }) => Set[bool]{false, true}
+func MapSet[T, V comparable](s Set[T], fn func(T) V) Set[V] {
+	result := make(Set[V], len(s))
+	for elem := range s {
+		result.Add(fn(elem))
+	}
+	return result
+}
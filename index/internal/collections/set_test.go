@@ -15,6 +15,7 @@
 package collections
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -187,6 +188,18 @@ func TestSet_Diff(t *testing.T) {
 			set2:     SetOf(1, 3),
 			expected: SetOf(2),
 		},
+		{
+			name:     "elements only in other set are excluded",
+			set1:     SetOf(1),
+			set2:     SetOf(1, 2, 3),
+			expected: SetOf[int](),
+		},
+		{
+			name:     "disjoint sets",
+			set1:     SetOf(1, 2),
+			set2:     SetOf(3, 4),
+			expected: SetOf(1, 2),
+		},
 	}
 
 	for _, tt := range tests {
@@ -197,6 +210,48 @@ func TestSet_Diff(t *testing.T) {
 	}
 }
 
+func TestSet_SymmetricDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		set1     Set[int]
+		set2     Set[int]
+		expected Set[int]
+	}{
+		{
+			name:     "empty sets",
+			set1:     SetOf[int](),
+			set2:     SetOf[int](),
+			expected: SetOf[int](),
+		},
+		{
+			name:     "equal sets",
+			set1:     SetOf(1, 2),
+			set2:     SetOf(1, 2),
+			expected: SetOf[int](),
+		},
+		{
+			name:     "overlapping sets",
+			set1:     SetOf(1, 2, 3),
+			set2:     SetOf(2, 3, 4),
+			expected: SetOf(1, 4),
+		},
+		{
+			name:     "disjoint sets",
+			set1:     SetOf(1),
+			set2:     SetOf(2),
+			expected: SetOf(1, 2),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.set1.SymmetricDiff(tt.set2))
+			// The operation is commutative
+			assert.Equal(t, tt.expected, tt.set2.SymmetricDiff(tt.set1))
+		})
+	}
+}
+
 func TestSet_Join(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -333,3 +388,155 @@ func TestSet_Values(t *testing.T) {
 		})
 	}
 }
+
+func TestSet_All(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      Set[int]
+		expected []int
+	}{
+		{
+			name:     "empty set",
+			set:      SetOf[int](),
+			expected: []int{},
+		},
+		{
+			name:     "single element",
+			set:      SetOf(1),
+			expected: []int{1},
+		},
+		{
+			name:     "multiple elements",
+			set:      SetOf(1, 2, 3),
+			expected: []int{1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := []int{}
+			for elem := range tt.set.All() {
+				result = append(result, elem)
+			}
+			// The order is not guaranteed
+			assert.ElementsMatch(t, tt.expected, result)
+		})
+	}
+
+	t.Run("stops iteration early", func(t *testing.T) {
+		visited := 0
+		for range SetOf(1, 2, 3).All() {
+			visited++
+			break
+		}
+		assert.Equal(t, 1, visited)
+	})
+}
+
+func TestSet_ForEach(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      Set[int]
+		expected []int
+	}{
+		{
+			name:     "empty set",
+			set:      SetOf[int](),
+			expected: []int{},
+		},
+		{
+			name:     "multiple elements",
+			set:      SetOf(1, 2, 3),
+			expected: []int{1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := []int{}
+			tt.set.ForEach(func(elem int) {
+				result = append(result, elem)
+			})
+			// The order is not guaranteed
+			assert.ElementsMatch(t, tt.expected, result)
+		})
+	}
+}
+
+func TestSet_Filter(t *testing.T) {
+	isEven := func(x int) bool { return x%2 == 0 }
+	tests := []struct {
+		name     string
+		set      Set[int]
+		expected Set[int]
+	}{
+		{
+			name:     "empty set",
+			set:      SetOf[int](),
+			expected: SetOf[int](),
+		},
+		{
+			name:     "no matching elements",
+			set:      SetOf(1, 3),
+			expected: SetOf[int](),
+		},
+		{
+			name:     "some matching elements",
+			set:      SetOf(1, 2, 3, 4),
+			expected: SetOf(2, 4),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.set.Filter(isEven)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	t.Run("does not modify original set", func(t *testing.T) {
+		set := SetOf(1, 2)
+		set.Filter(isEven)
+		assert.Equal(t, SetOf(1, 2), set)
+	})
+}
+
+func TestMapSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      Set[int]
+		fn       func(int) string
+		expected Set[string]
+	}{
+		{
+			name:     "empty set",
+			set:      SetOf[int](),
+			fn:       func(x int) string { return fmt.Sprint(x) },
+			expected: SetOf[string](),
+		},
+		{
+			name:     "distinct results",
+			set:      SetOf(1, 2, 3),
+			fn:       func(x int) string { return fmt.Sprint(x) },
+			expected: SetOf("1", "2", "3"),
+		},
+		{
+			name: "colliding results",
+			set:  SetOf(1, 2, 3, 4),
+			fn: func(x int) string {
+				if x%2 == 0 {
+					return "even"
+				}
+				return "odd"
+			},
+			expected: SetOf("even", "odd"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MapSet(tt.set, tt.fn)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -17,16 +17,25 @@ package cli
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
 )
 
 // Common flags available in all indexers, added as sideeffect of importing package
 var (
-	Verbose       = flag.Bool("verbose", false, "Enable verbose logging")
-	output        = flag.String("output", "output.ccidx", "Output file path for index")
-	repositoryDir = flag.String("repository", "", "Explicit path to bazel repository, if ommited BUILD_WORKSPACE_DIRECTORY env variable or current working directory is used")
+	Verbose         = flag.Bool("verbose", false, "Enable verbose logging")
+	output          = flag.String("output", "output.ccidx", "Output file path for index")
+	repositoryDir   = flag.String("repository", "", "Explicit path to bazel repository, if ommited BUILD_WORKSPACE_DIRECTORY env variable or current working directory is used")
+	ambiguous       = flag.String("ambiguous", "", "Policy used to assign headers defined in multiple rules: shortest_label, repository_root or fail. If ommited such headers are not indexed")
+	FollowDeps      = flag.Bool("follow_deps", false, "Should root targets be indexed also by headers of their transitive dependencies within the same repository")
+	PreferReexports = flag.Bool("prefer_reexports", false, "Assign headers defined in multiple rules to the re-exporting rule nearest to the rule owning the header. Equally near re-exporters are treated as ambiguous. Applied before --ambiguous policy")
+	versioned       = flag.Bool("versioned", false, "Write the index in versioned format, additionally containing ambiguous headers and dependencies of indexed rules")
+	dryRun          = flag.Bool("dry_run", false, "Print the index and a summary of indexed modules to stdout instead of writing the output file")
 )
 
 // Resolve working directory for indexer, uses either explicit --repository path, BUILD_WORKSPACE_DIRECTORY env variable or current working directory
@@ -51,13 +60,64 @@ func ResolveWorkingDir() (string, error) {
 	return dir, nil
 }
 
+// Resolve policy used to assign ambiguous headers based on --ambiguous flag, exits if the value is not recognized
+func ResolveAmbiguityPolicy() indexer.AmbiguityPolicy {
+	if !flag.Parsed() {
+		log.Panicln("Flags not parsed yet")
+	}
+	policy := indexer.AmbiguityPolicy(*ambiguous)
+	if !slices.Contains(indexer.AmbiguityPolicies, policy) {
+		log.Fatalf("Invalid value of --ambiguous flag: %q, expected one of %v", *ambiguous, indexer.AmbiguityPolicies[1:])
+	}
+	return policy
+}
+
+// Writes the index to the output file, using versioned format if requested using --versioned flag.
+// When --dry_run is set the index and a summary of indexed modules are printed to stdout instead
+func WriteIndex(result indexer.IndexingResult, modules []indexer.Module, outputFile string) error {
+	if !flag.Parsed() {
+		log.Panicln("Flags not parsed yet")
+	}
+	if *dryRun {
+		fmt.Print(result.String())
+		fmt.Print(dryRunSummary(modules))
+		return nil
+	}
+	if *versioned {
+		return result.WriteToFileV2(outputFile)
+	}
+	return result.WriteToFile(outputFile)
+}
+
+// Describes modules and targets that would be indexed, printed when --dry_run is set
+func dryRunSummary(modules []indexer.Module) string {
+	targets := 0
+	for _, module := range modules {
+		targets += len(module.Targets)
+	}
+	summary := fmt.Sprintf("Discovered %d modules with %d targets\n", len(modules), targets)
+	for _, module := range modules {
+		name := "@" + module.Repository
+		if module.Repository == "" {
+			name = "main repository"
+		}
+		summary += fmt.Sprintf("%-40s: %d targets\n", name, len(module.Targets))
+	}
+	return summary
+}
+
+// Resolve path to the output file based on --output flag, relative paths are resolved against the working directory of the indexer.
+// Returns an empty path when --dry_run is set, the output file is never written then
 func ResolveOutputFile() string {
 	if !flag.Parsed() {
 		log.Panicln("Flags not parsed yet")
 	}
+	if *dryRun {
+		return ""
+	}
 	outputFile := *output
 	if !filepath.IsAbs(outputFile) {
-		if workdir, err := ResolveWorkingDir(); err != nil {
+		if workdir, err := ResolveWorkingDir(); err == nil {
 			outputFile = filepath.Join(workdir, outputFile)
 		}
 	}
@@ -0,0 +1,70 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/EngFlow/gazelle_cc/index/internal/indexer"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/stretchr/testify/assert"
+)
+
+// Sets the value of the flag for the duration of the test
+func setFlag[T any](t *testing.T, flag *T, value T) {
+	previous := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = previous })
+}
+
+func TestResolveOutputFileDryRun(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "output.ccidx")
+	setFlag(t, output, outputFile)
+
+	setFlag(t, dryRun, false)
+	assert.Equal(t, outputFile, ResolveOutputFile())
+
+	// Output file is never written in dry-run mode
+	setFlag(t, dryRun, true)
+	assert.Empty(t, ResolveOutputFile())
+}
+
+func TestDryRunSummary(t *testing.T) {
+	modules := []indexer.Module{
+		{Repository: "zlib", Targets: []*indexer.Target{{Name: label.New("zlib", "", "zlib")}}},
+		{Targets: []*indexer.Target{{Name: label.New("", "lib", "a")}, {Name: label.New("", "lib", "b")}}},
+	}
+	assert.Equal(t, `Discovered 2 modules with 3 targets
+@zlib                                   : 1 targets
+main repository                         : 2 targets
+`, dryRunSummary(modules))
+}
+
+func TestResolveOutputFileRelativeToWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	t.Setenv("BUILD_WORKSPACE_DIRECTORY", workspace)
+	setFlag(t, repositoryDir, "")
+	setFlag(t, dryRun, false)
+
+	// Relative output is resolved under the workspace instead of the current working directory, e.g. when using 'bazel run'
+	setFlag(t, output, "index/output.ccidx")
+	assert.Equal(t, filepath.Join(workspace, "index/output.ccidx"), ResolveOutputFile())
+
+	// Explicit --repository takes precedence over the workspace
+	repository := t.TempDir()
+	setFlag(t, repositoryDir, repository)
+	assert.Equal(t, filepath.Join(repository, "index/output.ccidx"), ResolveOutputFile())
+}
@@ -25,8 +25,10 @@
 package indexer
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
+	"log"
 	"maps"
 	"os"
 	"path"
@@ -34,8 +36,12 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel"
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel/proto"
 	"github.com/EngFlow/gazelle_cc/index/internal/collections"
 	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
 )
 
 type (
@@ -60,15 +66,64 @@ type (
 type IndexingResult struct {
 	// Headers mapping to exactly one Bazel rule
 	HeaderToRule map[string]label.Label
-	// Headers defined in multiple rules
+	// Headers defined in multiple rules. If the AmbiguityPolicy selected one of the rules it's stored in HeaderToRule
+	// and only the remaining rules are listed here
 	Ambiguous map[string][]label.Label
+	// Sorted direct dependencies of indexed rules, allowing to reconstruct the dependency graph of external modules.
+	// Rules without dependencies are not listed, nil if none of the rules has dependencies
+	Deps map[label.Label][]label.Label
+}
+
+// Defines how headers defined in multiple rules are assigned
+type AmbiguityPolicy string
+
+// List of all recognized ambiguity policies
+var AmbiguityPolicies = []AmbiguityPolicy{KeepAmbiguous, PreferShortestLabel, PreferRepositoryRoot, FailOnAmbiguous}
+
+const (
+	// Ambiguous headers are not assigned to any rule
+	KeepAmbiguous AmbiguityPolicy = ""
+	// Ambiguous header is assigned to the rule with the shortest label, ties are resolved in lexicographic order
+	PreferShortestLabel AmbiguityPolicy = "shortest_label"
+	// Ambiguous header is assigned to the rule defined closest to the repository root, if there is exactly one such rule
+	PreferRepositoryRoot AmbiguityPolicy = "repository_root"
+	// Indexing fails if any of the headers is ambiguous
+	FailOnAmbiguous AmbiguityPolicy = "fail"
+)
+
+// Options allowing to customize how the headers are assigned to targets
+type IndexingOptions struct {
+	// When enabled, root targets (not being a dependency of any other target in the same module) are indexed also by the headers
+	// exposed by their transitive dependencies within the same module.
+	// Headers defined directly by some target always take precedence over headers collected transitively.
+	// Header reachable from multiple root targets through the same owning target is assigned to the root nearest to the owner.
+	FollowTransitiveDeps bool
+	// Defines how headers defined in multiple rules are assigned, by default these are not assigned to any rule
+	AmbiguityPolicy AmbiguityPolicy
+	// When enabled, header defined in multiple rules is assigned to the rule re-exporting it: the one nearest to the rule owning the header, which all of the other rules defining the header transitively depend on.
+	// Consumers including the header depend on the nearest rule exposing it, instead of the rule owning it. Applied before AmbiguityPolicy.
+	PreferReexports bool
 }
 
 // Process list of modules to create an unfiorm index mapping header to exactly one rule that provides their definition.
 // In case if multiple modules define same headers might try to select one that behaves as clousers over remaining ambigious rules.
 func CreateHeaderIndex(modules []Module) IndexingResult {
+	// Default options never lead to an error
+	result, _ := CreateHeaderIndexWithOptions(modules, IndexingOptions{})
+	return result
+}
+
+// Variant of CreateHeaderIndex allowing to customize indexing behaviour using provided options.
+// Returns an error if there are ambiguous headers when using FailOnAmbiguous policy, the result contains all of them.
+func CreateHeaderIndexWithOptions(modules []Module, options IndexingOptions) (IndexingResult, error) {
 	// headersMapping will store header paths to a collections.Set of Labels.
 	headersMapping := make(map[string][]label.Label)
+	// transitiveHeadersMapping stores headers exposed by root targets through their dependencies
+	transitiveHeadersMapping := make(map[string][]transitiveHeaderProvider)
+	var reexports *reexportsGraph
+	if options.PreferReexports {
+		reexports = newReexportsGraph(modules)
+	}
 	for _, module := range modules {
 		for _, target := range module.Targets {
 			// Create a targetLabel for the target using the module repository.
@@ -80,7 +135,12 @@ func CreateHeaderIndex(modules []Module) IndexingResult {
 
 			// Normalize headers and add to mapping
 			for hdr := range target.Hdrs {
-				for _, normalizedPath := range IndexableIncludePaths(hdr.Name, *target) {
+				includePaths := indexableIncludePaths(hdr.Name, *target)
+				if owner := reexports.findOwner(targetLabel, hdr); owner != nil {
+					// Re-exported header is available under the same paths as when included using the rule owning it
+					includePaths = indexableIncludePaths(hdr.Name, *owner)
+				}
+				for normalizedPath := range includePaths.All() {
 					if shouldExcludeHeader(normalizedPath) {
 						continue
 					}
@@ -88,6 +148,27 @@ func CreateHeaderIndex(modules []Module) IndexingResult {
 				}
 			}
 		}
+
+		if options.FollowTransitiveDeps {
+			for target, headers := range collectTransitiveHeaders(module) {
+				targetLabel := label.New(module.Repository, target.Name.Pkg, target.Name.Name)
+				if shouldExcludeTarget(targetLabel) {
+					continue
+				}
+				for includePath, header := range headers {
+					transitiveHeadersMapping[includePath] = append(transitiveHeadersMapping[includePath], transitiveHeaderProvider{
+						root:     targetLabel,
+						owner:    label.New(module.Repository, header.owner.Name.Pkg, header.owner.Name.Name),
+						distance: header.distance,
+					})
+				}
+			}
+		}
+	}
+	for path, providers := range transitiveHeadersMapping {
+		if _, isDefinedDirectly := headersMapping[path]; !isDefinedDirectly {
+			headersMapping[path] = selectTransitiveHeaderProviders(providers)
+		}
 	}
 
 	// Partition the headers into non-conflicting (exactly one label) and ambiguous (multiple labels).
@@ -100,16 +181,293 @@ func CreateHeaderIndex(modules []Module) IndexingResult {
 				headerToRule[path] = l
 				break
 			}
+		} else if selected, ok := reexports.selectReexportingRule(labels); ok {
+			headerToRule[path] = selected
+			ambiguous[path] = slices.DeleteFunc(slices.Clone(labels), func(l label.Label) bool { return l == selected })
+		} else if selected, ok := options.AmbiguityPolicy.selectRule(labels); ok {
+			headerToRule[path] = selected
+			ambiguous[path] = slices.DeleteFunc(slices.Clone(labels), func(l label.Label) bool { return l == selected })
 		} else {
 			// If there are multiple labels, mark as ambiguous
 			ambiguous[path] = labels
 		}
 	}
 
-	return IndexingResult{
+	result := IndexingResult{
 		HeaderToRule: headerToRule,
 		Ambiguous:    ambiguous,
 	}
+	for targetLabel, deps := range targetDependencies(modules) {
+		if shouldExcludeTarget(targetLabel) {
+			continue
+		}
+		if result.Deps == nil {
+			result.Deps = make(map[label.Label][]label.Label)
+		}
+		result.Deps[targetLabel] = slices.SortedFunc(slices.Values(deps), func(a, b label.Label) int {
+			return cmp.Compare(a.String(), b.String())
+		})
+	}
+	if options.AmbiguityPolicy == FailOnAmbiguous && len(ambiguous) > 0 {
+		headers := slices.Sorted(maps.Keys(ambiguous))
+		return result, fmt.Errorf("%d headers are defined in multiple rules, e.g. %v defined in %v", len(headers), headers[0], ambiguous[headers[0]])
+	}
+	return result, nil
+}
+
+// Selects a single rule defining the ambiguous header, returns false if policy does not allow to select any of them
+func (policy AmbiguityPolicy) selectRule(labels []label.Label) (label.Label, bool) {
+	switch policy {
+	case PreferShortestLabel:
+		return slices.MinFunc(labels, func(a, b label.Label) int {
+			if c := cmp.Compare(len(a.String()), len(b.String())); c != 0 {
+				return c
+			}
+			return strings.Compare(a.String(), b.String())
+		}), true
+	case PreferRepositoryRoot:
+		depth := func(l label.Label) int {
+			if l.Pkg == "" {
+				return 0
+			}
+			return strings.Count(l.Pkg, "/") + 1
+		}
+		selected := slices.MinFunc(labels, func(a, b label.Label) int { return cmp.Compare(depth(a), depth(b)) })
+		for _, l := range labels {
+			if l != selected && depth(l) == depth(selected) {
+				return label.NoLabel, false // Multiple rules defined at the same depth
+			}
+		}
+		return selected, true
+	default:
+		return label.NoLabel, false
+	}
+}
+
+// Dependencies between targets of all indexed modules, used to track headers re-exported by targets depending on the rule owning them
+type reexportsGraph struct {
+	// Targets identified by labels including the repository of their module
+	targets map[label.Label]*Target
+	// Direct dependencies of each of the targets
+	dependencies map[label.Label][]label.Label
+}
+
+func newReexportsGraph(modules []Module) *reexportsGraph {
+	graph := &reexportsGraph{
+		targets:      make(map[label.Label]*Target),
+		dependencies: targetDependencies(modules),
+	}
+	for _, module := range modules {
+		for _, target := range module.Targets {
+			graph.targets[label.New(module.Repository, target.Name.Pkg, target.Name.Name)] = target
+		}
+	}
+	return graph
+}
+
+// Collects direct dependencies of all targets, both targets and their dependencies are identified using absolute labels
+// qualified with the repository of the module defining them. Targets without dependencies are not listed.
+func targetDependencies(modules []Module) map[label.Label][]label.Label {
+	dependencies := make(map[label.Label][]label.Label)
+	for _, module := range modules {
+		for _, target := range module.Targets {
+			targetLabel := label.New(module.Repository, target.Name.Pkg, target.Name.Name)
+			for dep := range target.Deps {
+				if dep.Relative {
+					dep = dep.Abs(module.Repository, target.Name.Pkg)
+				}
+				dependencies[targetLabel] = append(dependencies[targetLabel], label.New(cmp.Or(dep.Repo, module.Repository), dep.Pkg, dep.Name))
+			}
+		}
+	}
+	return dependencies
+}
+
+// Returns labels of all transitive dependencies of the target ordered by their distance from it, dependencies at the same distance are sorted by label.
+// Distances, the number of dependency edges on the shortest path from the target, are returned for each of them. The target itself is included only when it's a part of a cycle
+func (graph *reexportsGraph) transitiveDeps(from label.Label) ([]label.Label, map[label.Label]int) {
+	var ordered []label.Label
+	distances := make(map[label.Label]int)
+	level := graph.dependencies[from]
+	for distance := 1; len(level) > 0; distance++ {
+		var next []label.Label
+		for _, dep := range slices.SortedFunc(slices.Values(level), func(a, b label.Label) int { return cmp.Compare(a.String(), b.String()) }) {
+			if _, visited := distances[dep]; visited {
+				continue
+			}
+			distances[dep] = distance
+			ordered = append(ordered, dep)
+			next = append(next, graph.dependencies[dep]...)
+		}
+		level = next
+	}
+	return ordered, distances
+}
+
+// Finds the nearest transitive dependency of the target owning the header, defined in the same package as the header and listing it in its hdrs.
+// Returns nil if the header is owned by the target itself, or when tracking of re-exports is disabled
+func (graph *reexportsGraph) findOwner(from label.Label, hdr label.Label) *Target {
+	if graph == nil || hdr.Relative || hdr.Pkg == from.Pkg {
+		return nil
+	}
+	deps, _ := graph.transitiveDeps(from)
+	for _, dep := range deps {
+		owner, exists := graph.targets[dep]
+		if !exists || dep == from || owner.Name.Pkg != hdr.Pkg {
+			continue
+		}
+		for ownedHdr := range owner.Hdrs {
+			if ownedHdr.Pkg == hdr.Pkg && ownedHdr.Name == hdr.Name {
+				return owner
+			}
+		}
+	}
+	return nil
+}
+
+// Selects the rule re-exporting the header defined in all of the given rules: the one nearest to the rule owning the header, which all of the remaining rules transitively depend on.
+// Returns false if tracking of re-exports is disabled or there is no such rule, e.g. when the rules are not depending on each other or multiple re-exporters are equally near.
+func (graph *reexportsGraph) selectReexportingRule(labels []label.Label) (label.Label, bool) {
+	if graph == nil {
+		return label.NoLabel, false
+	}
+	distances := make(map[label.Label]map[label.Label]int, len(labels))
+	for _, l := range labels {
+		_, distances[l] = graph.transitiveDeps(l)
+		if _, isCyclic := distances[l][l]; isCyclic {
+			// Rules depending on each other create a cycle, none of them re-exports the header of the other
+			return label.NoLabel, false
+		}
+	}
+	owner := label.NoLabel
+	for _, candidate := range labels {
+		if !slices.ContainsFunc(labels, func(l label.Label) bool {
+			_, reachesCandidate := distances[l][candidate]
+			return l != candidate && !reachesCandidate
+		}) {
+			owner = candidate
+			break
+		}
+	}
+	if owner == label.NoLabel {
+		return label.NoLabel, false
+	}
+	selected, nearest, isTie := label.NoLabel, 0, false
+	for _, candidate := range labels {
+		if candidate == owner {
+			continue
+		}
+		switch distance := distances[candidate][owner]; {
+		case selected == label.NoLabel || distance < nearest:
+			selected, nearest, isTie = candidate, distance, false
+		case distance == nearest:
+			isTie = true
+		}
+	}
+	if isTie {
+		return label.NoLabel, false
+	}
+	return selected, selected != label.NoLabel
+}
+
+// Header exposed by a root target through one of its transitive dependencies
+type transitiveHeader struct {
+	// Target defining the header
+	owner *Target
+	// Number of dependency edges between the root target and the owner
+	distance int
+}
+
+// Root target exposing a header of its transitive dependency, see collectTransitiveHeaders
+type transitiveHeaderProvider struct {
+	root     label.Label
+	owner    label.Label
+	distance int
+}
+
+// Selects labels of root targets that should be assigned to a header collected transitively.
+// Multiple roots reaching the same owning target, e.g. when sharing a dependency, are not ambiguous: the root nearest to the owner is selected, with ties broken by label.
+// Otherwise all of the distinct roots are returned.
+func selectTransitiveHeaderProviders(providers []transitiveHeaderProvider) []label.Label {
+	providers = slices.SortedFunc(slices.Values(providers), func(a, b transitiveHeaderProvider) int {
+		return cmp.Or(cmp.Compare(a.distance, b.distance), cmp.Compare(a.root.String(), b.root.String()))
+	})
+	owners := make(collections.Set[label.Label])
+	for _, provider := range providers {
+		owners.Add(provider.owner)
+	}
+	if len(owners) == 1 {
+		return []label.Label{providers[0].root}
+	}
+	var roots []label.Label
+	for _, provider := range providers {
+		if !slices.Contains(roots, provider.root) {
+			roots = append(roots, provider.root)
+		}
+	}
+	return roots
+}
+
+// For each root target of the module, a target that is not a dependency of any other target in the module,
+// collects include paths of headers defined by its transitive dependencies within the same module together with the nearest target defining them.
+func collectTransitiveHeaders(module Module) map[*Target]map[string]transitiveHeader {
+	// Targets are identified by their package and name, dependencies might refer to the same module using repository name or relative labels
+	targetKey := func(l label.Label, fromPkg string) label.Label {
+		if l.Relative {
+			l = l.Abs("", fromPkg)
+		}
+		return label.New("", l.Pkg, l.Name)
+	}
+	targetsByName := make(map[label.Label]*Target, len(module.Targets))
+	for _, target := range module.Targets {
+		targetsByName[targetKey(target.Name, "")] = target
+	}
+	dependencies := make(map[*Target][]*Target, len(module.Targets))
+	dependentTargets := make(collections.Set[*Target])
+	for _, target := range module.Targets {
+		for dep := range target.Deps {
+			if dep.Repo != "" && dep.Repo != module.Repository {
+				continue // Defined in other module
+			}
+			if depTarget, exists := targetsByName[targetKey(dep, target.Name.Pkg)]; exists && depTarget != target {
+				dependencies[target] = append(dependencies[target], depTarget)
+				dependentTargets.Add(depTarget)
+			}
+		}
+	}
+
+	result := make(map[*Target]map[string]transitiveHeader)
+	for _, root := range module.Targets {
+		if dependentTargets.Contains(root) {
+			continue
+		}
+		headers := make(map[string]transitiveHeader)
+		// Breadth-first traversal, targets are visited in order of their distance from the root
+		visited := collections.SetOf(root)
+		queue := slices.Clone(dependencies[root])
+		for distance := 1; len(queue) > 0; distance++ {
+			var next []*Target
+			for _, target := range queue {
+				if visited.Contains(target) {
+					continue
+				}
+				visited.Add(target)
+				for hdr := range target.Hdrs {
+					for normalizedPath := range indexableIncludePaths(hdr.Name, *target).All() {
+						if _, exists := headers[normalizedPath]; !exists && !shouldExcludeHeader(normalizedPath) {
+							headers[normalizedPath] = transitiveHeader{owner: target, distance: distance}
+						}
+					}
+				}
+				next = append(next, dependencies[target]...)
+			}
+			queue = next
+		}
+		if len(headers) > 0 {
+			result[root] = headers
+		}
+	}
+	return result
 }
 
 // Writes the mapping of IndexingResult.HeaderToRule to disk in JSON format.
@@ -132,6 +490,120 @@ func (result IndexingResult) WriteToFile(outputFile string) error {
 	return nil
 }
 
+// Version of the index file format written by WriteToFileV2
+const IndexFormatVersion = 1
+
+// Versioned index file format, allowing to evolve the format without breaking readers of previous versions
+type IndexFile struct {
+	Version int `json:"version"`
+	// Mapping of header to the label of rule defining it
+	Mappings map[string]string `json:"mappings"`
+	// Headers defined in multiple rules, mapped to sorted labels of rules that were not selected
+	Ambiguous map[string][]string `json:"ambiguous"`
+	// Labels of indexed rules mapped to sorted labels of their direct dependencies, optional
+	Deps map[string][]string `json:"deps,omitempty"`
+}
+
+// Writes IndexingResult to disk in versioned JSON format, containing both the mappings and ambiguous headers.
+// Keys and labels are sorted, the output is stable and can be easily compared.
+func (result IndexingResult) WriteToFileV2(outputFile string) error {
+	file := IndexFile{
+		Version:   IndexFormatVersion,
+		Mappings:  make(map[string]string, len(result.HeaderToRule)),
+		Ambiguous: make(map[string][]string, len(result.Ambiguous)),
+	}
+	for hdr, label := range result.HeaderToRule {
+		file.Mappings[hdr] = label.String()
+	}
+	for hdr, labels := range result.Ambiguous {
+		rendered := make([]string, len(labels))
+		for i, label := range labels {
+			rendered[i] = label.String()
+		}
+		slices.Sort(rendered)
+		file.Ambiguous[hdr] = rendered
+	}
+	if len(result.Deps) > 0 {
+		file.Deps = make(map[string][]string, len(result.Deps))
+		for target, deps := range result.Deps {
+			rendered := make([]string, len(deps))
+			for i, dep := range deps {
+				rendered[i] = dep.String()
+			}
+			slices.Sort(rendered)
+			file.Deps[target.String()] = rendered
+		}
+	}
+
+	// Keys of maps are always sorted when serialized
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize header index to json: %w", err)
+	}
+
+	os.MkdirAll(filepath.Dir(outputFile), 0777)
+	if err := os.WriteFile(outputFile, data, 0666); err != nil {
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	return nil
+}
+
+// Reads the index file written by either WriteToFile or WriteToFileV2.
+// Labels are not parsed, the index can be loaded even if it contains malformed entries.
+func LoadFromFile(indexFile string) (IndexFile, error) {
+	data, err := os.ReadFile(indexFile)
+	if err != nil {
+		return IndexFile{}, fmt.Errorf("failed to read index file: %w", err)
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	// Legacy index files are flat objects mapping headers to labels, the version field is never a number
+	if err := json.Unmarshal(data, &header); err != nil || header.Version == 0 {
+		file := IndexFile{Mappings: map[string]string{}, Ambiguous: map[string][]string{}}
+		if err := json.Unmarshal(data, &file.Mappings); err != nil {
+			return IndexFile{}, fmt.Errorf("failed to parse index file: %w", err)
+		}
+		return file, nil
+	}
+	if header.Version != IndexFormatVersion {
+		return IndexFile{}, fmt.Errorf("unsupported version of index file: %d, expected %d", header.Version, IndexFormatVersion)
+	}
+	var file IndexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return IndexFile{}, fmt.Errorf("failed to parse index file: %w", err)
+	}
+	return file, nil
+}
+
+// Checks consistency of the index file, returns a sorted list of issues found, empty if the index is valid.
+// Reports headers that would be excluded when indexing, e.g. hidden files, and labels that are malformed or possibly internal.
+func (file IndexFile) Check() []string {
+	issues := []string{}
+	checkLabel := func(hdr string, rawLabel string) {
+		parsed, err := label.Parse(rawLabel)
+		switch {
+		case err != nil:
+			issues = append(issues, fmt.Sprintf("%v: invalid label %q: %v", hdr, rawLabel, err))
+		case shouldExcludeTarget(parsed) || (parsed.Pkg != "" && shouldExcludeHeader(parsed.Pkg)):
+			issues = append(issues, fmt.Sprintf("%v: label %v refers to possibly internal or hidden package", hdr, rawLabel))
+		}
+	}
+	for hdr, rawLabel := range file.Mappings {
+		if shouldExcludeHeader(hdr) {
+			issues = append(issues, fmt.Sprintf("%v: header is blank or hidden", hdr))
+		}
+		checkLabel(hdr, rawLabel)
+	}
+	for hdr, rawLabels := range file.Ambiguous {
+		for _, rawLabel := range rawLabels {
+			checkLabel(hdr, rawLabel)
+		}
+	}
+	slices.Sort(issues)
+	return issues
+}
+
 // String returns a human-readable string representation of the IndexingResult.
 func (result IndexingResult) String() string {
 	var sb strings.Builder
@@ -206,12 +678,123 @@ func splitWords(s string) []string {
 // It considers the effects of the Bazel cc_library attributes:
 // - strip_include_prefix: Removes a real path prefix before exposing headers
 // - include_prefix: Prepends a virtual path to header includes after stripping
-// - includes: Adds paths to the compiler’s -I or -iquote list for locating headers
+// - includes: Adds paths to the compiler’s -I or -iquote list for locating headers, might ascend above the package or be relative to the repository root
 //
 // Returned paths reflect all valid compiler-visible forms for the header within the target’s package.
 // They are useful for detecting which targets may expose a given header or for header-to-target indexing.
 // It does expose possible include paths introduced as sideffects by other targets
 func IndexableIncludePaths(hdr string, target Target) []string {
+	return indexableIncludePaths(hdr, target).Values()
+}
+
+// Creates a Target based on the rule defined in the build file of given package, e.g. loaded using rule.LoadFile.
+// Labels of headers and dependencies are resolved relative to the package. Parts of attributes defined using expressions
+// that cannot be evaluated without Bazel, e.g. glob() or select(), are ignored.
+func NewTargetFromRule(repo string, pkg string, r *rule.Rule) Target {
+	parseLabels := func(attr string) collections.Set[label.Label] {
+		return collections.ToSet(collections.FilterMap(literalStrings(r.Attr(attr)), func(value string) (label.Label, bool) {
+			parsed, err := label.Parse(value)
+			if err != nil {
+				return label.NoLabel, false
+			}
+			return parsed.Abs(repo, pkg), true
+		}))
+	}
+	return Target{
+		Name:               label.New(repo, pkg, r.Name()),
+		Hdrs:               parseLabels("hdrs"),
+		Includes:           collections.ToSet(literalStrings(r.Attr("includes"))),
+		StripIncludePrefix: r.AttrString("strip_include_prefix"),
+		IncludePrefix:      r.AttrString("include_prefix"),
+		Deps:               parseLabels("deps"),
+	}
+}
+
+// Creates a Target based on the rule returned by Bazel query, labels of headers and dependencies that cannot be parsed are ignored.
+// Returns false if the queried target is not a rule, e.g. a source file matched by the query, or its label cannot be parsed.
+func NewTargetFromQuery(info *proto.Target) (Target, bool) {
+	if info.GetRule() == nil {
+		// Query might match source files or other non-rule targets
+		return Target{}, false
+	}
+	name, err := label.Parse(info.GetRule().GetName())
+	if err != nil {
+		log.Printf("Failed to parse queried target label: %v", info.GetRule().GetName())
+		return Target{}, false
+	}
+	parseLabels := func(attr string) collections.Set[label.Label] {
+		return collections.ToSet(collections.FilterMap(bazel.GetNamedAttribute(info, attr).GetStringListValue(), func(value string) (label.Label, bool) {
+			parsed, err := label.Parse(value)
+			if err != nil {
+				return label.NoLabel, false
+			}
+			return parsed, true
+		}))
+	}
+	return Target{
+		Name:               name,
+		Hdrs:               parseLabels("hdrs"),
+		Includes:           collections.ToSet(bazel.GetNamedAttribute(info, "includes").GetStringListValue()),
+		StripIncludePrefix: bazel.GetNamedAttribute(info, "strip_include_prefix").GetStringValue(),
+		IncludePrefix:      bazel.GetNamedAttribute(info, "include_prefix").GetStringValue(),
+		Deps:               parseLabels("deps"),
+	}, true
+}
+
+// Creates a Module named moduleName of rules returned by Bazel query, see NewTargetFromQuery.
+// Only rules accepted by the filter are included, e.g. the ones defined in the indexed repository. Nil filter accepts all of the rules
+func NewModuleFromQuery(query *proto.QueryResult, moduleName string, filter func(name label.Label) bool) Module {
+	targets := []*Target{}
+	for _, info := range query.GetTarget() {
+		target, ok := NewTargetFromQuery(info)
+		if !ok || (filter != nil && !filter(target.Name)) {
+			continue
+		}
+		targets = append(targets, &target)
+	}
+	return Module{
+		Repository: moduleName,
+		Targets:    targets,
+	}
+}
+
+// Collects string literals of the list expression, including lists concatenated with other expressions, e.g. `["a.h"] + glob(["*.h"])`
+func literalStrings(expr bzl.Expr) []string {
+	switch expr := expr.(type) {
+	case *bzl.ListExpr:
+		values := []string{}
+		for _, elem := range expr.List {
+			if str, ok := elem.(*bzl.StringExpr); ok {
+				values = append(values, str.Value)
+			}
+		}
+		return values
+	case *bzl.BinaryExpr:
+		if expr.Op == "+" {
+			return slices.Concat(literalStrings(expr.X), literalStrings(expr.Y))
+		}
+	}
+	return nil
+}
+
+// Returns all possible `#include` paths of each header defined by the rule from the build file of given package, see IndexableIncludePaths.
+// Headers defined in other packages are skipped, their include paths depend on the rules defined in these packages.
+func RuleIndexableIncludePaths(repo string, pkg string, r *rule.Rule) map[label.Label][]string {
+	target := NewTargetFromRule(repo, pkg, r)
+	includePaths := make(map[label.Label][]string, len(target.Hdrs))
+	for hdr := range target.Hdrs {
+		if hdr.Repo != repo || hdr.Pkg != pkg {
+			continue
+		}
+		paths := IndexableIncludePaths(hdr.Name, target)
+		slices.Sort(paths)
+		includePaths[hdr] = paths
+	}
+	return includePaths
+}
+
+// Variant of IndexableIncludePaths returning set of paths, allowing to iterate over them without additional allocations
+func indexableIncludePaths(hdr string, target Target) collections.Set[string] {
 	packagePath := target.Name.Pkg
 	headerPath := filepath.ToSlash(filepath.Join(packagePath, hdr))
 
@@ -236,8 +819,8 @@ func IndexableIncludePaths(hdr string, target Target) []string {
 		}
 	}
 
-	// 2. Include raw hdr as given unless is stripped
-	if stripped == hdr {
+	// 2. Include raw hdr as given unless is stripped or defined outside of the package
+	if stripped == hdr && !isOutsideOfRoot(hdr) {
 		possibleIncludes.Add(hdr)
 	}
 	// 3. Apply include_prefix (only valid when include_prefix is set)
@@ -248,11 +831,15 @@ func IndexableIncludePaths(hdr string, target Target) []string {
 
 	// 4. Derive paths from `includes`
 	for include := range target.Includes {
-		includePath := include
-		if includePath == "." {
-			includePath = ""
+		var fullIncludePath string
+		if path.IsAbs(include) {
+			fullIncludePath = strings.TrimPrefix(path.Clean(include), "/")
+		} else {
+			fullIncludePath = path.Join(packagePath, include)
+		}
+		if isOutsideOfRoot(fullIncludePath) {
+			continue // Include directory is outside of the repository
 		}
-		fullIncludePath := path.Join(packagePath, includePath)
 		fullHdrPath := path.Join(packagePath, hdr)
 
 		if rel, err := filepath.Rel(fullIncludePath, fullHdrPath); err == nil && !strings.HasPrefix(rel, "..") {
@@ -270,5 +857,10 @@ func IndexableIncludePaths(hdr string, target Target) []string {
 	}
 
 	// Final collection
-	return possibleIncludes.Values()
+	return possibleIncludes
+}
+
+// Checks if the clean, relative path refers to the parent of the directory it's relative to
+func isOutsideOfRoot(relPath string) bool {
+	return relPath == ".." || strings.HasPrefix(relPath, "../")
 }
@@ -15,12 +15,18 @@
 package indexer
 
 import (
+	"encoding/json"
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/EngFlow/gazelle_cc/index/internal/bazel/proto"
 	"github.com/EngFlow/gazelle_cc/index/internal/collections"
 	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
 	"github.com/stretchr/testify/assert"
+	protobuf "google.golang.org/protobuf/proto"
 )
 
 func TestIndexableIncludePaths(t *testing.T) {
@@ -171,6 +177,43 @@ func TestIndexableIncludePaths(t *testing.T) {
 				"lib/pkg/pkg1.h",
 			},
 		},
+		{
+			name:    "includes ascending above the package",
+			hdrPath: "../common/foo/bar.h",
+			target: Target{
+				Name:     label.Label{Pkg: "vendor/lib"},
+				Includes: collections.SetOf("../common"),
+			},
+			expected: []string{
+				"foo/bar.h",
+				"vendor/common/foo/bar.h",
+			},
+		},
+		{
+			name:    "absolute includes are relative to repository root",
+			hdrPath: "include/foo.h",
+			target: Target{
+				Name:     label.Label{Pkg: "vendor/lib"},
+				Includes: collections.SetOf("/vendor/lib/include", "/"),
+			},
+			expected: []string{
+				"foo.h",
+				"include/foo.h",
+				"vendor/lib/include/foo.h",
+			},
+		},
+		{
+			name:    "includes outside of repository are ignored",
+			hdrPath: "foo.h",
+			target: Target{
+				Name:     label.Label{Pkg: "lib"},
+				Includes: collections.SetOf("../../outside"),
+			},
+			expected: []string{
+				"foo.h",
+				"lib/foo.h",
+			},
+		},
 		{
 			name:    "pkg subdir headers",
 			hdrPath: "subdir/pkg3.h",
@@ -194,6 +237,55 @@ func TestIndexableIncludePaths(t *testing.T) {
 	}
 }
 
+func TestRuleIndexableIncludePaths(t *testing.T) {
+	file, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(`
+cc_library(
+    name = "lib",
+    hdrs = [
+        "include/lib/lib.h",
+        ":include/lib/detail.h",
+        "//other:other.h",
+    ] + glob(["generated/*.h"]),
+    strip_include_prefix = "include",
+    include_prefix = "vendor",
+    deps = [
+        ":base",
+        "//third_party:zlib",
+        "@fmt//:fmt",
+    ],
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := file.Rules[0]
+
+	t.Run("target", func(t *testing.T) {
+		target := NewTargetFromRule("", "lib", r)
+		assert.Equal(t, label.New("", "lib", "lib"), target.Name)
+		assert.ElementsMatch(t, []label.Label{
+			label.New("", "lib", "include/lib/lib.h"),
+			label.New("", "lib", "include/lib/detail.h"),
+			label.New("", "other", "other.h"),
+		}, target.Hdrs.Values())
+		assert.Equal(t, "include", target.StripIncludePrefix)
+		assert.Equal(t, "vendor", target.IncludePrefix)
+		assert.Empty(t, target.Includes)
+		assert.ElementsMatch(t, []label.Label{
+			label.New("", "lib", "base"),
+			label.New("", "third_party", "zlib"),
+			label.New("fmt", "", "fmt"),
+		}, target.Deps.Values())
+	})
+
+	t.Run("include paths", func(t *testing.T) {
+		assert.Equal(t, map[label.Label][]string{
+			label.New("", "lib", "include/lib/lib.h"):    {"lib/include/lib/lib.h", "vendor/lib/lib.h"},
+			label.New("", "lib", "include/lib/detail.h"): {"lib/include/lib/detail.h", "vendor/lib/detail.h"},
+		}, RuleIndexableIncludePaths("", "lib", r))
+	})
+}
+
 func TestShouldExcludeHeader(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -305,3 +397,466 @@ func TestCreateHeaderIndex(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateHeaderIndexWithTransitiveDeps(t *testing.T) {
+	umbrella := label.Label{Pkg: "", Name: "umbrella"}
+	core := label.Label{Pkg: "core", Name: "core"}
+	internal := label.Label{Pkg: "internal", Name: "impl"}
+	tests := []struct {
+		name     string
+		modules  []Module
+		options  IndexingOptions
+		expected IndexingResult
+	}{
+		{
+			name: "headers of dependencies are not followed by default",
+			modules: []Module{
+				{
+					Targets: []*Target{
+						{Name: umbrella, Deps: collections.SetOf(core)},
+						{Name: core, Hdrs: collections.SetOf(label.Label{Pkg: "core", Name: "core.h"}), Includes: collections.SetOf(".")},
+					},
+				},
+			},
+			expected: IndexingResult{
+				HeaderToRule: map[string]label.Label{
+					"core.h":      core,
+					"core/core.h": core,
+				},
+				Ambiguous: map[string][]label.Label{},
+				Deps:      map[label.Label][]label.Label{umbrella: {core}},
+			},
+		},
+		{
+			name: "root target exposes headers of its transitive deps",
+			modules: []Module{
+				{
+					Targets: []*Target{
+						{Name: umbrella, Deps: collections.SetOf(label.Label{Relative: true, Name: "public"})},
+						{Name: label.Label{Name: "public"}, Deps: collections.SetOf(internal)},
+						{Name: internal, Hdrs: collections.SetOf(label.Label{Pkg: "internal", Name: "detail.h"})},
+					},
+				},
+			},
+			options: IndexingOptions{FollowTransitiveDeps: true},
+			expected: IndexingResult{
+				HeaderToRule: map[string]label.Label{
+					"detail.h":          umbrella,
+					"internal/detail.h": umbrella,
+				},
+				Ambiguous: map[string][]label.Label{},
+				Deps: map[label.Label][]label.Label{
+					umbrella:                    {label.New("", "", "public")},
+					label.New("", "", "public"): {internal},
+				},
+			},
+		},
+		{
+			name: "directly defined headers take precedence over transitive ones",
+			modules: []Module{
+				{
+					Targets: []*Target{
+						{Name: umbrella, Deps: collections.SetOf(core)},
+						{Name: core, Hdrs: collections.SetOf(label.Label{Pkg: "core", Name: "core.h"})},
+					},
+				},
+			},
+			options: IndexingOptions{FollowTransitiveDeps: true},
+			expected: IndexingResult{
+				HeaderToRule: map[string]label.Label{
+					"core.h":      core,
+					"core/core.h": core,
+				},
+				Ambiguous: map[string][]label.Label{},
+				Deps:      map[label.Label][]label.Label{umbrella: {core}},
+			},
+		},
+		{
+			name: "dependencies from other modules are not followed",
+			modules: []Module{
+				{
+					Repository: "app",
+					Targets: []*Target{
+						{Name: umbrella, Deps: collections.SetOf(label.Label{Repo: "other", Pkg: "core", Name: "core"})},
+					},
+				},
+				{
+					Repository: "other",
+					Targets: []*Target{
+						{Name: core, Hdrs: collections.SetOf(label.Label{Pkg: "core", Name: "core.h"})},
+					},
+				},
+			},
+			options: IndexingOptions{FollowTransitiveDeps: true},
+			expected: IndexingResult{
+				HeaderToRule: map[string]label.Label{
+					"core.h":      label.New("other", "core", "core"),
+					"core/core.h": label.New("other", "core", "core"),
+				},
+				Ambiguous: map[string][]label.Label{},
+				Deps:      map[label.Label][]label.Label{label.New("app", "", "umbrella"): {label.New("other", "core", "core")}},
+			},
+		},
+		{
+			name: "roots sharing a dependency are not ambiguous, the nearest one is selected",
+			modules: []Module{
+				{
+					Targets: []*Target{
+						{Name: label.Label{Name: "app"}, Deps: collections.SetOf(label.Label{Name: "public"})},
+						{Name: label.Label{Name: "public"}, Deps: collections.SetOf(internal)},
+						{Name: umbrella, Deps: collections.SetOf(internal)},
+						{Name: internal, Hdrs: collections.SetOf(label.Label{Pkg: "internal", Name: "detail.h"})},
+					},
+				},
+			},
+			options: IndexingOptions{FollowTransitiveDeps: true},
+			expected: IndexingResult{
+				HeaderToRule: map[string]label.Label{
+					"detail.h":          umbrella,
+					"internal/detail.h": umbrella,
+				},
+				Ambiguous: map[string][]label.Label{},
+				Deps: map[label.Label][]label.Label{
+					label.New("", "", "app"):    {label.New("", "", "public")},
+					label.New("", "", "public"): {internal},
+					umbrella:                    {internal},
+				},
+			},
+		},
+		{
+			name: "roots exposing the header of different owners are ambiguous",
+			modules: []Module{
+				{
+					Targets: []*Target{
+						{Name: label.Label{Name: "app"}, Deps: collections.SetOf(label.Label{Pkg: "internal", Name: "other"})},
+						{Name: umbrella, Deps: collections.SetOf(internal)},
+						{Name: internal, Hdrs: collections.SetOf(label.Label{Pkg: "internal", Name: "detail.h"})},
+						{Name: label.Label{Pkg: "internal", Name: "other"}, Hdrs: collections.SetOf(label.Label{Pkg: "internal", Name: "detail.h"})},
+					},
+				},
+			},
+			options: IndexingOptions{FollowTransitiveDeps: true},
+			expected: IndexingResult{
+				HeaderToRule: map[string]label.Label{},
+				Ambiguous: map[string][]label.Label{
+					"detail.h":          {label.New("", "", "app"), umbrella},
+					"internal/detail.h": {label.New("", "", "app"), umbrella},
+				},
+				Deps: map[label.Label][]label.Label{
+					label.New("", "", "app"): {label.New("", "internal", "other")},
+					umbrella:                 {internal},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CreateHeaderIndexWithOptions(tt.modules, tt.options)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestCreateHeaderIndexAmbiguityPolicy(t *testing.T) {
+	root := label.Label{Pkg: "", Name: "common_headers"}
+	vendor := label.Label{Pkg: "vendor", Name: "lib"}
+	other := label.Label{Pkg: "other", Name: "lib"}
+	target := func(name label.Label) *Target {
+		return &Target{Name: name, Hdrs: collections.SetOf(label.Label{Pkg: name.Pkg, Name: "common.h"}), Includes: collections.SetOf(".")}
+	}
+	tests := []struct {
+		name              string
+		targets           []*Target
+		policy            AmbiguityPolicy
+		expectedRule      label.Label
+		expectedAmbiguous []label.Label
+		expectedErr       bool
+	}{
+		{
+			name:              "ambiguous headers are not assigned by default",
+			targets:           []*Target{target(root), target(vendor), target(other)},
+			policy:            KeepAmbiguous,
+			expectedRule:      label.NoLabel,
+			expectedAmbiguous: []label.Label{root, vendor, other},
+		},
+		{
+			name:              "prefer shortest label",
+			targets:           []*Target{target(root), target(vendor), target(other)},
+			policy:            PreferShortestLabel,
+			expectedRule:      other,
+			expectedAmbiguous: []label.Label{root, vendor},
+		},
+		{
+			name:              "prefer repository root",
+			targets:           []*Target{target(root), target(vendor), target(other)},
+			policy:            PreferRepositoryRoot,
+			expectedRule:      root,
+			expectedAmbiguous: []label.Label{vendor, other},
+		},
+		{
+			name:              "prefer repository root with multiple rules at the same depth",
+			targets:           []*Target{target(vendor), target(other)},
+			policy:            PreferRepositoryRoot,
+			expectedRule:      label.NoLabel,
+			expectedAmbiguous: []label.Label{vendor, other},
+		},
+		{
+			name:              "fail on ambiguous",
+			targets:           []*Target{target(vendor), target(other)},
+			policy:            FailOnAmbiguous,
+			expectedRule:      label.NoLabel,
+			expectedAmbiguous: []label.Label{vendor, other},
+			expectedErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CreateHeaderIndexWithOptions([]Module{{Targets: tt.targets}}, IndexingOptions{AmbiguityPolicy: tt.policy})
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			selected, exists := result.HeaderToRule["common.h"]
+			if tt.expectedRule == label.NoLabel {
+				assert.False(t, exists)
+			} else {
+				assert.Equal(t, tt.expectedRule, selected)
+			}
+			assert.Equal(t, tt.expectedAmbiguous, result.Ambiguous["common.h"])
+		})
+	}
+}
+
+func TestCreateHeaderIndexReexports(t *testing.T) {
+	app := label.Label{Pkg: "app", Name: "app"}
+	public := label.Label{Pkg: "public", Name: "public"}
+	alt := label.Label{Pkg: "alt", Name: "alt"}
+	core := label.Label{Pkg: "core", Name: "core"}
+	header := label.Label{Pkg: "core", Name: "core.h"}
+	target := func(name label.Label, reexports bool, deps ...label.Label) *Target {
+		t := &Target{Name: name, Deps: collections.SetOf(deps...)}
+		if reexports {
+			t.Hdrs = collections.SetOf(header)
+		}
+		return t
+	}
+	tests := []struct {
+		name              string
+		modules           []Module
+		options           IndexingOptions
+		expectedRule      label.Label
+		expectedAmbiguous []label.Label
+	}{
+		{
+			name: "re-exports are not tracked by default",
+			modules: []Module{{Targets: []*Target{
+				target(public, true, core),
+				target(core, true),
+			}}},
+			expectedRule: core,
+		},
+		{
+			name: "nearest re-exporter in the chain",
+			modules: []Module{{Targets: []*Target{
+				target(app, true, label.Label{Relative: true, Name: "helper"}),
+				target(label.Label{Pkg: "app", Name: "helper"}, false, public),
+				target(public, true, core),
+				target(core, true),
+			}}},
+			options:           IndexingOptions{PreferReexports: true},
+			expectedRule:      public,
+			expectedAmbiguous: []label.Label{app, core},
+		},
+		{
+			name: "re-exporters at different distances from the owner",
+			modules: []Module{{Targets: []*Target{
+				target(public, true, core),
+				target(alt, true, label.Label{Relative: true, Name: "helper"}),
+				target(label.Label{Pkg: "alt", Name: "helper"}, false, core),
+				target(core, true),
+			}}},
+			options:           IndexingOptions{PreferReexports: true},
+			expectedRule:      public,
+			expectedAmbiguous: []label.Label{alt, core},
+		},
+		{
+			name: "dependent rule not re-exporting the header",
+			modules: []Module{{Targets: []*Target{
+				target(app, false, public),
+				target(public, true, core),
+				target(core, true),
+			}}},
+			options:           IndexingOptions{PreferReexports: true},
+			expectedRule:      public,
+			expectedAmbiguous: []label.Label{core},
+		},
+		{
+			name: "re-exported from other module",
+			modules: []Module{
+				{Repository: "app", Targets: []*Target{target(public, true, label.Label{Repo: "other", Pkg: "core", Name: "core"})}},
+				{Repository: "other", Targets: []*Target{target(core, true)}},
+			},
+			options:           IndexingOptions{PreferReexports: true},
+			expectedRule:      label.New("app", "public", "public"),
+			expectedAmbiguous: []label.Label{label.New("other", "core", "core")},
+		},
+		{
+			name: "multiple independent re-exporters fall back to ambiguity policy",
+			modules: []Module{{Targets: []*Target{
+				target(public, true, core),
+				target(alt, true, core),
+				target(core, true),
+			}}},
+			options:           IndexingOptions{PreferReexports: true, AmbiguityPolicy: PreferShortestLabel},
+			expectedRule:      alt,
+			expectedAmbiguous: []label.Label{public, core},
+		},
+		{
+			name: "rules depending on each other",
+			modules: []Module{{Targets: []*Target{
+				target(public, true, core),
+				target(core, true, public),
+			}}},
+			options:           IndexingOptions{PreferReexports: true},
+			expectedRule:      label.NoLabel,
+			expectedAmbiguous: []label.Label{public, core},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CreateHeaderIndexWithOptions(tt.modules, tt.options)
+			assert.NoError(t, err)
+			selected, exists := result.HeaderToRule["core/core.h"]
+			if tt.expectedRule == label.NoLabel {
+				assert.False(t, exists)
+			} else {
+				assert.Equal(t, tt.expectedRule, selected)
+			}
+			assert.Equal(t, tt.expectedAmbiguous, result.Ambiguous["core/core.h"])
+		})
+	}
+}
+
+func TestWriteToFileV2(t *testing.T) {
+	result := IndexingResult{
+		HeaderToRule: map[string]label.Label{
+			"zlib.h":   label.New("zlib", "", "zlib"),
+			"common.h": label.New("b", "", "b"),
+		},
+		Ambiguous: map[string][]label.Label{
+			"common.h": {label.New("c", "", "c"), label.New("a", "", "a")},
+		},
+		Deps: map[label.Label][]label.Label{
+			label.New("b", "", "b"): {label.New("zlib", "", "zlib"), label.New("a", "", "a")},
+		},
+	}
+	outputFile := filepath.Join(t.TempDir(), "index.json")
+	assert.NoError(t, result.WriteToFileV2(outputFile))
+
+	data, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "version": 1,
+  "mappings": {
+    "common.h": "@b//:b",
+    "zlib.h": "@zlib//:zlib"
+  },
+  "ambiguous": {
+    "common.h": [
+      "@a//:a",
+      "@c//:c"
+    ]
+  },
+  "deps": {
+    "@b//:b": [
+      "@a//:a",
+      "@zlib//:zlib"
+    ]
+  }
+}`, string(data))
+
+	var decoded IndexFile
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, IndexFormatVersion, decoded.Version)
+	for hdr, expected := range result.HeaderToRule {
+		parsed, err := label.Parse(decoded.Mappings[hdr])
+		assert.NoError(t, err)
+		assert.Equal(t, expected, parsed)
+	}
+	for hdr, expected := range result.Ambiguous {
+		parsed := []label.Label{}
+		for _, l := range decoded.Ambiguous[hdr] {
+			parsedLabel, err := label.Parse(l)
+			assert.NoError(t, err)
+			parsed = append(parsed, parsedLabel)
+		}
+		assert.ElementsMatch(t, expected, parsed)
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	dir := t.TempDir()
+	result := IndexingResult{
+		HeaderToRule: map[string]label.Label{"zlib.h": label.New("zlib", "", "zlib")},
+		Ambiguous:    map[string][]label.Label{"common.h": {label.New("a", "", "a")}},
+	}
+	legacyFile := filepath.Join(dir, "legacy.json")
+	assert.NoError(t, result.WriteToFile(legacyFile))
+	legacy, err := LoadFromFile(legacyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, IndexFile{Mappings: map[string]string{"zlib.h": "@zlib//:zlib"}, Ambiguous: map[string][]string{}}, legacy)
+
+	versionedFile := filepath.Join(dir, "versioned.json")
+	assert.NoError(t, result.WriteToFileV2(versionedFile))
+	versioned, err := LoadFromFile(versionedFile)
+	assert.NoError(t, err)
+	assert.Equal(t, IndexFile{
+		Version:   IndexFormatVersion,
+		Mappings:  map[string]string{"zlib.h": "@zlib//:zlib"},
+		Ambiguous: map[string][]string{"common.h": {"@a//:a"}},
+	}, versioned)
+
+	unsupportedFile := filepath.Join(dir, "unsupported.json")
+	assert.NoError(t, os.WriteFile(unsupportedFile, []byte(`{"version": 2, "mappings": {}}`), 0666))
+	_, err = LoadFromFile(unsupportedFile)
+	assert.ErrorContains(t, err, "unsupported version of index file: 2")
+}
+
+func TestNewModuleFromQuery(t *testing.T) {
+	attr := func(name string, values ...string) *proto.Attribute {
+		return &proto.Attribute{Name: protobuf.String(name), StringListValue: values}
+	}
+	query := proto.QueryResult{
+		Target: []*proto.Target{
+			{Rule: &proto.Rule{
+				Name:      protobuf.String("@zlib//:zlib"),
+				RuleClass: protobuf.String("cc_library"),
+				Attribute: []*proto.Attribute{
+					attr("hdrs", "@zlib//:zlib.h", "not a label:"),
+					attr("deps", "@zlib//:zconf"),
+					{Name: protobuf.String("strip_include_prefix"), StringValue: protobuf.String("src")},
+				},
+			}},
+			{Rule: &proto.Rule{Name: protobuf.String("@other//:other"), RuleClass: protobuf.String("cc_library")}},
+			// Source files or other targets matched by the query that are not rules
+			{},
+		},
+	}
+
+	module := NewModuleFromQuery(&query, "zlib", func(name label.Label) bool { return name.Repo == "zlib" })
+	assert.Equal(t, "zlib", module.Repository)
+	assert.Equal(t, []*Target{{
+		Name:               label.New("zlib", "", "zlib"),
+		Hdrs:               collections.SetOf(label.New("zlib", "", "zlib.h")),
+		Includes:           collections.Set[string]{},
+		StripIncludePrefix: "src",
+		Deps:               collections.SetOf(label.New("zlib", "", "zconf")),
+	}}, module.Targets)
+
+	assert.Len(t, NewModuleFromQuery(&query, "all", nil).Targets, 2)
+}
@@ -18,16 +18,21 @@ package tests
 import (
 	"bytes"
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/bazelbuild/bazel-gazelle/rule"
 	"github.com/bazelbuild/rules_go/go/runfiles"
 )
 
 var (
 	gazelleBinaryPath = flag.String("gazelle_binary_path", "", "rlocationpath to the gazelle binary to test.")
 	indexerBinaryPath = flag.String("indexer_binary_path", "", "rlocationpath to the cc indexer binary to test.")
+	// Each test case starts its own Bazel server with a dedicated output base, limit their number to keep memory usage reasonable
+	maxParallelTestCases = flag.Int("max_parallel_test_cases", 2, "Maximal number of test cases executed concurrently.")
 )
 
 type IndexerIntegrationContext struct {
@@ -35,11 +40,16 @@ type IndexerIntegrationContext struct {
 	Dir string
 }
 type IndexerIntegration struct {
-	// Function to exuecute before each test case, typically integration specific preperation logc
+	// Function to exuecute before each test case, typically integration specific preperation logc.
+	// Test cases are executed in parallel, but calls to this function are serialized as it might modify state shared between test cases, e.g. package manager cache
 	BeforeTestCase func(t *testing.T, ctx IndexerIntegrationContext)
+	// Requires generated BUILD files to exactly match BUILD.expected, ignoring only leading and trailing whitespace.
+	// By default both files are formatted before comparison, so only semantic differences are reported
+	ExactBuildMatch bool
 }
 
-// Entry point for integration tests, needs to be pointed by at least 1 of `indexer_integration_test` rules src
+// Entry point for integration tests, needs to be pointed by at least 1 of `indexer_integration_test` rules src.
+// Test cases are executed in parallel, at most --max_parallel_test_cases at the same time, each of them in its own temporary directory.
 func ExecuteIndexerIntegrationTest(t *testing.T, integration IndexerIntegration) {
 	relativeGazelleBinary, err := runfiles.Rlocation(*gazelleBinaryPath)
 	if err != nil {
@@ -59,13 +69,35 @@ func ExecuteIndexerIntegrationTest(t *testing.T, integration IndexerIntegration)
 		t.Fatalf("failed to read test dir: %v", err)
 	}
 
+	if beforeTestCase := integration.BeforeTestCase; beforeTestCase != nil {
+		var mu sync.Mutex
+		integration.BeforeTestCase = func(t *testing.T, ctx IndexerIntegrationContext) {
+			mu.Lock()
+			defer mu.Unlock()
+			beforeTestCase(t, ctx)
+		}
+	}
+
+	testCases := []string{}
 	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+		if entry.IsDir() {
+			testCases = append(testCases, entry.Name())
 		}
-		tcName := entry.Name()
+	}
+	runParallel(t, testCases, *maxParallelTestCases, func(t *testing.T, tcName string) {
+		executeTestCase(t, integration, absoluteGazelleBinary, absoluteIndexerPath, filepath.Join(testCasesDir, tcName))
+	})
+}
+
+// Runs each of test cases in a parallel subtest, at most limit of them are executed at the same time
+func runParallel(t *testing.T, testCases []string, limit int, run func(t *testing.T, testCase string)) {
+	semaphore := make(chan struct{}, max(limit, 1))
+	for _, tcName := range testCases {
 		t.Run(tcName, func(t *testing.T) {
-			executeTestCase(t, integration, absoluteGazelleBinary, absoluteIndexerPath, filepath.Join(testCasesDir, tcName))
+			t.Parallel()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			run(t, tcName)
 		})
 	}
 }
@@ -103,7 +135,8 @@ func executeTestCase(
 	Execute(t, defaultExecConfig, gazelleBinary)
 
 	t.Logf("==> [%s] Validating generated BUILD.bazel", testDir)
-	err = filepath.WalkDir(".", func(path string, d os.DirEntry, err error) error {
+	// Only the copy of the test case is validated, other test cases might be running at the same time
+	err = filepath.WalkDir(testDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -111,6 +144,10 @@ func executeTestCase(
 		if !d.IsDir() && filepath.Base(path) == "BUILD.expected" {
 			dir := filepath.Dir(path)
 			buildPath := filepath.Join(dir, "BUILD")
+			if _, err := os.Stat(buildPath); os.IsNotExist(err) {
+				// Gazelle names newly created files BUILD.bazel
+				buildPath = filepath.Join(dir, "BUILD.bazel")
+			}
 			if _, err := os.Stat(buildPath); os.IsNotExist(err) {
 				t.Errorf("Missing BUILD file: %v", buildPath)
 			} else if err != nil {
@@ -118,7 +155,9 @@ func executeTestCase(
 			}
 			expected, _ := os.ReadFile(path)
 			actual, _ := os.ReadFile(buildPath)
-			if !bytes.Equal(bytes.TrimSpace(expected), bytes.TrimSpace(actual)) {
+			if equal, err := buildFilesEqual(expected, actual, integration.ExactBuildMatch); err != nil {
+				t.Errorf("Failed to compare %v with expected: %v", buildPath, err)
+			} else if !equal {
 				t.Errorf("BUILD.bazel doesn't match expected.\nExpected:\n%s\nActual:\n%s", expected, actual)
 			}
 		}
@@ -137,3 +176,26 @@ func executeTestCase(
 		"build", "//...",
 		"--incompatible_disallow_empty_glob=false")
 }
+
+// Checks if content of BUILD files is equal. Unless exact match is required, both files are formatted before comparison
+func buildFilesEqual(expected, actual []byte, exact bool) (bool, error) {
+	if exact {
+		return bytes.Equal(bytes.TrimSpace(expected), bytes.TrimSpace(actual)), nil
+	}
+	format := func(data []byte) ([]byte, error) {
+		f, err := rule.LoadData("BUILD", "", data)
+		if err != nil {
+			return nil, err
+		}
+		return f.Format(), nil
+	}
+	formattedExpected, err := format(expected)
+	if err != nil {
+		return false, fmt.Errorf("invalid expected BUILD file: %w", err)
+	}
+	formattedActual, err := format(actual)
+	if err != nil {
+		return false, fmt.Errorf("invalid generated BUILD file: %w", err)
+	}
+	return bytes.Equal(formattedExpected, formattedActual), nil
+}
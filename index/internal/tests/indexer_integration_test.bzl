@@ -27,7 +27,10 @@ def indexer_integration_test(
     |  | -- ....
     Indexer needs to implement the common flags listed in index/internal/indexer/cli/cli.go and write the output to 'generated.ccidx' file (specified by --output flag).
     Generated index would be compared with `expected.ccindex`
+    Generated BUILD files are compared with `BUILD.expected` files after formatting, unless `ExactBuildMatch` is set in `IndexerIntegration`.
     As the last step test invoked `bazel build //...` in the directory on the targets generated by the gazelle_binary
+    Test cases are executed in parallel, each in its own temporary directory and Bazel output base. At most 2 test cases are executed at the same time,
+    the limit can be changed using `--test_arg=--max_parallel_test_cases=<n>`.
    
 
     Args:
@@ -0,0 +1,128 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"flag"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunParallel(t *testing.T) {
+	for _, tc := range []struct {
+		clue               string
+		limit              int
+		testCases          []string
+		expectedConcurrent int
+	}{
+		{clue: "two parallel cases", limit: 2, testCases: []string{"a", "b"}, expectedConcurrent: 2},
+		{clue: "bounded concurrency", limit: 1, testCases: []string{"a", "b", "c"}, expectedConcurrent: 1},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			// Number of parallel tests is additionally limited by the -test.parallel flag
+			if parallel := flag.Lookup("test.parallel").Value.(flag.Getter).Get().(int); parallel < tc.limit {
+				t.Skipf("requires -test.parallel=%d, got %d", tc.limit, parallel)
+			}
+			var (
+				mu         sync.Mutex
+				running    int
+				concurrent int
+				executed   []string
+			)
+			t.Run("cases", func(t *testing.T) {
+				runParallel(t, tc.testCases, tc.limit, func(t *testing.T, testCase string) {
+					mu.Lock()
+					running++
+					concurrent = max(concurrent, running)
+					executed = append(executed, testCase)
+					mu.Unlock()
+
+					// Wait for other test cases to start, if they're allowed to run concurrently
+					deadline := time.Now().Add(5 * time.Second)
+					for time.Now().Before(deadline) {
+						mu.Lock()
+						started := running >= tc.limit || len(executed) == len(tc.testCases)
+						mu.Unlock()
+						if started {
+							break
+						}
+						time.Sleep(10 * time.Millisecond)
+					}
+
+					mu.Lock()
+					running--
+					mu.Unlock()
+				})
+			})
+			// Parallel subtests are finished once the enclosing subtest returns
+			assert.Equal(t, tc.expectedConcurrent, concurrent)
+			assert.ElementsMatch(t, tc.testCases, executed)
+		})
+	}
+}
+
+func TestBuildFilesEqual(t *testing.T) {
+	expected := []byte(`cc_library(
+    name = "lib",
+    srcs = ["lib.cc"],
+    deps = [
+        "@fmt//:fmt",
+        "@zlib//:zlib",
+    ],
+)
+`)
+	for _, tc := range []struct {
+		clue          string
+		actual        string
+		expectedEqual bool
+		exactEqual    bool
+	}{
+		{
+			clue:          "identical",
+			actual:        string(expected) + "\n",
+			expectedEqual: true,
+			exactEqual:    true,
+		},
+		{
+			clue: "formatting differences",
+			actual: `cc_library(name='lib',
+  srcs=['lib.cc'], deps=["@fmt//:fmt", "@zlib//:zlib"])`,
+			expectedEqual: true,
+			exactEqual:    false,
+		},
+		{
+			clue:          "semantic differences",
+			actual:        `cc_library(name = "lib", srcs = ["lib.cc"], deps = ["@fmt//:fmt"])`,
+			expectedEqual: false,
+			exactEqual:    false,
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			equal, err := buildFilesEqual(expected, []byte(tc.actual), false)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedEqual, equal)
+
+			equal, err = buildFilesEqual(expected, []byte(tc.actual), true)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.exactEqual, equal)
+		})
+	}
+
+	_, err := buildFilesEqual(expected, []byte("cc_library("), false)
+	assert.Error(t, err)
+}
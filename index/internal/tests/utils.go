@@ -16,43 +16,98 @@
 package tests
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 type ExecConfig struct {
-	Dir     string
-	Env     []string
+	Dir string
+	Env []string
+	// Allows the command to exit with non-zero code, failure is only logged and the test continues
 	CanFail bool
+	// Maximal duration of the command, DefaultExecTimeout is used if not set
+	Timeout time.Duration
 }
 
-// Utility to execute commands
-func Execute(t *testing.T, config ExecConfig, program string, args ...string) exec.Cmd {
+// Timeout of executed commands if not defined in ExecConfig, prevents hanging processes from blocking the tests indefinitely
+const DefaultExecTimeout = 30 * time.Minute
+
+// Utility to execute commands. Fails the test if the command does not succeed, unless ExecConfig.CanFail is set.
+// Returns the executed command allowing to inspect its exit code.
+func Execute(t testing.TB, config ExecConfig, program string, args ...string) exec.Cmd {
 	t.Helper()
-	cmd := exec.Command(program, args...)
+	cmd, output, err := execute(config, program, args...)
+	if err != nil {
+		t.Logf("Failed to execute %v: %v", cmd.Args, err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			t.Logf("Output of %v captured before timeout:\n%s", cmd.Args, output)
+		}
+		if !config.CanFail {
+			t.FailNow()
+		}
+	}
+	return *cmd
+}
+
+// Runs the command, killing it if it does not finish within the configured timeout.
+// Output of the command is forwarded to stdout/stderr and additionally captured to be reported on timeout.
+func execute(config ExecConfig, program string, args ...string) (*exec.Cmd, string, error) {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = DefaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, program, args...)
 	if config.Dir != "" {
 		cmd.Dir = config.Dir
 	}
 	if config.Env != nil {
 		cmd.Env = config.Env
 	}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		t.Logf("Failed to execute %v: %v", cmd.Args, err)
-		if !config.CanFail {
-			t.FailNow()
-		}
+	output := &lockedBuffer{}
+	cmd.Stdout = io.MultiWriter(os.Stdout, output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, output)
+	// Output pipes might be kept open by child processes that were not killed together with the command
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %v: %w", timeout, ctx.Err())
 	}
-	return *cmd
+	return cmd, output.String(), err
+}
+
+// Buffer safe to be written concurrently by stdout and stderr of the command
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
 }
 
 func AssertJsonEqual(t *testing.T, jsonA, jsonB []byte) {
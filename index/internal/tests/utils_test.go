@@ -0,0 +1,79 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteTimeout(t *testing.T) {
+	start := time.Now()
+	_, output, err := execute(ExecConfig{Timeout: 200 * time.Millisecond}, "sh", "-c", "echo started; sleep 10")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "timed out after 200ms")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, "started\n", output)
+
+	_, output, err = execute(ExecConfig{Timeout: 10 * time.Second}, "sh", "-c", "echo finished")
+	assert.NoError(t, err)
+	assert.Equal(t, "finished\n", output)
+}
+
+// Records failures of the test instead of failing the test it's running in
+type recordingT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *recordingT) Helper()                         {}
+func (t *recordingT) Logf(format string, args ...any) {}
+func (t *recordingT) FailNow() {
+	t.failed = true
+	runtime.Goexit()
+}
+
+func TestExecuteCanFail(t *testing.T) {
+	for _, tc := range []struct {
+		clue           string
+		canFail        bool
+		expectedFailed bool
+	}{
+		{clue: "failure allowed", canFail: true, expectedFailed: false},
+		{clue: "failure is fatal", canFail: false, expectedFailed: true},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			recorder := &recordingT{TB: t}
+			var cmd exec.Cmd
+			done := make(chan bool)
+			go func() {
+				defer close(done)
+				cmd = Execute(recorder, ExecConfig{CanFail: tc.canFail}, "sh", "-c", "exit 3")
+				done <- true
+			}()
+			returned := <-done
+			assert.Equal(t, tc.expectedFailed, recorder.failed)
+			assert.Equal(t, !tc.expectedFailed, returned)
+			if returned {
+				assert.Equal(t, 3, cmd.ProcessState.ExitCode())
+			}
+		})
+	}
+}
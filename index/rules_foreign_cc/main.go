@@ -15,6 +15,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -39,26 +40,35 @@ func main() {
 	}
 	outputFile := cli.ResolveOutputFile()
 
-	defsQuery, err := bazel.Query(workdir, "kind('cmake|configure_make|make|ninja', //...)")
+	// Foreign rules often share their sources and dependants, avoid querying the same targets multiple times
+	queries := bazel.NewQueryCache()
+	defsQuery, err := queries.Query(workdir, "kind('cmake|configure_make|make|ninja', //...)")
 	if err != nil {
-		log.Fatal("Bazel query failed, unable to index foreign_cc rules")
+		log.Fatalf("Bazel query failed, unable to index foreign_cc rules: %v", err)
 	}
 	modules := []indexer.Module{}
 	for _, foreignDefn := range defsQuery.GetTarget() {
-		if module := collectModuleInfo(workdir, foreignDefn); module != nil {
+		if module := collectModuleInfo(queries, workdir, foreignDefn); module != nil {
 			modules = append(modules, *module)
 		}
 	}
 
-	indexingResult := indexer.CreateHeaderIndex(modules)
-	indexingResult.WriteToFile(outputFile)
+	indexingResult, err := indexer.CreateHeaderIndexWithOptions(modules, indexer.IndexingOptions{
+		FollowTransitiveDeps: *cli.FollowDeps,
+		AmbiguityPolicy:      cli.ResolveAmbiguityPolicy(),
+		PreferReexports:      *cli.PreferReexports,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+	cli.WriteIndex(indexingResult, modules, outputFile)
 
 	if *cli.Verbose {
 		log.Println(indexingResult.String())
 	}
 }
 
-func collectModuleInfo(workdir string, foreignDefn *proto.Target) *indexer.Module {
+func collectModuleInfo(queries *bazel.QueryCache, workdir string, foreignDefn *proto.Target) *indexer.Module {
 	targets := []*indexer.Target{}
 	libSource := bazel.GetNamedAttribute(foreignDefn, "lib_source").GetStringValue()
 	includeDir := bazel.GetNamedAttribute(foreignDefn, "out_include_dir").GetStringValue()
@@ -79,8 +89,10 @@ func collectModuleInfo(workdir string, foreignDefn *proto.Target) *indexer.Modul
 	}
 
 	hdrs := collections.Set[label.Label]{}
-	if sourcesQuery, err := bazel.Query(workdir, libSource); err != nil {
-		log.Printf("Failed to query for details for lib_source %v: %w", libSource, err)
+	if sourcesQuery, err := queries.Query(workdir, libSource); errors.Is(err, bazel.ErrBazelNotFound) {
+		log.Fatalf("Unable to index foreign_cc rules: %v", err)
+	} else if err != nil {
+		log.Printf("Failed to query for details for lib_source %v: %v", libSource, err)
 	} else {
 		for _, sourcesTarget := range sourcesQuery.GetTarget() {
 			switch sourcesTarget.GetRule().GetRuleClass() {
@@ -98,29 +110,24 @@ func collectModuleInfo(workdir string, foreignDefn *proto.Target) *indexer.Modul
 		}
 	}
 
-	if depsQuery, err := bazel.ConfiguredQuery(workdir,
+	if depsQuery, err := queries.ConfiguredQuery(workdir,
 		fmt.Sprintf("kind(cc_library, rdeps(//..., %s, 1))", foreignDefn.GetRule().GetName()),
 		bazel.QueryConfig{KeepGoing: true},
-	); err != nil {
-		log.Printf("Failed to found direct dependanant of %v:%v", foreignDefn.GetRule().GetRuleClass(), foreignDefn.GetRule().GetName())
+	); errors.Is(err, bazel.ErrBazelNotFound) {
+		log.Fatalf("Unable to index foreign_cc rules: %v", err)
+	} else if err != nil {
+		log.Printf("Failed to found direct dependanant of %v:%v: %v", foreignDefn.GetRule().GetRuleClass(), foreignDefn.GetRule().GetName(), err)
 		return nil
 	} else {
 		for _, ccLib := range depsQuery.GetTarget() {
-			libName, err := label.Parse(ccLib.GetRule().GetName())
-			if err != nil {
+			target, ok := indexer.NewTargetFromQuery(ccLib)
+			if !ok {
 				continue
 			}
-			targets = append(targets, &indexer.Target{
-				Name: libName,
-				Hdrs: *hdrs.Join(
-					collections.ToSet(collections.FilterMap(
-						bazel.GetNamedAttribute(ccLib, "hdrs").GetStringListValue(),
-						tryParseLabel))),
-				Includes: collections.SetOf(includeDir),
-				Deps: collections.ToSet(collections.FilterMap(
-					bazel.GetNamedAttribute(ccLib, "deps").StringListValue,
-					tryParseLabel)),
-			})
+			// Headers of the foreign library are exposed by its direct dependants using the include directory of the build
+			target.Hdrs = *hdrs.Join(target.Hdrs)
+			target.Includes = collections.SetOf(includeDir)
+			targets = append(targets, &target)
 		}
 	}
 	return &indexer.Module{
@@ -17,32 +17,128 @@ package cc
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"maps"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"unicode"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/rule"
 )
 
 // config.Configurer methods
-func (*ccLanguage) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {}
-func (*ccLanguage) CheckFlags(fs *flag.FlagSet, c *config.Config) error          { return nil }
+func (lang *ccLanguage) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {
+	fs.StringVar(&lang.depsReportFile, "cc_deps_report", "", "path to the file to which a report of added and removed dependencies of cc rules would be written, CSV if the file has '.csv' extension, JSON otherwise. Relative paths are resolved against the repository root")
+	fs.StringVar(&lang.unresolvedReportFile, "cc_unresolved_report", "", "path to the JSON file to which includes that could not be resolved to any rule would be written, grouped by the label of the including rule. Relative paths are resolved against the repository root")
+	fs.BoolVar(&lang.verbose, "cc_verbose", false, "when true, additional diagnostics are logged, e.g. the reason why each of existing cc rules is removed")
+}
+
+func (lang *ccLanguage) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	if lang.depsReportFile != "" {
+		file := lang.depsReportFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(c.RepoRoot, file)
+		}
+		lang.depsReport = &depsReport{file: file}
+	}
+	if lang.unresolvedReportFile != "" {
+		file := lang.unresolvedReportFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(c.RepoRoot, file)
+		}
+		lang.unresolvedReport = &unresolvedReport{file: file}
+	}
+	return nil
+}
 
 const (
-	cc_group             = "cc_group"
-	cc_group_unit_cycles = "cc_group_unit_cycles"
-	cc_indexfile         = "cc_indexfile"
-	cc_search            = "cc_search"
+	cc_binary_grouping            = "cc_binary_grouping"
+	cc_bracket_includes           = "cc_bracket_includes"
+	cc_c_index                    = "cc_c_index"
+	cc_classify                   = "cc_classify"
+	cc_cuda_kind                  = "cc_cuda_kind"
+	cc_default_dep                = "cc_default_dep"
+	cc_default_visibility         = "cc_default_visibility"
+	cc_deps_order                 = "cc_deps_order"
+	cc_emit_include_prefix        = "cc_emit_include_prefix"
+	cc_emit_includes              = "cc_emit_includes"
+	cc_extensions                 = "cc_extensions"
+	cc_external_root              = "cc_external_root"
+	cc_group                      = "cc_group"
+	cc_group_unit_cycles          = "cc_group_unit_cycles"
+	cc_group_unit_orphans         = "cc_group_unit_orphans"
+	cc_ignore_include             = "cc_ignore_include"
+	cc_ignored_include_extensions = "cc_ignored_include_extensions"
+	cc_implementation_deps        = "cc_implementation_deps"
+	cc_import                     = "cc_import"
+	cc_include_dir                = "cc_include_dir"
+	cc_indexfile                  = "cc_indexfile"
+	cc_inline_test_files          = "cc_inline_test_files"
+	cc_keep_empty                 = "cc_keep_empty"
+	cc_library_name               = "cc_library_name"
+	cc_namespace_dep              = "cc_namespace_dep"
+	cc_noresolve_prefix           = "cc_noresolve_prefix"
+	cc_on_duplicate_source        = "cc_on_duplicate_source"
+	cc_reserved_name_suffix       = "cc_reserved_name_suffix"
+	cc_reserved_names             = "cc_reserved_names"
+	cc_resolve_file               = "cc_resolve_file"
+	cc_search                     = "cc_search"
+	cc_split_headers              = "cc_split_headers"
+	cc_suggest_impl_deps          = "cc_suggest_impl_deps"
+	cc_suggest_unit_splits        = "cc_suggest_unit_splits"
+	cc_test_attrs                 = "cc_test_attrs"
+	cc_test_layout                = "cc_test_layout"
+	cc_unmanaged                  = "cc_unmanaged"
+	cc_unresolved_includes        = "cc_unresolved_includes"
 )
 
 func (c *ccLanguage) KnownDirectives() []string {
 	return []string{
+		cc_binary_grouping,
+		cc_bracket_includes,
+		cc_c_index,
+		cc_classify,
+		cc_cuda_kind,
+		cc_default_dep,
+		cc_default_visibility,
+		cc_deps_order,
+		cc_emit_include_prefix,
+		cc_emit_includes,
+		cc_extensions,
+		cc_external_root,
 		cc_group,
 		cc_group_unit_cycles,
+		cc_group_unit_orphans,
+		cc_ignore_include,
+		cc_ignored_include_extensions,
+		cc_implementation_deps,
+		cc_import,
+		cc_include_dir,
 		cc_indexfile,
+		cc_inline_test_files,
+		cc_keep_empty,
+		cc_library_name,
+		cc_namespace_dep,
+		cc_noresolve_prefix,
+		cc_on_duplicate_source,
+		cc_reserved_name_suffix,
+		cc_reserved_names,
+		cc_resolve_file,
 		cc_search,
+		cc_split_headers,
+		cc_suggest_impl_deps,
+		cc_suggest_unit_splits,
+		cc_test_attrs,
+		cc_test_layout,
+		cc_unmanaged,
+		cc_unresolved_includes,
 	}
 }
 
@@ -58,17 +154,192 @@ func (c *ccLanguage) Configure(config *config.Config, rel string, f *rule.File)
 	if f == nil {
 		return
 	}
+	if len(f.Directives) > 0 {
+		// Directives of any language might change how includes are resolved, e.g. '# gazelle:resolve'
+		conf.resolveScope = &resolveScope{}
+	}
 
 	for _, d := range f.Directives {
 		switch d.Key {
+		case cc_binary_grouping:
+			selectDirectiveChoice(&conf.binaryGroupingMode, binaryGroupingModes, d)
+		case cc_bracket_includes:
+			selectDirectiveChoice(&conf.bracketIncludesMode, bracketIncludesModes, d)
+		case cc_cuda_kind:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.cudaKind = ""
+				continue
+			}
+			selectDirectiveChoice(&conf.cudaKind, cudaRuleDefs, d)
+		case cc_default_dep:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.defaultDep = label.NoLabel
+				continue
+			}
+			l, err := label.Parse(d.Value)
+			if err != nil {
+				log.Printf("# gazelle:%v: invalid label %q: %v", d.Key, d.Value, err)
+				continue
+			}
+			conf.defaultDep = l.Abs(config.RepoName, rel)
+		case cc_default_visibility:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.defaultVisibility = nil
+				continue
+			}
+			visibility := []string{}
+			for _, value := range strings.Fields(d.Value) {
+				if _, err := label.Parse(value); err != nil {
+					log.Printf("# gazelle:%v: invalid visibility label %q: %v", d.Key, value, err)
+					continue
+				}
+				visibility = append(visibility, value)
+			}
+			if len(visibility) > 0 {
+				conf.defaultVisibility = visibility
+			}
+		case cc_deps_order:
+			selectDirectiveChoice(&conf.depsOrder, depsOrders, d)
+		case cc_on_duplicate_source:
+			selectDirectiveChoice(&conf.duplicateSources, duplicateSourcesSeverities, d)
 		case cc_group:
 			selectDirectiveChoice(&conf.groupingMode, sourceGroupingModes, d)
 		case cc_group_unit_cycles:
 			selectDirectiveChoice(&conf.groupsCycleHandlingMode, groupsCycleHandlingModes, d)
-		case cc_indexfile:
-			// New indexfiles replace inherited ones
+		case cc_group_unit_orphans:
+			selectDirectiveChoice(&conf.orphanUnitsMode, orphanUnitsModes, d)
+		case cc_library_name:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.libraryName = ""
+				continue
+			}
+			if _, err := label.Parse(":" + d.Value); err != nil {
+				log.Printf("# gazelle:%v: invalid rule name %q: %v", d.Key, d.Value, err)
+				continue
+			}
+			conf.libraryName = d.Value
+		case cc_unresolved_includes:
+			selectDirectiveChoice(&conf.unresolvedIncludes, unresolvedIncludesSeverities, d)
+		case cc_test_layout:
+			selectDirectiveChoice(&conf.testLayout, testLayouts, d)
+		case cc_test_attrs:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.testAttrs = map[string]any{}
+				continue
+			}
+			args, err := splitQuoted(d.Value)
+			if err != nil {
+				log.Printf("# gazelle:%v: %v", d.Key, err)
+				continue
+			}
+			// Values extend inherited attributes, values of the same attribute are overriden
+			testAttrs := maps.Clone(conf.testAttrs)
+			for _, arg := range args {
+				key, value, found := strings.Cut(arg, "=")
+				if !found {
+					log.Printf("# gazelle:%v: invalid argument %q, expected key=value pair, e.g. 'size=large'", d.Key, arg)
+					continue
+				}
+				parse, known := ccTestAttrParsers[key]
+				if !known {
+					log.Printf("# gazelle:%v: unknown attribute %q would be ignored, expected one of %v", d.Key, key, slices.Sorted(maps.Keys(ccTestAttrParsers)))
+					continue
+				}
+				parsed, err := parse(value)
+				if err != nil {
+					log.Printf("# gazelle:%v: invalid value of %v attribute: %v", d.Key, key, err)
+					continue
+				}
+				testAttrs[key] = parsed
+			}
+			conf.testAttrs = testAttrs
+		case cc_namespace_dep:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.namespaceDeps = map[string]label.Label{}
+				continue
+			}
+			args := strings.Fields(d.Value)
+			if len(args) != 2 {
+				log.Printf("# gazelle:%v got %d arguments, expected 2, a namespace and a label", d.Key, len(args))
+				continue
+			}
+			namespace := strings.TrimPrefix(args[0], "::")
+			if !isQualifiedNamespace(namespace) {
+				log.Printf("# gazelle:%v: invalid namespace %q, expected a qualified name, e.g. 'foo::bar'", d.Key, args[0])
+				continue
+			}
+			l, err := label.Parse(args[1])
+			if err != nil {
+				log.Printf("# gazelle:%v: invalid label %q: %v", d.Key, args[1], err)
+				continue
+			}
+			// Mappings extend inherited ones, mappings of the same namespace are overriden
+			namespaceDeps := maps.Clone(conf.namespaceDeps)
+			namespaceDeps[namespace] = l.Abs(config.RepoName, rel)
+			conf.namespaceDeps = namespaceDeps
+		case cc_ignore_include:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.ignoredIncludePatterns = []*regexp.Regexp{}
+				continue
+			}
+			for _, pattern := range strings.Fields(d.Value) {
+				conf.ignoredIncludePatterns = append(conf.ignoredIncludePatterns, compileIncludePattern(pattern))
+			}
+		case cc_ignored_include_extensions:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.ignoredIncludeExtensions = defaultIgnoredIncludeExtensions()
+				continue
+			}
+			for _, ext := range strings.Fields(d.Value) {
+				if !strings.HasPrefix(ext, ".") || len(ext) == 1 {
+					log.Printf("# gazelle:%v: invalid extension %q, expected a value starting with '.', e.g. '.pch'", d.Key, ext)
+					continue
+				}
+				conf.ignoredIncludeExtensions = append(conf.ignoredIncludeExtensions, ext)
+			}
+		case cc_noresolve_prefix:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.noResolvePrefixes = defaultNoResolvePrefixes()
+				continue
+			}
+			for _, prefix := range strings.Fields(d.Value) {
+				prefix = path.Clean(prefix)
+				if path.IsAbs(prefix) || prefix == "." {
+					log.Printf("# gazelle:%v: invalid prefix %q, expected a relative include path, e.g. 'sys/'", d.Key, prefix)
+					continue
+				}
+				conf.noResolvePrefixes = append(conf.noResolvePrefixes, prefix)
+			}
+		case cc_reserved_names:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.reservedNames = defaultReservedNames()
+				continue
+			}
+			conf.reservedNames = append(conf.reservedNames, strings.Fields(d.Value)...)
+		case cc_reserved_name_suffix:
+			if strings.ContainsAny(d.Value, ":/ ") {
+				log.Printf("# gazelle:%v: invalid suffix %q, it must be a part of the rule name", d.Key, d.Value)
+				continue
+			}
+			conf.reservedNameSuffix = d.Value
+		case cc_indexfile, cc_c_index:
+			indexes := &conf.dependencyIndexes
+			if d.Key == cc_c_index {
+				indexes = &conf.cDependencyIndexes
+			}
+			// New indexfiles extend inherited ones, empty value resets them
 			if d.Value == "" {
-				conf.dependencyIndexes = []ccDependencyIndex{}
+				*indexes = []ccDependencyIndex{}
 				continue
 			}
 			path := filepath.Join(config.WorkDir, d.Value)
@@ -76,12 +347,150 @@ func (c *ccLanguage) Configure(config *config.Config, rel string, f *rule.File)
 				log.Printf("gazelle_cc: absolute paths for %v directive are not allowed, %v would be ignored", d.Key, d.Value)
 				continue
 			}
-			index, err := loadDependencyIndex(path)
+			index, graph, err := loadDependencyIndex(path)
 			if err != nil {
 				log.Printf("gazelle_cc: failed to load cc dependencies index: %v, it would be ignored. Reason: %v", path, err)
 				continue
 			}
-			conf.dependencyIndexes = append(conf.dependencyIndexes, index)
+			*indexes = append(*indexes, index)
+			if len(graph) > 0 {
+				conf.dependencyGraph = conf.dependencyGraph.merge(graph, config.ModuleToApparentName)
+			}
+		case cc_inline_test_files:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.inlineTestPatterns = []string{}
+				continue
+			}
+			for _, pattern := range strings.Fields(d.Value) {
+				if _, err := path.Match(pattern, ""); err != nil {
+					log.Printf("# gazelle:%v: invalid pattern %q: %v", d.Key, pattern, err)
+					continue
+				}
+				conf.inlineTestPatterns = append(conf.inlineTestPatterns, pattern)
+			}
+		case cc_classify:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.classifyRules = []classifyRule{}
+				continue
+			}
+			args := strings.Fields(d.Value)
+			if len(args) != 2 {
+				log.Printf("# gazelle:%v got %d arguments, expected 2, a file name pattern and one of %v", d.Key, len(args), sourceClassifications)
+				continue
+			}
+			if _, err := path.Match(args[0], ""); err != nil {
+				log.Printf("# gazelle:%v: invalid pattern %q: %v", d.Key, args[0], err)
+				continue
+			}
+			classification := sourceClassification(args[1])
+			if !slices.Contains(sourceClassifications, classification) {
+				log.Printf("# gazelle:%v: invalid classification %q, expected one of %v", d.Key, args[1], sourceClassifications)
+				continue
+			}
+			conf.classifyRules = append(conf.classifyRules, classifyRule{pattern: args[0], classification: classification})
+		case cc_extensions:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.sourceExtensions = []string{}
+				conf.headerExtensions = []string{}
+				continue
+			}
+			for _, arg := range strings.Fields(d.Value) {
+				key, ext, found := strings.Cut(arg, "=")
+				if !found {
+					log.Printf("# gazelle:%v: invalid argument %q, expected key=value pair, e.g. 'source=.cu'", d.Key, arg)
+					continue
+				}
+				if !strings.HasPrefix(ext, ".") || len(ext) < 2 || strings.ContainsAny(ext[1:], "./") {
+					log.Printf("# gazelle:%v: invalid extension %q, expected a file extension starting with a dot, e.g. '.cu'", d.Key, ext)
+					continue
+				}
+				switch key {
+				case "source":
+					conf.sourceExtensions = append(conf.sourceExtensions, ext)
+				case "header":
+					conf.headerExtensions = append(conf.headerExtensions, ext)
+				default:
+					log.Printf("# gazelle:%v: unknown key %q would be ignored, expected one of [header source]", d.Key, key)
+				}
+			}
+		case cc_unmanaged:
+			if d.Value == "" {
+				// Special syntax (empty value) to reset directive.
+				conf.unmanagedRulePatterns = []string{}
+				continue
+			}
+			for _, pattern := range strings.Fields(d.Value) {
+				if _, err := path.Match(pattern, ""); err != nil {
+					log.Printf("# gazelle:%v: invalid pattern %q: %v", d.Key, pattern, err)
+					continue
+				}
+				conf.unmanagedRulePatterns = append(conf.unmanagedRulePatterns, pattern)
+			}
+		case cc_include_dir:
+			// New directories extend inherited ones, empty value resets them
+			if d.Value == "" {
+				conf.includeDirs = []ccIncludeDir{}
+				continue
+			}
+			args := strings.Fields(d.Value)
+			if len(args) != 2 {
+				log.Printf("# gazelle:%v got %d arguments, expected 2, a directory and a label", d.Key, len(args))
+				continue
+			}
+			dir := args[0]
+			if path.Clean(dir) != dir || path.IsAbs(dir) || dir == "." {
+				log.Printf("# gazelle:%v: invalid directory %q, expected a clean path relative to the repository root", d.Key, dir)
+				continue
+			}
+			l, err := label.Parse(args[1])
+			if err != nil {
+				log.Printf("# gazelle:%v: invalid label %q: %v", d.Key, args[1], err)
+				continue
+			}
+			includeDir, err := loadIncludeDir(config.RepoRoot, dir, l.Abs(config.RepoName, rel))
+			if err != nil {
+				log.Printf("gazelle_cc: failed to list headers of %v, %v directive would be ignored. Reason: %v", dir, d.Key, err)
+				continue
+			}
+			conf.includeDirs = append(conf.includeDirs, includeDir)
+		case cc_resolve_file:
+			// New override files extend inherited ones, empty value resets them
+			if d.Value == "" {
+				conf.resolveOverrides = []ccDependencyIndex{}
+				continue
+			}
+			if filepath.IsAbs(d.Value) {
+				log.Printf("gazelle_cc: absolute paths for %v directive are not allowed, %v would be ignored", d.Key, d.Value)
+				continue
+			}
+			path := filepath.Join(config.WorkDir, d.Value)
+			overrides, err := loadResolveOverrides(path)
+			if err != nil {
+				log.Printf("gazelle_cc: failed to load cc resolve overrides: %v, it would be ignored. Reason: %v", path, err)
+				continue
+			}
+			conf.resolveOverrides = append(conf.resolveOverrides, overrides)
+		case cc_implementation_deps:
+			selectDirectiveBool(&conf.implementationDeps, d)
+		case cc_emit_include_prefix:
+			selectDirectiveBool(&conf.emitIncludePrefix, d)
+		case cc_emit_includes:
+			selectDirectiveBool(&conf.emitIncludes, d)
+		case cc_external_root:
+			selectDirectiveBool(&conf.externalRoot, d)
+		case cc_keep_empty:
+			selectDirectiveBool(&conf.keepEmptyRules, d)
+		case cc_import:
+			selectDirectiveBool(&conf.generateImports, d)
+		case cc_split_headers:
+			selectDirectiveBool(&conf.splitHeaders, d)
+		case cc_suggest_impl_deps:
+			selectDirectiveBool(&conf.suggestImplDeps, d)
+		case cc_suggest_unit_splits:
+			selectDirectiveBool(&conf.suggestUnitSplits, d)
 		case cc_search:
 			if d.Value == "" {
 				// Special syntax (empty value) to reset directive.
@@ -92,38 +501,18 @@ func (c *ccLanguage) Configure(config *config.Config, rel string, f *rule.File)
 					log.Print(err)
 					continue
 				}
-				if len(args) == 0 || len(args) > 2 {
-					log.Printf("# gazelle:cc_search got %d arguments, expected up to 2, an include prefix to strip, and an include prefix to add", len(args))
+				searches, err := parseCcSearch(args)
+				if err != nil {
+					log.Printf("# gazelle:cc_search: %v", err)
 					continue
 				}
-				s := ccSearch{stripIncludePrefix: args[0]}
-				if s.stripIncludePrefix != "" {
-					if path.Clean(s.stripIncludePrefix) != s.stripIncludePrefix {
-						log.Printf("# gazelle:cc_search: strip_include_prefix path %q is not clean", s.stripIncludePrefix)
-						continue
-					}
-					if path.IsAbs(s.stripIncludePrefix) {
-						log.Printf("# gazelle:cc_search: strip_include_prefix path %q must be relative", s.stripIncludePrefix)
-						continue
-					}
-				}
-				if len(args) > 1 {
-					s.includePrefix = args[1]
-				}
-				if s.includePrefix != "" {
-					if path.Clean(s.includePrefix) != s.includePrefix {
-						log.Printf("# gazelle:cc_search: include_prefix path %q is not clean", s.includePrefix)
-						continue
-					}
-					if path.IsAbs(s.includePrefix) {
-						log.Printf("# gazelle:cc_search: include_prefix path %q must be relative", s.includePrefix)
-						continue
-					}
-				}
-				conf.ccSearch = append(conf.ccSearch, s)
+				conf.ccSearch = append(conf.ccSearch, searches...)
 			}
 		}
 	}
+	if conf.libraryName != "" && conf.groupingMode != groupSourcesByDirectory {
+		log.Printf("# gazelle:%v %v is ignored in %v, it can be used only with '# gazelle:%v %v'", cc_library_name, conf.libraryName, f.Path, cc_group, groupSourcesByDirectory)
+	}
 }
 
 // Compares the directive value with list of expected choices. If there is a match it updates the target with matching value
@@ -138,15 +527,105 @@ func selectDirectiveChoice[T ~string](target *T, options []T, d rule.Directive)
 	log.Printf("Invalid value for directive %v, expected one of %v, got: %v", d.Key, options, d.Value)
 }
 
+// Parses the directive value as a boolean flag (on/off). Empty value enables the flag.
+// If value is not recognized it emits warning on stderr and keeps the target unchanged
+func selectDirectiveBool(target *bool, d rule.Directive) {
+	switch strings.ToLower(d.Value) {
+	case "", "on", "true":
+		*target = true
+	case "off", "false":
+		*target = false
+	default:
+		log.Printf("Invalid value for directive %v, expected one of [on off], got: %v", d.Key, d.Value)
+	}
+}
+
 type ccConfig struct {
 	// Defines how how sources should be grouped when defining rules
 	groupingMode sourceGroupingMode
 	// Should rules with sources assigned to different targets be merged into single one if they define a cyclic dependency
 	groupsCycleHandlingMode groupsCycleHandlingMode
+	// Should sources without corresponding header be merged into the unit they're uniquely associated with
+	orphanUnitsMode orphanUnitsMode
+	// Defines how sources containing main function are grouped into cc_binary rules
+	binaryGroupingMode binaryGroupingMode
+	// Defines if includes using brackets might refer to headers relative to the including file
+	bracketIncludesMode bracketIncludesMode
+	// Defines the order of resolved dependencies
+	depsOrder depsOrder
+	// Defines how quoted includes that could not be resolved to any rule are reported
+	unresolvedIncludes unresolvedIncludesSeverity
+	// Defines how sources listed in srcs of multiple existing rules are reported
+	duplicateSources duplicateSourcesSeverity
+	// Defines if test sources are defined in cc_test rules or in testonly libraries
+	testLayout testLayout
+	// Attributes assigned to generated cc_test rules, e.g. size or timeout
+	testAttrs map[string]any
 	// User defined dependency indexes based on the filename
 	dependencyIndexes []ccDependencyIndex
+	// User defined dependency indexes consulted only for includes of C sources ('.c' files), before dependencyIndexes
+	cDependencyIndexes []ccDependencyIndex
+	// Dependencies of rules defined in all index files loaded so far, never modified in place.
+	// Entries are kept when indexes are reset, these describe external rules and are never invalidated
+	dependencyGraph ccDependencyGraph
+	// User defined include to label mappings, consulted before any other resolution method
+	resolveOverrides []ccDependencyIndex
+	// Directories which headers are all provided by a single rule, consulted after resolveOverrides
+	includeDirs []ccIncludeDir
+	// Dependencies of sources using namespaces, added only when some of the includes of the rule could not be resolved.
+	// Never modified in place, shared with subprojects
+	namespaceDeps map[string]label.Label
+	// Dependency assigned instead of quoted includes that could not be resolved to any rule, unset by default
+	defaultDep label.Label
+	// Visibility assigned to newly generated rules, when not set libraries are public and other rules use the default visibility
+	defaultVisibility []string
 	// List of 'gazelle:cc_search' directives, used to construct RelsToIndex.
 	ccSearch []ccSearch
+	// Compiled glob patterns of include paths that are dropped before resolving dependencies
+	ignoredIncludePatterns []*regexp.Regexp
+	// Extensions of included files that should never be resolved as dependencies, e.g. precompiled headers
+	ignoredIncludeExtensions []string
+	// Include path prefixes, e.g. of system headers, that are never resolved unless explicitly mapped by the user
+	noResolvePrefixes []string
+	// Names that should not be used by newly generated rules, e.g. target pattern wildcards
+	reservedNames []string
+	// Suffix appended to names of newly generated rules colliding with reservedNames, when empty such rules are only reported
+	reservedNameSuffix string
+	// Should cc_import rules be generated for prebuilt libraries in directories without sources to compile
+	generateImports bool
+	// Should public headers of cc_library be defined in a separate header-only library
+	splitHeaders bool
+	// Should a warning be reported when dependencies of a library grouping multiple translation units are used only by some of them
+	suggestUnitSplits bool
+	// Should a warning be reported when dependencies of a library are used only by its sources, but assigned to 'deps' with '# gazelle:cc_implementation_deps off'
+	suggestImplDeps bool
+	// Should existing rules with no buildable sources be kept instead of being removed
+	keepEmptyRules bool
+	// Should strip_include_prefix and include_prefix of generated libraries be inferred from 'cc_search' directives
+	emitIncludePrefix bool
+	// Should directories required to include headers of generated libraries from other packages be added to their 'includes' attribute
+	emitIncludes bool
+	// Should existing rules be indexed instead of generating rules, e.g. for third-party code vendored in the repository
+	externalRoot bool
+	// Should dependencies used only by non-header sources of cc_library be assigned to 'implementation_deps' instead of 'deps'
+	implementationDeps bool
+	// Glob patterns of source file names containing tests inlined in the implementation
+	inlineTestPatterns []string
+	// Glob patterns of names of existing rules that are never modified or removed by gazelle_cc
+	unmanagedRulePatterns []string
+	// Rules overriding classification of source files matching their patterns, later rules take precedence
+	classifyRules []classifyRule
+	// Extensions of source files recognized in addition to the built-in ones, e.g. '.cu'
+	sourceExtensions []string
+	// Extensions of header files recognized in addition to the built-in ones, e.g. '.h++'
+	headerExtensions []string
+	// Kind of rules defining CUDA sources ('.cu' and '.cuh' files), when empty such sources are ignored
+	cudaKind string
+	// Name of the library generated when sources are grouped by directory, overrides the name derived from the directory.
+	// Applies only to the package defining the directive, it's not inherited by subdirectories
+	libraryName string
+	// Identifies configurations resolving includes the same way, includes resolved using the same scope are memoized in resolveCache
+	resolveScope *resolveScope
 }
 
 type ccSearch struct {
@@ -161,16 +640,102 @@ type ccSearch struct {
 	includePrefix string
 }
 
+// Parses arguments of 'cc_search' directive, either the positional form: an include prefix to strip and an include prefix to add,
+// or any number of 'strip=<prefix>' and 'include=<prefix>' pairs. A pair ends when either of its keys is repeated.
+func parseCcSearch(args []string) ([]ccSearch, error) {
+	if !slices.ContainsFunc(args, func(arg string) bool { return strings.Contains(arg, "=") }) {
+		if len(args) == 0 || len(args) > 2 {
+			return nil, fmt.Errorf("got %d arguments, expected up to 2, an include prefix to strip, and an include prefix to add", len(args))
+		}
+		s := ccSearch{stripIncludePrefix: args[0]}
+		if len(args) > 1 {
+			s.includePrefix = args[1]
+		}
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+		return []ccSearch{s}, nil
+	}
+
+	searches := []ccSearch{}
+	var current *ccSearch
+	hasStrip, hasInclude := false, false
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found || (key != "strip" && key != "include") {
+			return nil, fmt.Errorf("invalid argument %q, expected strip=<prefix> or include=<prefix>", arg)
+		}
+		if current == nil || (key == "strip" && hasStrip) || (key == "include" && hasInclude) {
+			searches = append(searches, ccSearch{})
+			current = &searches[len(searches)-1]
+			hasStrip, hasInclude = false, false
+		}
+		if key == "strip" {
+			current.stripIncludePrefix, hasStrip = value, true
+		} else {
+			current.includePrefix, hasInclude = value, true
+		}
+	}
+	for _, s := range searches {
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return searches, nil
+}
+
+// Checks if both prefixes are clean relative paths
+func (s ccSearch) validate() error {
+	for _, prefix := range []struct{ name, value string }{
+		{"strip_include_prefix", s.stripIncludePrefix},
+		{"include_prefix", s.includePrefix},
+	} {
+		if prefix.value == "" {
+			continue
+		}
+		if path.Clean(prefix.value) != prefix.value {
+			return fmt.Errorf("%v path %q is not clean", prefix.name, prefix.value)
+		}
+		if path.IsAbs(prefix.value) {
+			return fmt.Errorf("%v path %q must be relative", prefix.name, prefix.value)
+		}
+	}
+	return nil
+}
+
 func getCcConfig(c *config.Config) *ccConfig {
 	return c.Exts[languageName].(*ccConfig)
 }
 
 func newCcConfig() *ccConfig {
 	return &ccConfig{
-		groupingMode:            groupSourcesByDirectory,
-		groupsCycleHandlingMode: mergeOnGroupsCycle,
-		dependencyIndexes:       []ccDependencyIndex{},
-		ccSearch:                defaultCcSearch(),
+		groupingMode:             groupSourcesByDirectory,
+		groupsCycleHandlingMode:  mergeOnGroupsCycle,
+		orphanUnitsMode:          keepOrphanUnits,
+		binaryGroupingMode:       binaryPerFile,
+		bracketIncludesMode:      systemBracketIncludes,
+		depsOrder:                lexicalDepsOrder,
+		unresolvedIncludes:       silentUnresolvedIncludes,
+		duplicateSources:         warnOnDuplicateSources,
+		testLayout:               separateTestLayout,
+		testAttrs:                map[string]any{},
+		dependencyIndexes:        []ccDependencyIndex{},
+		cDependencyIndexes:       []ccDependencyIndex{},
+		resolveOverrides:         []ccDependencyIndex{},
+		includeDirs:              []ccIncludeDir{},
+		namespaceDeps:            map[string]label.Label{},
+		ccSearch:                 defaultCcSearch(),
+		ignoredIncludePatterns:   []*regexp.Regexp{},
+		ignoredIncludeExtensions: defaultIgnoredIncludeExtensions(),
+		noResolvePrefixes:        defaultNoResolvePrefixes(),
+		reservedNames:            defaultReservedNames(),
+		inlineTestPatterns:       []string{},
+		unmanagedRulePatterns:    []string{},
+		classifyRules:            []classifyRule{},
+		sourceExtensions:         []string{},
+		headerExtensions:         []string{},
+		implementationDeps:       true,
+		resolveScope:             &resolveScope{},
 	}
 }
 
@@ -178,9 +743,46 @@ func (conf *ccConfig) clone() *ccConfig {
 	return &ccConfig{
 		groupingMode:            conf.groupingMode,
 		groupsCycleHandlingMode: conf.groupsCycleHandlingMode,
+		orphanUnitsMode:         conf.orphanUnitsMode,
+		binaryGroupingMode:      conf.binaryGroupingMode,
+		bracketIncludesMode:     conf.bracketIncludesMode,
+		depsOrder:               conf.depsOrder,
+		unresolvedIncludes:      conf.unresolvedIncludes,
+		duplicateSources:        conf.duplicateSources,
+		testLayout:              conf.testLayout,
+		// Attributes are never modified in place, a new map is created when directive is used
+		testAttrs: conf.testAttrs,
 		// No deep cloning of dependency indexes to reduce memory usage
-		dependencyIndexes: conf.dependencyIndexes[:len(conf.dependencyIndexes):len(conf.dependencyIndexes)],
-		ccSearch:          conf.ccSearch[:len(conf.ccSearch):len(conf.ccSearch)],
+		dependencyIndexes:        conf.dependencyIndexes[:len(conf.dependencyIndexes):len(conf.dependencyIndexes)],
+		cDependencyIndexes:       conf.cDependencyIndexes[:len(conf.cDependencyIndexes):len(conf.cDependencyIndexes)],
+		dependencyGraph:          conf.dependencyGraph,
+		resolveOverrides:         conf.resolveOverrides[:len(conf.resolveOverrides):len(conf.resolveOverrides)],
+		includeDirs:              conf.includeDirs[:len(conf.includeDirs):len(conf.includeDirs)],
+		namespaceDeps:            conf.namespaceDeps,
+		defaultDep:               conf.defaultDep,
+		defaultVisibility:        conf.defaultVisibility,
+		ccSearch:                 conf.ccSearch[:len(conf.ccSearch):len(conf.ccSearch)],
+		ignoredIncludePatterns:   conf.ignoredIncludePatterns[:len(conf.ignoredIncludePatterns):len(conf.ignoredIncludePatterns)],
+		ignoredIncludeExtensions: conf.ignoredIncludeExtensions[:len(conf.ignoredIncludeExtensions):len(conf.ignoredIncludeExtensions)],
+		noResolvePrefixes:        conf.noResolvePrefixes[:len(conf.noResolvePrefixes):len(conf.noResolvePrefixes)],
+		reservedNames:            conf.reservedNames[:len(conf.reservedNames):len(conf.reservedNames)],
+		reservedNameSuffix:       conf.reservedNameSuffix,
+		generateImports:          conf.generateImports,
+		splitHeaders:             conf.splitHeaders,
+		suggestUnitSplits:        conf.suggestUnitSplits,
+		suggestImplDeps:          conf.suggestImplDeps,
+		keepEmptyRules:           conf.keepEmptyRules,
+		emitIncludePrefix:        conf.emitIncludePrefix,
+		emitIncludes:             conf.emitIncludes,
+		externalRoot:             conf.externalRoot,
+		implementationDeps:       conf.implementationDeps,
+		inlineTestPatterns:       conf.inlineTestPatterns[:len(conf.inlineTestPatterns):len(conf.inlineTestPatterns)],
+		unmanagedRulePatterns:    conf.unmanagedRulePatterns[:len(conf.unmanagedRulePatterns):len(conf.unmanagedRulePatterns)],
+		classifyRules:            conf.classifyRules[:len(conf.classifyRules):len(conf.classifyRules)],
+		sourceExtensions:         conf.sourceExtensions[:len(conf.sourceExtensions):len(conf.sourceExtensions)],
+		headerExtensions:         conf.headerExtensions[:len(conf.headerExtensions):len(conf.headerExtensions)],
+		cudaKind:                 conf.cudaKind,
+		resolveScope:             conf.resolveScope,
 	}
 }
 
@@ -191,17 +793,124 @@ func defaultCcSearch() []ccSearch {
 	return []ccSearch{{}}
 }
 
+// defaultIgnoredIncludeExtensions returns extensions of precompiled headers.
+// These are compiler artifacts that are never provided by any rule.
+func defaultIgnoredIncludeExtensions() []string {
+	return []string{".pch", ".gch"}
+}
+
+// defaultNoResolvePrefixes returns prefixes of system headers provided by the C library and the kernel.
+// These are available in the toolchain and never provided by any rule.
+func defaultNoResolvePrefixes() []string {
+	return []string{"asm", "bits", "sys"}
+}
+
+// defaultReservedNames returns target pattern wildcards, e.g. '//pkg:all' refers to all rules of the package instead of a rule named 'all'.
+func defaultReservedNames() []string {
+	return []string{"all", "all-targets"}
+}
+
+// Checks if include should be skipped when resolving dependencies
+func (conf *ccConfig) isIgnoredInclude(include ccInclude) bool {
+	return hasMatchingExtension(include.rawPath, conf.ignoredIncludeExtensions)
+}
+
+// Checks if either raw or normalized path of the include matches one of the 'cc_ignore_include' patterns
+func (conf *ccConfig) isSuppressedInclude(include ccInclude) bool {
+	return slices.ContainsFunc(conf.ignoredIncludePatterns, func(pattern *regexp.Regexp) bool {
+		return pattern.MatchString(include.rawPath) || pattern.MatchString(include.normalizedPath)
+	})
+}
+
+// Compiles the glob pattern of include path into regular expression.
+// '*' matches any sequence of characters except '/', '**' matches any sequence including '/', '?' matches a single character except '/'.
+// '**/' prefix of path segment matches zero or more directories, e.g. '**/gen.h' matches both 'gen.h' and 'a/b/gen.h'
+func compileIncludePattern(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// Checks if include path starts with one of the prefixes that should not be resolved
+func (conf *ccConfig) isNoResolveInclude(include ccInclude) bool {
+	return slices.ContainsFunc(conf.noResolvePrefixes, func(prefix string) bool {
+		return strings.HasPrefix(include.rawPath, prefix+"/")
+	})
+}
+
+// Checks if the source file contains tests inlined in the implementation, based on the file name
+func (conf *ccConfig) isInlineTestFile(fileName string) bool {
+	return slices.ContainsFunc(conf.inlineTestPatterns, func(pattern string) bool {
+		matches, _ := path.Match(pattern, path.Base(fileName))
+		return matches
+	})
+}
+
+// Finds the classification of the source file defined using 'cc_classify' directive, based on the file name.
+// Returns false if none of the rules matches the file
+func (conf *ccConfig) classifySource(fileName string) (sourceClassification, bool) {
+	for i := len(conf.classifyRules) - 1; i >= 0; i-- {
+		if matches, _ := path.Match(conf.classifyRules[i].pattern, path.Base(fileName)); matches {
+			return conf.classifyRules[i].classification, true
+		}
+	}
+	return "", false
+}
+
+// Checks if the file is a source or a header, based on the built-in extensions and the ones defined using 'cc_extensions' directive
+func (conf *ccConfig) isCSource(fileName string) bool {
+	return hasMatchingExtension(fileName, cExtensions) || conf.isCustomHeader(fileName) || hasMatchingExtension(fileName, conf.sourceExtensions)
+}
+
+// Checks if the file is a header based on the extensions defined using 'cc_extensions' directive
+func (conf *ccConfig) isCustomHeader(fileName string) bool {
+	return hasMatchingExtension(fileName, conf.headerExtensions)
+}
+
+func (conf *ccConfig) isUnmanagedRule(ruleName string) bool {
+	return slices.ContainsFunc(conf.unmanagedRulePatterns, func(pattern string) bool {
+		matches, _ := path.Match(pattern, ruleName)
+		return matches
+	})
+}
+
 type sourceGroupingMode string
 
-var sourceGroupingModes = []sourceGroupingMode{groupSourcesByDirectory, groupSourcesByUnit}
+var sourceGroupingModes = []sourceGroupingMode{groupSourcesByDirectory, groupSourcesByUnit, groupSourcesByUnitGlobal, groupSourcesByUnitHeaderMerge}
 
 const (
 	// single cc_library per directory
 	groupSourcesByDirectory sourceGroupingMode = "directory"
 	// cc_library per translation unit or group of recursivelly dependant translation units
 	groupSourcesByUnit sourceGroupingMode = "unit"
+	// same as groupSourcesByUnit, but translation units span subdirectories without a build file, rules are defined in the closest package
+	groupSourcesByUnitGlobal sourceGroupingMode = "unit-global"
+	// same as groupSourcesByUnit, but headers without implementation connected by includes are merged into a single cc_library
+	groupSourcesByUnitHeaderMerge sourceGroupingMode = "unit-header-merge"
 )
 
+// Returns true if sources are grouped by translation units, either within a single directory or across subdirectories
+func (mode sourceGroupingMode) groupsByUnits() bool {
+	return mode == groupSourcesByUnit || mode == groupSourcesByUnitGlobal || mode == groupSourcesByUnitHeaderMerge
+}
+
 type groupsCycleHandlingMode string
 
 var groupsCycleHandlingModes = []groupsCycleHandlingMode{mergeOnGroupsCycle, warnOnGroupsCycle}
@@ -213,6 +922,127 @@ const (
 	warnOnGroupsCycle groupsCycleHandlingMode = "warn"
 )
 
+type orphanUnitsMode string
+
+var orphanUnitsModes = []orphanUnitsMode{keepOrphanUnits, mergeOrphanUnits}
+
+const (
+	// Sources without corresponding header form their own units
+	keepOrphanUnits orphanUnitsMode = "keep"
+	// Sources without corresponding header are merged into the unit they're uniquely associated with
+	mergeOrphanUnits orphanUnitsMode = "merge"
+)
+
+type binaryGroupingMode string
+
+var binaryGroupingModes = []binaryGroupingMode{binaryPerFile, singleBinary}
+
+const (
+	// cc_binary per source containing main function
+	binaryPerFile binaryGroupingMode = "per-file"
+	// single cc_binary per directory containing all sources with main function
+	singleBinary binaryGroupingMode = "single"
+)
+
+type bracketIncludesMode string
+
+var bracketIncludesModes = []bracketIncludesMode{systemBracketIncludes, localBracketIncludes}
+
+const (
+	// Includes using brackets are resolved only using paths as written in the include
+	systemBracketIncludes bracketIncludesMode = "system"
+	// Includes using brackets are additionally resolved relative to the directory of the including file, the same as quoted includes
+	localBracketIncludes bracketIncludesMode = "local"
+)
+
+type depsOrder string
+
+var depsOrders = []depsOrder{lexicalDepsOrder, depthDepsOrder, internalFirstDepsOrder}
+
+const (
+	// Dependencies are sorted by their labels
+	lexicalDepsOrder depsOrder = "lexical"
+	// Dependencies are sorted by their depth in the dependency graph known from index files, leaf dependencies first.
+	// Dependencies with the same depth are sorted by their labels
+	depthDepsOrder depsOrder = "depth"
+	// Dependencies defined in the same repository come first, followed by external dependencies, each sorted by their labels
+	internalFirstDepsOrder depsOrder = "internal-first"
+)
+
+type unresolvedIncludesSeverity string
+
+var unresolvedIncludesSeverities = []unresolvedIncludesSeverity{silentUnresolvedIncludes, warnUnresolvedIncludes, errorUnresolvedIncludes}
+
+const (
+	// Unresolved includes are not reported
+	silentUnresolvedIncludes unresolvedIncludesSeverity = "silent"
+	// Each of unresolved includes is reported as a warning
+	warnUnresolvedIncludes unresolvedIncludesSeverity = "warn"
+	// Each of unresolved includes is reported, the run fails after resolving all of the rules
+	errorUnresolvedIncludes unresolvedIncludesSeverity = "error"
+)
+
+type duplicateSourcesSeverity string
+
+var duplicateSourcesSeverities = []duplicateSourcesSeverity{warnOnDuplicateSources, errorOnDuplicateSources}
+
+const (
+	// Sources listed in multiple rules are reported as warnings
+	warnOnDuplicateSources duplicateSourcesSeverity = "warn"
+	// Sources listed in multiple rules are reported as errors, the run fails after resolution if any were reported
+	errorOnDuplicateSources duplicateSourcesSeverity = "error"
+)
+
+type testLayout string
+
+var testLayouts = []testLayout{separateTestLayout, inlineTestLayout}
+
+const (
+	// Test sources are defined in cc_test rules
+	separateTestLayout testLayout = "separate"
+	// Test sources are defined in testonly cc_library rules, linked by a custom test runner
+	inlineTestLayout testLayout = "inline"
+)
+
+type sourceClassification string
+
+var sourceClassifications = []sourceClassification{srcsClassification, hdrsClassification, mainClassification, testClassification}
+
+const (
+	// Source compiled into a library
+	srcsClassification sourceClassification = "srcs"
+	// Header exposed by a library
+	hdrsClassification sourceClassification = "hdrs"
+	// Source of a binary, independently of detecting the main function
+	mainClassification sourceClassification = "main"
+	// Source of a test
+	testClassification sourceClassification = "test"
+)
+
+// Overrides classification of source files matching the pattern, defined using 'cc_classify' directive
+type classifyRule struct {
+	// Glob pattern matched against the base name of the file
+	pattern        string
+	classification sourceClassification
+}
+
+// Attributes of cc_test that can be defined using 'cc_test_attrs' directive, mapped to the parser of their value
+var ccTestAttrParsers = map[string]func(value string) (any, error){
+	"size":        parseChoice("small", "medium", "large", "enormous"),
+	"timeout":     parseChoice("short", "moderate", "long", "eternal"),
+	"flaky":       func(value string) (any, error) { return strconv.ParseBool(value) },
+	"shard_count": func(value string) (any, error) { return strconv.Atoi(value) },
+}
+
+func parseChoice(options ...string) func(string) (any, error) {
+	return func(value string) (any, error) {
+		if !slices.Contains(options, value) {
+			return nil, fmt.Errorf("expected one of %v, got: %q", options, value)
+		}
+		return value, nil
+	}
+}
+
 // splitQuoted splits the string s around each instance of one or more consecutive
 // white space characters while taking into account quotes and escaping, and
 // returns an array of substrings of s or an empty list if s contains only white space.
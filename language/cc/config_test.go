@@ -15,8 +15,14 @@
 package cc
 
 import (
+	"bytes"
+	"log"
+	"os"
 	"testing"
 
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
 	"github.com/stretchr/testify/require"
 )
 
@@ -95,3 +101,260 @@ func TestSplitQuoted(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCcSearch(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		args    []string
+		want    []ccSearch
+		wantErr bool
+	}{
+		{
+			name: "positional strip",
+			args: []string{"src/include"},
+			want: []ccSearch{{stripIncludePrefix: "src/include"}},
+		},
+		{
+			name: "positional strip and include",
+			args: []string{"src/include", "api"},
+			want: []ccSearch{{stripIncludePrefix: "src/include", includePrefix: "api"}},
+		},
+		{
+			name:    "positional too many arguments",
+			args:    []string{"a", "b", "c"},
+			wantErr: true,
+		},
+		{
+			name:    "positional not clean",
+			args:    []string{"src/../include"},
+			wantErr: true,
+		},
+		{
+			name: "pair in any order",
+			args: []string{"include=api", "strip=src/include"},
+			want: []ccSearch{{stripIncludePrefix: "src/include", includePrefix: "api"}},
+		},
+		{
+			name: "multiple pairs",
+			args: []string{"strip=src/include", "include=api", "strip=third_party", "include=vendor", "strip=gen"},
+			want: []ccSearch{
+				{stripIncludePrefix: "src/include", includePrefix: "api"},
+				{stripIncludePrefix: "third_party", includePrefix: "vendor"},
+				{stripIncludePrefix: "gen"},
+			},
+		},
+		{
+			name: "repeated key starts a new pair",
+			args: []string{"include=api", "include=internal", "strip=src"},
+			want: []ccSearch{{includePrefix: "api"}, {stripIncludePrefix: "src", includePrefix: "internal"}},
+		},
+		{
+			name:    "unknown key",
+			args:    []string{"strip=src", "prefix=api"},
+			wantErr: true,
+		},
+		{
+			name:    "mixed syntax",
+			args:    []string{"src", "include=api"},
+			wantErr: true,
+		},
+		{
+			name:    "absolute path",
+			args:    []string{"strip=/usr/include"},
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseCcSearch(test.args)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestUnmarshalResolveOverrides(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		data          string
+		want          ccDependencyIndex
+		wantMalformed int
+		wantErr       bool
+	}{
+		{
+			name: "json",
+			data: `{"vendor/zlib.h": "//third_party/zlib", "fmt/core.h": "@fmt//:fmt"}`,
+			want: ccDependencyIndex{
+				"vendor/zlib.h": label.New("", "third_party/zlib", "zlib"),
+				"fmt/core.h":    label.New("fmt", "", "fmt"),
+			},
+		},
+		{
+			name: "text",
+			data: "# comment\n\nvendor/zlib.h //third_party/zlib\n  fmt/./core.h\t@fmt//:fmt  \n",
+			want: ccDependencyIndex{
+				"vendor/zlib.h": label.New("", "third_party/zlib", "zlib"),
+				"fmt/core.h":    label.New("fmt", "", "fmt"),
+			},
+		},
+		{
+			name:          "json_invalid_label",
+			data:          `{"vendor/zlib.h": "//third_party/zlib", "broken.h": "//a:b:c"}`,
+			want:          ccDependencyIndex{"vendor/zlib.h": label.New("", "third_party/zlib", "zlib")},
+			wantMalformed: 1,
+		},
+		{
+			name:          "text_malformed_lines",
+			data:          "vendor/zlib.h //third_party/zlib\nmissing_label.h\ntoo many fields\nbroken.h //a:b:c\n",
+			want:          ccDependencyIndex{"vendor/zlib.h": label.New("", "third_party/zlib", "zlib")},
+			wantMalformed: 3,
+		},
+		{
+			name:    "invalid_json",
+			data:    `{"vendor/zlib.h": `,
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, malformed, err := unmarshalResolveOverrides([]byte(test.data))
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.want, got)
+			require.Len(t, malformed, test.wantMalformed)
+		})
+	}
+}
+
+func TestUnmarshalDependencyIndex(t *testing.T) {
+	expected := ccDependencyIndex{
+		"zlib.h":     label.New("zlib", "", "zlib"),
+		"fmt/core.h": label.New("fmt", "", "fmt"),
+	}
+	for _, test := range []struct {
+		name      string
+		data      string
+		want      ccDependencyIndex
+		wantGraph ccDependencyGraph
+		wantErr   bool
+	}{
+		{
+			name:      "legacy",
+			data:      `{"zlib.h": "@zlib//:zlib", "fmt/core.h": "@fmt//:fmt"}`,
+			want:      expected,
+			wantGraph: ccDependencyGraph{},
+		},
+		{
+			name:      "legacy_with_version_header",
+			data:      `{"version": "@version//:version"}`,
+			want:      ccDependencyIndex{"version": label.New("version", "", "version")},
+			wantGraph: ccDependencyGraph{},
+		},
+		{
+			name: "versioned",
+			data: `{
+  "version": 1,
+  "mappings": {"fmt/core.h": "@fmt//:fmt", "zlib.h": "@zlib//:zlib"},
+  "ambiguous": {"common.h": ["@a//:a", "@b//:b"]}
+}`,
+			want:      expected,
+			wantGraph: ccDependencyGraph{},
+		},
+		{
+			name: "versioned_with_deps",
+			data: `{
+  "version": 1,
+  "mappings": {"fmt/core.h": "@fmt//:fmt", "zlib.h": "@zlib//:zlib"},
+  "ambiguous": {},
+  "deps": {"@fmt//:fmt": ["@zlib//:zlib", "invalid:label:"]}
+}`,
+			want:      expected,
+			wantGraph: ccDependencyGraph{label.New("fmt", "", "fmt"): {label.New("zlib", "", "zlib")}},
+		},
+		{
+			name:    "unsupported_version",
+			data:    `{"version": 2, "mappings": {}}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, gotGraph, err := unmarshalDependencyIndex([]byte(test.data))
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.want, got)
+			require.Equal(t, test.wantGraph, gotGraph)
+		})
+	}
+}
+
+func TestCompileIncludePattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern    string
+		matches    []string
+		notMatches []string
+	}{
+		{
+			pattern:    "gen/*.h",
+			matches:    []string{"gen/config.h"},
+			notMatches: []string{"gen/sub/config.h", "lib/gen/config.h", "gen/config.hpp"},
+		},
+		{
+			pattern:    "**/version.h",
+			matches:    []string{"version.h", "third_party/version.h", "a/b/version.h"},
+			notMatches: []string{"myversion.h", "version.hpp"},
+		},
+		{
+			pattern:    "proto/**",
+			matches:    []string{"proto/a.pb.h", "proto/nested/a.pb.h"},
+			notMatches: []string{"protobuf/a.pb.h"},
+		},
+		{
+			pattern:    "config?.h",
+			matches:    []string{"config1.h"},
+			notMatches: []string{"config.h", "config/.h"},
+		},
+	} {
+		pattern := compileIncludePattern(tc.pattern)
+		for _, path := range tc.matches {
+			require.True(t, pattern.MatchString(path), "%v should match %v", tc.pattern, path)
+		}
+		for _, path := range tc.notMatches {
+			require.False(t, pattern.MatchString(path), "%v should not match %v", tc.pattern, path)
+		}
+	}
+}
+
+func TestConfigureExtensions(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	c := config.New()
+	lang := NewLanguage()
+	rootFile, err := rule.LoadData("BUILD.bazel", "", []byte("# gazelle:cc_extensions source=.cu header=.cuh\n"))
+	require.NoError(t, err)
+	lang.Configure(c, "", rootFile)
+	require.Equal(t, []string{".cu"}, getCcConfig(c).sourceExtensions)
+	require.Equal(t, []string{".cuh"}, getCcConfig(c).headerExtensions)
+
+	// Extensions extend the inherited ones, invalid arguments are reported and skipped
+	c = c.Clone()
+	nestedFile, err := rule.LoadData("gpu/BUILD.bazel", "gpu", []byte("# gazelle:cc_extensions header=.h++ module=.cppm source=cxx\n"))
+	require.NoError(t, err)
+	lang.Configure(c, "gpu", nestedFile)
+	require.Equal(t, []string{".cu"}, getCcConfig(c).sourceExtensions)
+	require.Equal(t, []string{".cuh", ".h++"}, getCcConfig(c).headerExtensions)
+	require.Contains(t, logs.String(), `unknown key "module" would be ignored`)
+	require.Contains(t, logs.String(), `invalid extension "cxx"`)
+	require.True(t, getCcConfig(c).isCSource("kernel.cu"))
+	require.True(t, getCcConfig(c).isCustomHeader("vector.h++"))
+	require.False(t, getCcConfig(c).isCSource("kernel.cuda"))
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// Attributes which elements referring to the same rule using different labels are deduplicated after resolution
+var dedupedAttributes = []string{"deps", "implementation_deps"}
+
+// Rule generated by gazelle_cc which dependencies are deduplicated after generated rules are merged into the build file.
+// Gazelle removes only identical labels when merging, dependencies kept by the user might refer to the resolved ones using a different spelling.
+type dedupedRule struct {
+	from label.Label
+	// Rule that would contain the final dependencies after generated rules are merged into the build file
+	rule *rule.Rule
+	// Name of the main repository, labels referring to it are equivalent to labels without a repository
+	repoName string
+	// Translates module names to apparent names of the repositories defined using bazel_dep
+	moduleToApparentName func(string) string
+}
+
+// Records 'alias' rules defined in the package, dependencies referring to them are equivalent to dependencies on their actual targets
+func (lang *ccLanguage) recordAliases(args language.GenerateArgs) {
+	if args.File == nil {
+		return
+	}
+	for _, r := range args.File.Rules {
+		if r.Kind() != "alias" {
+			continue
+		}
+		actual, err := label.Parse(r.AttrString("actual"))
+		if err != nil {
+			continue
+		}
+		lang.aliases[label.New("", args.Rel, r.Name())] = actual.Abs("", args.Rel)
+	}
+}
+
+// Records generated rules which dependencies should be deduplicated after resolution
+func (lang *ccLanguage) recordDedupedRules(args language.GenerateArgs, generated []*rule.Rule) {
+	for _, genRule := range generated {
+		lang.dedupedRules = append(lang.dedupedRules, dedupedRule{
+			from:                 label.New(args.Config.RepoName, args.Rel, genRule.Name()),
+			rule:                 findMergeTarget(args, genRule),
+			repoName:             args.Config.RepoName,
+			moduleToApparentName: args.Config.ModuleToApparentName,
+		})
+	}
+}
+
+// Returns the label uniquely identifying the rule referred to by the given dependency.
+// Labels referring to the main repository by its name, to modules by their names instead of apparent names and to aliases are normalized
+func (lang *ccLanguage) canonicalLabel(record dedupedRule, dep label.Label) label.Label {
+	visited := make(map[label.Label]bool)
+	for {
+		dep = dep.Abs("", record.from.Pkg)
+		if dep.Repo == record.repoName {
+			dep.Repo = ""
+		} else if dep.Repo != "" && record.moduleToApparentName != nil {
+			if apparentName := record.moduleToApparentName(dep.Repo); apparentName != "" {
+				dep.Repo = apparentName
+			}
+		}
+		dep = label.New(dep.Repo, dep.Pkg, dep.Name)
+		actual, isAlias := lang.aliases[dep]
+		if !isAlias || visited[dep] {
+			return dep
+		}
+		visited[dep] = true
+		dep = actual
+	}
+}
+
+// Removes dependencies referring to the same rule as any of the previous dependencies of the attribute.
+// Dependencies kept by the user using '# keep' comment are never removed, these take precedence over the resolved ones.
+func (lang *ccLanguage) dedupeDeps(record dedupedRule) {
+	for _, attr := range dedupedAttributes {
+		list, ok := record.rule.Attr(attr).(*bzl.ListExpr)
+		if !ok || len(list.List) < 2 || record.rule.ShouldKeep() {
+			continue
+		}
+		canonicalLabels := make([]label.Label, len(list.List))
+		covered := make(map[label.Label]bool)
+		for i, elem := range list.List {
+			str, isString := elem.(*bzl.StringExpr)
+			if !isString {
+				canonicalLabels[i] = label.NoLabel
+				continue
+			}
+			dep, err := label.Parse(str.Value)
+			if err != nil {
+				canonicalLabels[i] = label.NoLabel
+				continue
+			}
+			canonicalLabels[i] = lang.canonicalLabel(record, dep)
+			if rule.ShouldKeep(elem) {
+				covered[canonicalLabels[i]] = true
+			}
+		}
+		deduped := make([]bzl.Expr, 0, len(list.List))
+		for i, elem := range list.List {
+			canonical := canonicalLabels[i]
+			switch {
+			case canonical == label.NoLabel || rule.ShouldKeep(elem):
+				deduped = append(deduped, elem)
+			case !covered[canonical]:
+				covered[canonical] = true
+				deduped = append(deduped, elem)
+			}
+		}
+		if len(deduped) != len(list.List) {
+			list.List = deduped
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// Attributes ordered when using '# gazelle:cc_deps_order depth' or '# gazelle:cc_deps_order internal-first'
+var orderedAttributes = []string{"deps", "implementation_deps"}
+
+// Comment recognized by buildifier, preventing the list assigned to the attribute from being sorted
+const doNotSortComment = "do not sort"
+
+// Rule generated in a package using '# gazelle:cc_deps_order' other than lexical.
+// Gazelle sorts dependencies when merging generated rules into the build file, these are ordered only after merging.
+type orderedRule struct {
+	from  label.Label
+	order depsOrder
+	// Rule that would contain the final dependencies after generated rules are merged into the build file
+	rule *rule.Rule
+	// Dependency graph known in the package defining the rule
+	graph ccDependencyGraph
+}
+
+// Records generated rules which dependencies should be ordered after resolution
+func (lang *ccLanguage) recordOrderedRules(args language.GenerateArgs, generated []*rule.Rule) {
+	conf := getCcConfig(args.Config)
+	if conf.depsOrder == lexicalDepsOrder {
+		return
+	}
+	for _, genRule := range generated {
+		lang.orderedRules = append(lang.orderedRules, orderedRule{
+			from:  label.New(args.Config.RepoName, args.Rel, genRule.Name()),
+			order: conf.depsOrder,
+			rule:  findMergeTarget(args, genRule),
+			graph: conf.dependencyGraph,
+		})
+	}
+}
+
+// Returns the existing rule into which the generated rule would be merged, or the generated rule if it's a new one.
+// Same as Gazelle, rules are matched using both their names and kinds, possibly mapped using 'map_kind' or 'alias_kind'
+func findMergeTarget(args language.GenerateArgs, genRule *rule.Rule) *rule.Rule {
+	if args.File != nil {
+		genKind := resolveCCRuleKind(genRule.Kind(), args.Config)
+		for _, existing := range args.File.Rules {
+			if existing.Name() == genRule.Name() && resolveCCRuleKind(existing.Kind(), args.Config) == genKind {
+				return existing
+			}
+		}
+	}
+	return genRule
+}
+
+// Orders dependencies by their depth in the dependency graph, leaf dependencies first, or places dependencies defined in the same repository before external ones.
+// Dependencies with the same rank are ordered the same as buildifier sorts them, attributes already in that order are not modified.
+// Otherwise the attribute is marked with '# do not sort' comment, preventing gazelle and buildifier from sorting it again.
+func (record orderedRule) orderDeps() {
+	depths := make(map[label.Label]int)
+	rankOf := func(e bzl.Expr) int {
+		dep, err := label.Parse(e.(*bzl.StringExpr).Value)
+		if err != nil {
+			return 0
+		}
+		dep = dep.Abs(record.from.Repo, record.from.Pkg)
+		switch record.order {
+		case internalFirstDepsOrder:
+			if dep.Repo == "" || dep.Repo == record.from.Repo {
+				return 0
+			}
+			return 1
+		default:
+			return record.graph.depth(label.New(dep.Repo, dep.Pkg, dep.Name), depths)
+		}
+	}
+	for _, attr := range orderedAttributes {
+		list, ok := record.rule.Attr(attr).(*bzl.ListExpr)
+		if !ok || len(list.List) < 2 || record.rule.ShouldKeep() || rule.ShouldKeep(&bzl.CommentBlock{Comments: *record.rule.AttrComments(attr)}) {
+			continue
+		}
+		if slices.ContainsFunc(list.List, func(elem bzl.Expr) bool {
+			_, isString := elem.(*bzl.StringExpr)
+			return !isString
+		}) {
+			// Lists containing other expressions are never sorted
+			continue
+		}
+
+		sorted := &bzl.ListExpr{List: slices.Clone(list.List)}
+		bzl.SortStringList(sorted)
+		ordered := slices.Clone(sorted.List)
+		slices.SortStableFunc(ordered, func(a, b bzl.Expr) int {
+			return cmp.Compare(rankOf(a), rankOf(b))
+		})
+		if slices.Equal(ordered, sorted.List) {
+			continue
+		}
+		values := make([]string, len(ordered))
+		for i, elem := range ordered {
+			values[i] = elem.(*bzl.StringExpr).Value
+		}
+		record.rule.SetAttr(attr, rule.UnsortedStrings(values))
+		// Retain comments of the list elements
+		record.rule.Attr(attr).(*bzl.ListExpr).List = ordered
+		comments := record.rule.AttrComments(attr)
+		if !slices.ContainsFunc(comments.Before, func(c bzl.Comment) bool { return strings.Contains(strings.ToLower(c.Token), doNotSortComment) }) {
+			comments.Before = append(comments.Before, bzl.Comment{Token: "# " + doNotSortComment})
+		}
+	}
+}
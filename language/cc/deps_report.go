@@ -0,0 +1,151 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// Attributes compared when creating the dependencies report
+var depsReportAttributes = []string{"deps", "implementation_deps"}
+
+type (
+	// Collects changes of dependencies made by gazelle, written when requested using -cc_deps_report flag
+	depsReport struct {
+		// Path to the report file, the format is selected based on its extension: CSV for '.csv', JSON otherwise
+		file    string
+		records []depsReportRecord
+	}
+	// Snapshot of dependencies of a rule taken before resolution
+	depsReportRecord struct {
+		from label.Label
+		// Rule that would contain the final dependencies after generated rules are merged into the build file
+		rule *rule.Rule
+		// Dependencies assigned to each of the attributes before resolution
+		before map[string][]string
+	}
+	depsReportEntry struct {
+		Rule      string   `json:"rule"`
+		Attribute string   `json:"attribute"`
+		Added     []string `json:"added"`
+		Removed   []string `json:"removed"`
+	}
+)
+
+// Records dependencies of the rules before resolution. Generated rules are merged into existing rules with the same name,
+// so dependencies of existing rules are used as a baseline, newly created rules have no dependencies.
+func (report *depsReport) recordRules(args language.GenerateArgs, generated []*rule.Rule) {
+	for _, genRule := range generated {
+		record := depsReportRecord{
+			from:   label.New(args.Config.RepoName, args.Rel, genRule.Name()),
+			rule:   findMergeTarget(args, genRule),
+			before: make(map[string][]string),
+		}
+		if record.rule != genRule {
+			for _, attr := range depsReportAttributes {
+				record.before[attr] = record.rule.AttrStrings(attr)
+			}
+		}
+		report.records = append(report.records, record)
+	}
+}
+
+// Compares recorded dependencies with the final state of the rules.
+// Returns entries sorted by rule label and attribute name, attributes without changes are skipped.
+func (report *depsReport) entries() []depsReportEntry {
+	entries := []depsReportEntry{}
+	for _, record := range report.records {
+		normalize := func(labels []string) []string {
+			normalized := make([]string, 0, len(labels))
+			for _, l := range labels {
+				if parsed, err := label.Parse(l); err == nil {
+					l = parsed.Rel(record.from.Repo, record.from.Pkg).String()
+				}
+				if !slices.Contains(normalized, l) {
+					normalized = append(normalized, l)
+				}
+			}
+			slices.Sort(normalized)
+			return normalized
+		}
+		for _, attr := range depsReportAttributes {
+			before := normalize(record.before[attr])
+			after := normalize(record.rule.AttrStrings(attr))
+			entry := depsReportEntry{Rule: record.from.String(), Attribute: attr, Added: []string{}, Removed: []string{}}
+			for _, dep := range after {
+				if !slices.Contains(before, dep) {
+					entry.Added = append(entry.Added, dep)
+				}
+			}
+			for _, dep := range before {
+				if !slices.Contains(after, dep) {
+					entry.Removed = append(entry.Removed, dep)
+				}
+			}
+			if len(entry.Added) > 0 || len(entry.Removed) > 0 {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b depsReportEntry) int {
+		if c := strings.Compare(a.Rule, b.Rule); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Attribute, b.Attribute)
+	})
+	return entries
+}
+
+// Serializes the report as CSV if the report file has '.csv' extension or as JSON otherwise
+func (report *depsReport) marshal() ([]byte, error) {
+	entries := report.entries()
+	if strings.EqualFold(filepath.Ext(report.file), ".csv") {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write([]string{"rule", "attribute", "added", "removed"})
+		for _, entry := range entries {
+			writer.Write([]string{entry.Rule, entry.Attribute, strings.Join(entry.Added, " "), strings.Join(entry.Removed, " ")})
+		}
+		writer.Flush()
+		return buf.Bytes(), writer.Error()
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Writes the report to the requested file, failures are reported without interrupting the run
+func (report *depsReport) write() {
+	data, err := report.marshal()
+	if err == nil {
+		err = os.WriteFile(report.file, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("gazelle_cc: failed to write cc dependencies report %v: %v", report.file, err)
+	}
+}
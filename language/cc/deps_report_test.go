@@ -0,0 +1,129 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepsReport(t *testing.T) {
+	existingFile, err := rule.LoadData("pkg/BUILD.bazel", "pkg", []byte(`
+cc_library(
+    name = "lib",
+    srcs = ["lib.cc"],
+    hdrs = ["lib.h"],
+    implementation_deps = ["//pkg/impl"],
+    deps = [
+        ":stale",
+        "//common:common",
+    ],
+)
+`))
+	require.NoError(t, err)
+
+	args := language.GenerateArgs{Config: &config.Config{}, Rel: "pkg", File: existingFile}
+	generatedLib := rule.NewRule("cc_library", "lib")
+	generatedTool := rule.NewRule("cc_binary", "tool")
+
+	for _, tc := range []struct {
+		clue     string
+		file     string
+		expected string
+	}{
+		{
+			clue: "JSON report",
+			file: "report.json",
+			expected: `[
+  {
+    "rule": "//pkg:lib",
+    "attribute": "deps",
+    "added": [
+      "//util"
+    ],
+    "removed": [
+      ":stale"
+    ]
+  },
+  {
+    "rule": "//pkg:tool",
+    "attribute": "deps",
+    "added": [
+      ":lib"
+    ],
+    "removed": []
+  }
+]
+`,
+		},
+		{
+			clue: "CSV report",
+			file: "report.csv",
+			expected: `rule,attribute,added,removed
+//pkg:lib,deps,//util,:stale
+//pkg:tool,deps,:lib,
+`,
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			report := depsReport{file: tc.file}
+			report.recordRules(args, []*rule.Rule{generatedLib, generatedTool})
+
+			// Simulate results of resolution merged into the build file
+			existingLib := existingFile.Rules[0]
+			defer existingLib.SetAttr("deps", []string{":stale", "//common:common"})
+			existingLib.SetAttr("deps", []string{"//common", "//util"})
+			generatedTool.SetAttr("deps", []string{":lib"})
+
+			data, err := report.marshal()
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestDepsReportMatchesExistingRulesByKind(t *testing.T) {
+	existingFile, err := rule.LoadData("pkg/BUILD.bazel", "pkg", []byte(`
+cc_test(
+    name = "tool",
+    deps = [":stale"],
+)
+
+my_library(
+    name = "lib",
+    deps = ["//common"],
+)
+`))
+	require.NoError(t, err)
+	c := &config.Config{KindMap: map[string]config.MappedKind{
+		"cc_library": {FromKind: "cc_library", KindName: "my_library"},
+	}}
+	args := language.GenerateArgs{Config: c, Rel: "pkg", File: existingFile}
+	generatedTool := rule.NewRule("cc_binary", "tool")
+	generatedLib := rule.NewRule("cc_library", "lib")
+
+	report := depsReport{file: "report.csv"}
+	report.recordRules(args, []*rule.Rule{generatedTool, generatedLib})
+	// Rule of another kind is not merged with the generated one, its dependencies are not used as the baseline
+	require.Same(t, generatedTool, report.records[0].rule)
+	require.Empty(t, report.records[0].before["deps"])
+	// Rule of the mapped kind is merged with the generated one
+	require.Same(t, existingFile.Rules[1], report.records[1].rule)
+	require.Equal(t, []string{"//common"}, report.records[1].before["deps"])
+}
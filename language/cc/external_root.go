@@ -0,0 +1,131 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// Indexes headers of the directory located in the root marked using '# gazelle:cc_external_root', instead of generating rules.
+// Headers are assigned to existing rules of the closest package listing them in 'hdrs' or 'textual_hdrs', either explicitly or using glob() patterns,
+// under all the paths they can be included with. Headers of subdirectories without a build file are indexed together with their package,
+// these are visited before their parent. The index is consulted when resolving includes of rules defined in the rest of the repository.
+// Only directories visited during the current run are indexed, the root is not walked independently of Gazelle.
+func (c *ccLanguage) indexExternalRoot(args language.GenerateArgs) {
+	headers := []string{}
+	for _, fileName := range args.RegularFiles {
+		if hasMatchingExtension(fileName, headerExtensions) || hasMatchingExtension(fileName, textualHeaderExtensions) {
+			headers = append(headers, path.Join(args.Rel, fileName))
+		}
+	}
+	if args.File == nil {
+		c.externalRootHeaders[args.Rel] = headers
+		return
+	}
+	for _, rel := range slices.Sorted(maps.Keys(c.externalRootHeaders)) {
+		if args.Rel == "" || strings.HasPrefix(rel, args.Rel+"/") {
+			headers = append(headers, c.externalRootHeaders[rel]...)
+			delete(c.externalRootHeaders, rel)
+		}
+	}
+	for _, r := range args.File.Rules {
+		if resolveCCRuleKind(r.Kind(), args.Config) != "cc_library" {
+			continue
+		}
+		from := label.New(args.Config.RepoName, args.Rel, r.Name())
+		stripIncludePrefix := r.AttrString("strip_include_prefix")
+		if stripIncludePrefix != "" {
+			stripIncludePrefix = path.Clean(stripIncludePrefix)
+		}
+		includePrefix := r.AttrString("include_prefix")
+		if includePrefix != "" {
+			includePrefix = path.Clean(includePrefix)
+		}
+		includeDirs := r.AttrStrings("includes")
+		for _, hdrRel := range headers {
+			file := strings.TrimPrefix(hdrRel, args.Rel+"/")
+			if !listsFile(r.Attr("hdrs"), file) && !listsFile(r.Attr("textual_hdrs"), file) {
+				continue
+			}
+			for _, include := range possibleIncludePaths(args.Rel, stripIncludePrefix, includePrefix, includeDirs, hdrRel) {
+				// Headers exposed by multiple rules are assigned to the first of them
+				if _, exists := c.externalRootIndex[include]; !exists {
+					c.externalRootIndex[include] = from
+				}
+			}
+		}
+	}
+}
+
+// Checks if the file, relative to the package, is listed in the attribute, either explicitly or using glob() patterns.
+// Lists concatenated with other expressions are inspected, remaining expressions, e.g. select(), are ignored.
+func listsFile(expr bzl.Expr, file string) bool {
+	switch expr := expr.(type) {
+	case *bzl.ListExpr:
+		for _, elem := range expr.List {
+			if str, ok := elem.(*bzl.StringExpr); ok && strings.TrimPrefix(str.Value, ":") == file {
+				return true
+			}
+		}
+	case *bzl.BinaryExpr:
+		return expr.Op == "+" && (listsFile(expr.X, file) || listsFile(expr.Y, file))
+	case *bzl.CallExpr:
+		if ident, ok := expr.X.(*bzl.Ident); !ok || ident.Name != "glob" {
+			return false
+		}
+		var include, exclude []string
+		for i, arg := range expr.List {
+			if assign, ok := arg.(*bzl.AssignExpr); ok {
+				if key, ok := assign.LHS.(*bzl.Ident); ok && key.Name == "include" {
+					include = globPatterns(assign.RHS)
+				} else if ok && key.Name == "exclude" {
+					exclude = globPatterns(assign.RHS)
+				}
+				continue
+			}
+			switch i {
+			case 0:
+				include = globPatterns(arg)
+			case 1:
+				exclude = globPatterns(arg)
+			}
+		}
+		matches := func(pattern string) bool { return compileIncludePattern(pattern).MatchString(file) }
+		return slices.ContainsFunc(include, matches) && !slices.ContainsFunc(exclude, matches)
+	}
+	return false
+}
+
+// Returns string literals of the list of glob() patterns
+func globPatterns(expr bzl.Expr) []string {
+	list, ok := expr.(*bzl.ListExpr)
+	if !ok {
+		return nil
+	}
+	patterns := []string{}
+	for _, elem := range list.List {
+		if str, ok := elem.(*bzl.StringExpr); ok {
+			patterns = append(patterns, str.Value)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListsFile(t *testing.T) {
+	file, err := rule.LoadData("BUILD.bazel", "", []byte(`
+cc_library(
+    name = "explicit",
+    hdrs = ["a.h", ":b.h"],
+)
+
+cc_library(
+    name = "glob",
+    hdrs = glob(["include/**/*.h"], exclude = ["include/internal/*.h"]),
+)
+
+cc_library(
+    name = "concat",
+    hdrs = ["c.h"] + glob(include = ["*.inc"]) + select({"//conditions:default": ["d.h"]}),
+)
+`))
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		rule     string
+		file     string
+		expected bool
+	}{
+		{rule: "explicit", file: "a.h", expected: true},
+		{rule: "explicit", file: "b.h", expected: true},
+		{rule: "explicit", file: "c.h", expected: false},
+		{rule: "glob", file: "include/foo.h", expected: true},
+		{rule: "glob", file: "include/foo/bar.h", expected: true},
+		{rule: "glob", file: "include/internal/impl.h", expected: false},
+		{rule: "glob", file: "include/internal/nested/impl.h", expected: true},
+		{rule: "glob", file: "src/foo.h", expected: false},
+		{rule: "concat", file: "c.h", expected: true},
+		{rule: "concat", file: "defs.inc", expected: true},
+		{rule: "concat", file: "nested/defs.inc", expected: false},
+		// Values of select() depend on the configuration, these are never evaluated
+		{rule: "concat", file: "d.h", expected: false},
+	} {
+		t.Run(tc.rule+"/"+tc.file, func(t *testing.T) {
+			var r *rule.Rule
+			for _, candidate := range file.Rules {
+				if candidate.Name() == tc.rule {
+					r = candidate
+				}
+			}
+			require.Equal(t, tc.expected, listsFile(r.Attr("hdrs"), tc.file))
+		})
+	}
+}
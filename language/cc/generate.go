@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"fmt"
 	"log"
 	"maps"
 	"path"
@@ -32,14 +33,39 @@ import (
 )
 
 func (c *ccLanguage) GenerateRules(args language.GenerateArgs) language.GenerateResult {
+	conf := getCcConfig(args.Config)
+	c.recordAliases(args)
+	if conf.externalRoot {
+		// Rules are managed by the user, these are only indexed
+		c.indexExternalRoot(args)
+		return language.GenerateResult{}
+	}
+	if conf.groupingMode == groupSourcesByUnitGlobal && args.File == nil {
+		// Directory is not a package, its sources are defined in rules of the closest enclosing package
+		c.unitGlobalSources[args.Rel] = collectSourceInfos(args)
+		return language.GenerateResult{}
+	}
 	srcInfo := collectSourceInfos(args)
+	if conf.groupingMode == groupSourcesByUnitGlobal {
+		c.includeUnitGlobalSources(args, &srcInfo)
+	}
 	rulesInfo := extractRulesInfo(args)
+	srcInfo.excludeSources(rulesInfo.externalSources)
+	srcInfo.keepTextualHeaders(rulesInfo.textualHeaders)
+	if conf.duplicateSources == errorOnDuplicateSources {
+		c.duplicateSourceErrors += rulesInfo.duplicatedSources
+	}
+
+	c.reportNewTemplateHeaders(srcInfo, rulesInfo)
 
 	var result = language.GenerateResult{}
-	consumedProtoFiles := c.generateProtoLibraryRules(args, rulesInfo, &result)
-	c.generateLibraryRules(args, srcInfo, rulesInfo, consumedProtoFiles, &result)
+	consumedSources := c.generateProtoLibraryRules(args, rulesInfo, &result)
+	maps.Copy(consumedSources, c.generateImportRules(args, srcInfo, rulesInfo, &result))
+	c.generateLibraryRules(args, srcInfo, rulesInfo, consumedSources, &result)
+	c.generateCudaRules(args, srcInfo, rulesInfo, &result)
 	c.generateBinaryRules(args, srcInfo, rulesInfo, &result)
 	c.generateTestRules(args, srcInfo, rulesInfo, &result)
+	rulesInfo.dropRulesManagedExternally(args, &result)
 
 	// None of the rules generated above can be empty - it's guaranteed by generating them only if sources exists
 	// However we need to inspect for existing rules that are no longer matching any files
@@ -47,33 +73,65 @@ func (c *ccLanguage) GenerateRules(args language.GenerateArgs) language.Generate
 
 	result.RelsToIndex = c.listRelsToIndex(args, srcInfo)
 
+	if c.depsReport != nil {
+		c.depsReport.recordRules(args, result.Gen)
+	}
+	c.recordDedupedRules(args, result.Gen)
+	c.recordOrderedRules(args, result.Gen)
+	c.recordIncludeRoots(args, result.Gen)
+
 	return result
 }
 
-func extractImports(args language.GenerateArgs, files []sourceFile, sourceInfos map[sourceFile]parser.SourceInfo) ccImports {
+func extractImports(args language.GenerateArgs, files []sourceFile, srcInfo ccSourceInfoSet) ccImports {
+	conf := getCcConfig(args.Config)
 	imports := ccImports{}
 	for _, file := range files {
 		var includes *[]ccInclude
-		if file.isHeader() {
-			includes = &imports.hdrIncludes
+		var namespaces *[]string
+		if srcInfo.isHeader(file) {
+			includes, namespaces = &imports.hdrIncludes, &imports.hdrNamespaces
 		} else {
-			includes = &imports.srcIncludes
+			includes, namespaces = &imports.srcIncludes, &imports.srcNamespaces
 		}
-
-		sourceInfo := sourceInfos[file]
-		for _, include := range sourceInfo.Includes.DoubleQuote {
-			rawPath := path.Clean(include)
-			*includes = append(*includes, ccInclude{rawPath: rawPath, normalizedPath: path.Join(args.Rel, rawPath), isSystemInclude: false})
+		if len(conf.namespaceDeps) > 0 {
+			*namespaces = append(*namespaces, srcInfo.sourceInfos[file].UsedNamespaces...)
 		}
-		for _, include := range sourceInfo.Includes.Bracket {
-			*includes = append(*includes, ccInclude{rawPath: include, normalizedPath: include, isSystemInclude: true})
+
+		for _, location := range srcInfo.sourceInfos[file].IncludeLocations {
+			include := ccInclude{
+				rawPath:         location.Path,
+				normalizedPath:  location.Path,
+				isSystemInclude: location.IsSystem,
+				location:        fmt.Sprintf("%v:%d", file, location.Line),
+				isCSource:       path.Ext(string(file)) == ".c",
+			}
+			if !location.IsSystem && !isLabelInclude(location.Path) {
+				include.rawPath = path.Clean(location.Path)
+				include.normalizedPath = path.Join(path.Dir(file.stringValue()), include.rawPath)
+			}
+			if conf.isSuppressedInclude(include) {
+				continue
+			}
+			if srcInfo.isOwnHeaderInclude(file, location.Path) {
+				// Paired header is always assigned to the same rule, independently of how it's spelled
+				continue
+			}
+			// Headers generated by other rules in this package are provided by the generating rule
+			if generator, ok := srcInfo.generatedHeaders[sourceFile(include.normalizedPath)]; ok && !location.IsSystem {
+				if !slices.Contains(imports.deps, generator) {
+					imports.deps = append(imports.deps, generator)
+				}
+				continue
+			}
+			*includes = append(*includes, include)
 		}
 	}
 
 	return imports
 }
 
-func splitSourcesIntoGroups(args language.GenerateArgs, srcs []sourceFile, srcInfo ccSourceInfoSet) sourceGroups {
+func splitSourcesIntoGroups(args language.GenerateArgs, srcs []sourceFile, srcInfo ccSourceInfoSet, rulesInfo rulesInfo) sourceGroups {
 	conf := getCcConfig(args.Config)
 	var srcGroups sourceGroups
 	switch conf.groupingMode {
@@ -81,8 +139,20 @@ func splitSourcesIntoGroups(args language.GenerateArgs, srcs []sourceFile, srcIn
 		// All sources grouped together
 		groupName := groupId(filepath.Base(args.Dir))
 		srcGroups = sourceGroups{groupName: {sources: srcs}}
-	case groupSourcesByUnit:
-		srcGroups = groupSourcesByUnits(srcs, srcInfo.sourceInfos)
+	case groupSourcesByUnit, groupSourcesByUnitGlobal, groupSourcesByUnitHeaderMerge:
+		assignedSources := make(sourceFileSet)
+		for _, ruleSources := range rulesInfo.ccRuleSources {
+			maps.Copy(assignedSources, ruleSources)
+		}
+		var searches []ccSearch
+		if conf.groupingMode == groupSourcesByUnitGlobal {
+			// Headers defined in other directories are typically included using paths translated by 'cc_search' directives
+			searches = conf.ccSearch
+		}
+		srcGroups = groupSourcesByUnits(srcs, srcInfo.sourceInfos, conf.orphanUnitsMode == mergeOrphanUnits, assignedSources, searches)
+		if conf.groupingMode == groupSourcesByUnitHeaderMerge {
+			srcGroups.mergeHeaderOnlyClusters()
+		}
 	}
 	return srcGroups
 }
@@ -108,11 +178,114 @@ func newOrExistingRule(kind string, ruleName string, srcGroups sourceGroups, rul
 	return newRule
 }
 
+// Reports newly generated rule which name collides with one of the names defined using 'cc_reserved_names' or with a symbol loaded in the build file.
+// The rule is renamed using the suffix defined using 'cc_reserved_name_suffix', if any. Existing rules are never renamed.
+func checkReservedRuleName(args language.GenerateArgs, rulesInfo rulesInfo, r *rule.Rule) {
+	if _, exists := rulesInfo.definedRules[r.Name()]; exists {
+		return
+	}
+	conf := getCcConfig(args.Config)
+	var collision string
+	if slices.Contains(conf.reservedNames, r.Name()) {
+		collision = "a reserved name"
+	} else if args.File != nil {
+		for _, load := range args.File.Loads {
+			if slices.Contains(load.Symbols(), r.Name()) {
+				collision = fmt.Sprintf("a symbol loaded from %v", load.Name())
+				break
+			}
+		}
+	}
+	if collision == "" {
+		return
+	}
+	from := label.New("", args.Rel, r.Name())
+	if conf.reservedNameSuffix == "" {
+		log.Printf("%v: name of the generated rule collides with %v, rename it or set '# gazelle:%v <suffix>' to rename such rules automatically", from, collision, cc_reserved_name_suffix)
+		return
+	}
+	log.Printf("%v: name of the generated rule collides with %v, it would be named '%v'", from, collision, r.Name()+conf.reservedNameSuffix)
+	r.SetName(r.Name() + conf.reservedNameSuffix)
+}
+
+// Assigns visibility defined using 'cc_default_visibility' directive to the generated rule, unless the build file defines the default visibility of the package.
+// Libraries are public when the directive is not used, other kinds of rules use the default visibility of the package.
+func setDefaultVisibility(args language.GenerateArgs, r *rule.Rule, isLibrary bool) {
+	if args.File != nil && args.File.HasDefaultVisibility() {
+		return
+	}
+	visibility := getCcConfig(args.Config).defaultVisibility
+	if len(visibility) == 0 {
+		if !isLibrary {
+			return
+		}
+		visibility = []string{"//visibility:public"}
+	}
+	r.SetAttr("visibility", visibility)
+}
+
+// Assigns strip_include_prefix and include_prefix attributes to the generated library when enabled using 'cc_emit_include_prefix' directive,
+// so that its headers are available under the same paths as searched using 'cc_search' directives.
+// Headers located in a single subdirectory, e.g. grouped using '# gazelle:cc_group unit-global', are searched in that subdirectory.
+func setIncludePrefixes(args language.GenerateArgs, r *rule.Rule) {
+	conf := getCcConfig(args.Config)
+	if !conf.emitIncludePrefix {
+		return
+	}
+	hdrsDir := ""
+	for _, hdr := range slices.Concat(r.AttrStrings("hdrs"), r.AttrStrings("textual_hdrs")) {
+		if dir := path.Dir(hdr); hdrsDir == "" || hdrsDir == dir {
+			hdrsDir = dir
+		} else {
+			hdrsDir = "."
+			break
+		}
+	}
+	stripIncludePrefix, includePrefix, ok := inferIncludePrefixes(conf.ccSearch, path.Join(args.Rel, hdrsDir))
+	if !ok {
+		return
+	}
+	if stripIncludePrefix != "" {
+		r.SetAttr("strip_include_prefix", stripIncludePrefix)
+	}
+	if includePrefix != "" {
+		r.SetAttr("include_prefix", includePrefix)
+	}
+}
+
+// Infers strip_include_prefix and include_prefix attributes of libraries defined in the package based on 'cc_search' directives.
+// A search rule applies to packages located in the directory it adds to include paths. Headers found in that directory are included
+// using the prefix stripped by the rule, which is the opposite of how library attributes transform the paths.
+// Returns false if none or multiple distinct rules apply to the package.
+func inferIncludePrefixes(searches []ccSearch, rel string) (stripIncludePrefix string, includePrefix string, ok bool) {
+	var found *ccSearch
+	for _, search := range searches {
+		if search == (ccSearch{}) {
+			continue // Includes are already relative to the repository root
+		}
+		if search.includePrefix != "" && rel != search.includePrefix && !strings.HasPrefix(rel, search.includePrefix+"/") {
+			continue
+		}
+		if found != nil && *found != search {
+			return "", "", false
+		}
+		found = &search
+	}
+	if found == nil {
+		return "", "", false
+	}
+	// When only include_prefix is set, Bazel strips the path of the package
+	if rel == found.includePrefix && found.stripIncludePrefix != "" {
+		return "", found.stripIncludePrefix, true
+	}
+	return "/" + found.includePrefix, found.stripIncludePrefix, true
+}
+
 func (c *ccLanguage) generateLibraryRules(args language.GenerateArgs, srcInfo ccSourceInfoSet, rulesInfo rulesInfo, excludedSources sourceFileSet, result *language.GenerateResult) {
 	conf := getCcConfig(args.Config)
 	// Ignore files that might have been consumed by other rules
 	allSrcs := []sourceFile{}
-	for _, file := range slices.Concat(srcInfo.srcs, srcInfo.hdrs) {
+	for _, file := range slices.Concat(srcInfo.srcs, srcInfo.hdrs, srcInfo.textualHdrs) {
 		if isExcluded := excludedSources[file]; !isExcluded {
 			allSrcs = append(allSrcs, file)
 		}
@@ -120,13 +293,35 @@ func (c *ccLanguage) generateLibraryRules(args language.GenerateArgs, srcInfo cc
 	if len(allSrcs) == 0 {
 		return
 	}
-	srcGroups := splitSourcesIntoGroups(args, allSrcs, srcInfo)
-	ambigiousRuleAssignments := srcGroups.adjustToExistingRules(rulesInfo)
+	srcGroups := splitSourcesIntoGroups(args, allSrcs, srcInfo, rulesInfo)
+	var pinnedGroupId groupId
+	if conf.libraryName != "" && conf.groupingMode == groupSourcesByDirectory {
+		// All sources are grouped together, the library is named using 'cc_library_name' directive
+		pinnedGroupId = groupId(conf.libraryName)
+		for _, id := range srcGroups.groupIds() {
+			srcGroups.renameOrMergeWith(id, pinnedGroupId)
+		}
+	}
+	ambigiousRuleAssignments, replacedRules := srcGroups.adjustToExistingRules(rulesInfo, pinnedGroupId)
+	for _, ruleName := range replacedRules {
+		existing := rulesInfo.definedRules[ruleName]
+		if resolveCCRuleKind(existing.Kind(), args.Config) != "cc_library" {
+			continue
+		}
+		c.explainRemovedRule(args, ruleName, "its sources %v were moved to rule '%v' named using '# gazelle:%v'",
+			toRelativePaths(args.Rel, slices.Sorted(maps.Keys(rulesInfo.ccRuleSources[ruleName]))), pinnedGroupId, cc_library_name)
+		result.Empty = append(result.Empty, rule.NewRule(existing.Kind(), ruleName))
+	}
 
 	for _, groupId := range srcGroups.groupIds() {
 		group := srcGroups[groupId]
 		ruleName := string(groupId)
 		newRule := newOrExistingRule("cc_library", ruleName, srcGroups, rulesInfo, args)
+		if groupId == pinnedGroupId && newRule.Name() != ruleName {
+			// Existing rule with a different name is replaced instead of being reused
+			newRule = rule.NewRule("cc_library", ruleName)
+		}
+		checkReservedRuleName(args, rulesInfo, newRule)
 
 		// Deal with rules that conflict with existing defintions
 		if ambigiousRuleAssignments, exists := ambigiousRuleAssignments[groupId]; exists {
@@ -136,42 +331,205 @@ func (c *ccLanguage) generateLibraryRules(args language.GenerateArgs, srcInfo cc
 		}
 
 		// Assign sources to gorups
-		srcs, hdrs := partitionCSources(group.sources)
+		srcs, hdrs, textualHdrs := srcInfo.partitionSources(group.sources)
+		if conf.splitHeaders && len(srcs) > 0 && len(hdrs) > 0 {
+			// Public headers are defined in a dedicated header-only library, the implementation library depends on it
+			headersRule := rule.NewRule(newRule.Kind(), newRule.Name()+splitHeadersRuleSuffix)
+			headersRule.SetAttr("hdrs", toRelativePaths(args.Rel, hdrs))
+			if len(textualHdrs) > 0 {
+				headersRule.SetAttr("textual_hdrs", toRelativePaths(args.Rel, textualHdrs))
+			}
+			setIncludePrefixes(args, headersRule)
+			setDefaultVisibility(args, headersRule, true)
+			result.Gen = append(result.Gen, headersRule)
+			result.Imports = append(result.Imports, extractImports(args, slices.Concat(hdrs, textualHdrs), srcInfo))
+
+			newRule.SetAttr("srcs", toRelativePaths(args.Rel, srcs))
+			setDefaultVisibility(args, newRule, true)
+			imports := extractImports(args, srcs, srcInfo)
+			imports.deps = append(imports.deps, label.New("", args.Rel, headersRule.Name()))
+			result.Gen = append(result.Gen, newRule)
+			result.Imports = append(result.Imports, imports)
+			continue
+		}
 		if len(srcs) > 0 {
 			newRule.SetAttr("srcs", toRelativePaths(args.Rel, srcs))
 		}
 		if len(hdrs) > 0 {
 			newRule.SetAttr("hdrs", toRelativePaths(args.Rel, hdrs))
 		}
-		if args.File == nil || !args.File.HasDefaultVisibility() {
-			newRule.SetAttr("visibility", []string{"//visibility:public"})
+		if len(textualHdrs) > 0 {
+			newRule.SetAttr("textual_hdrs", toRelativePaths(args.Rel, textualHdrs))
+		}
+		if len(hdrs) > 0 || len(textualHdrs) > 0 {
+			setIncludePrefixes(args, newRule)
 		}
+		setDefaultVisibility(args, newRule, true)
+
+		result.Gen = append(result.Gen, newRule)
+		result.Imports = append(result.Imports, extractImports(args, group.sources, srcInfo))
+	}
+}
+
+// Generates a single rule of kind selected using 'cc_cuda_kind' directive defining all CUDA sources of the package, named after the directory
+func (c *ccLanguage) generateCudaRules(args language.GenerateArgs, srcInfo ccSourceInfoSet, rulesInfo rulesInfo, result *language.GenerateResult) {
+	conf := getCcConfig(args.Config)
+	allSrcs := slices.Concat(srcInfo.cudaSrcs, srcInfo.cudaHdrs)
+	if conf.cudaKind == "" || len(allSrcs) == 0 {
+		return
+	}
+	ruleName := filepath.Base(args.Dir) + cudaRuleSuffix
+	srcGroups := sourceGroups{groupId(ruleName): {sources: allSrcs}}
+	newRule := newOrExistingRule(conf.cudaKind, ruleName, srcGroups, rulesInfo, args)
+	checkReservedRuleName(args, rulesInfo, newRule)
+	if len(srcInfo.cudaSrcs) > 0 {
+		newRule.SetAttr("srcs", toRelativePaths(args.Rel, srcInfo.cudaSrcs))
+	}
+	if len(srcInfo.cudaHdrs) > 0 {
+		newRule.SetAttr("hdrs", toRelativePaths(args.Rel, srcInfo.cudaHdrs))
+		setIncludePrefixes(args, newRule)
+	}
+	setDefaultVisibility(args, newRule, true)
+	result.Gen = append(result.Gen, newRule)
+	result.Imports = append(result.Imports, extractImports(args, allSrcs, srcInfo))
+}
 
+// Attributes of cc_import rule referring to prebuilt libraries
+var importArtifactAttrs = []string{"static_library", "shared_library", "interface_library"}
+
+// Generates cc_import rules for prebuilt libraries when enabled using 'cc_import' directive, only in directories without sources to compile.
+// Libraries sharing the same name, e.g. libfoo.a and libfoo.so, are defined in a single rule. Headers are assigned to the rule only if there is exactly one of them.
+// Returns a set of headers assigned to the generated or existing cc_import rules that should be excluded from cc_library rules
+func (c *ccLanguage) generateImportRules(args language.GenerateArgs, srcInfo ccSourceInfoSet, rulesInfo rulesInfo, result *language.GenerateResult) sourceFileSet {
+	consumedHeaders := make(sourceFileSet)
+	// Headers of existing cc_import rules are provided together with prebuilt libraries, these are never assigned to cc_library rules
+	for ruleName, r := range rulesInfo.definedRules {
+		if resolveCCRuleKind(r.Kind(), args.Config) == "cc_import" {
+			maps.Copy(consumedHeaders, rulesInfo.ccRuleSources[ruleName])
+		}
+	}
+	if !getCcConfig(args.Config).generateImports || len(srcInfo.importArtifacts) == 0 {
+		return consumedHeaders
+	}
+	if len(srcInfo.srcs) > 0 || len(srcInfo.mainSrcs) > 0 || len(srcInfo.testSrcs) > 0 {
+		// Prebuilt libraries are most likely outputs of building the sources
+		return consumedHeaders
+	}
+	artifactsByName := make(map[string][]sourceFile)
+	for _, artifact := range srcInfo.importArtifacts {
+		name := strings.TrimPrefix(artifact.baseName(), "lib")
+		artifactsByName[name] = append(artifactsByName[name], artifact)
+	}
+	for _, name := range slices.Sorted(maps.Keys(artifactsByName)) {
+		artifacts := artifactsByName[name]
+		slices.Sort(artifacts)
+		ruleName := name
+		for _, artifact := range artifacts {
+			if existingRule, exists := rulesInfo.groupAssignment[artifact.toGroupId()]; exists {
+				ruleName = existingRule
+				break
+			}
+		}
+		newRule := rule.NewRule("cc_import", ruleName)
+		hasDll := slices.ContainsFunc(artifacts, func(artifact sourceFile) bool { return path.Ext(artifact.stringValue()) == ".dll" })
+		for _, artifact := range artifacts {
+			var attr string
+			switch path.Ext(artifact.stringValue()) {
+			case ".a":
+				attr = "static_library"
+			case ".lib":
+				// On Windows '.lib' is either a static library or an interface library of a DLL
+				if hasDll {
+					attr = "interface_library"
+				} else {
+					attr = "static_library"
+				}
+			default:
+				attr = "shared_library"
+			}
+			if newRule.Attr(attr) != nil {
+				log.Printf("%v: multiple prebuilt libraries named %v would be assigned to %v attribute, %v is ignored", args.Rel, name, attr, artifact)
+				continue
+			}
+			newRule.SetAttr(attr, toRelativePaths(args.Rel, []sourceFile{artifact})[0])
+		}
+		imports := ccImports{}
+		if len(artifactsByName) == 1 && len(srcInfo.hdrs) > 0 {
+			newRule.SetAttr("hdrs", toRelativePaths(args.Rel, srcInfo.hdrs))
+			imports = extractImports(args, srcInfo.hdrs, srcInfo)
+			for _, hdr := range srcInfo.hdrs {
+				consumedHeaders[hdr] = true
+			}
+		}
+		setDefaultVisibility(args, newRule, true)
 		result.Gen = append(result.Gen, newRule)
-		result.Imports = append(result.Imports, extractImports(args, group.sources, srcInfo.sourceInfos))
+		result.Imports = append(result.Imports, imports)
 	}
+	return consumedHeaders
 }
 
 func (c *ccLanguage) generateBinaryRules(args language.GenerateArgs, srcInfo ccSourceInfoSet, rulesInfo rulesInfo, result *language.GenerateResult) {
 	srcGroups := identitySourceGroups(srcInfo.mainSrcs)
+	singleBinaryRule := getCcConfig(args.Config).binaryGroupingMode == singleBinary
+	var libraryDeps []label.Label
+	if singleBinaryRule && len(srcInfo.mainSrcs) > 0 {
+		// All entrypoints are compiled into a rule named after the directory, unless this name is already used by the library
+		name := filepath.Base(args.Dir)
+		if slices.ContainsFunc(result.Gen, func(r *rule.Rule) bool { return r.Name() == name }) {
+			name += "_bin"
+		}
+		srcGroups = sourceGroups{groupId(name): {sources: srcInfo.mainSrcs}}
+		// Entrypoints might refer to symbols defined in the remaining sources of the directory without including their headers,
+		// the binary depends on the libraries compiling them
+		for _, r := range result.Gen {
+			if resolveCCRuleKind(r.Kind(), args.Config) != "cc_library" {
+				continue
+			}
+			if slices.ContainsFunc(r.AttrStrings("srcs"), func(src string) bool { return slices.Contains(srcInfo.srcs, newSourceFile(args.Rel, src)) }) {
+				libraryDeps = append(libraryDeps, label.New(args.Config.RepoName, args.Rel, r.Name()))
+			}
+		}
+	}
 	for _, groupId := range srcGroups.groupIds() {
 		group := srcGroups[groupId]
 		ruleName := group.sources[0].baseName()
+		if singleBinaryRule {
+			ruleName = string(groupId)
+		}
 		newRule := newOrExistingRule("cc_binary", ruleName, srcGroups, rulesInfo, args)
+		checkReservedRuleName(args, rulesInfo, newRule)
 		newRule.SetAttr("srcs", toRelativePaths(args.Rel, group.sources))
+		setDefaultVisibility(args, newRule, false)
+		imports := extractImports(args, group.sources, srcInfo)
+		imports.deps = append(imports.deps, libraryDeps...)
 		result.Gen = append(result.Gen, newRule)
-		result.Imports = append(result.Imports, extractImports(args, group.sources, srcInfo.sourceInfos))
+		result.Imports = append(result.Imports, imports)
 	}
 }
 
 func (c *ccLanguage) generateTestRules(args language.GenerateArgs, srcInfo ccSourceInfoSet, rulesInfo rulesInfo, result *language.GenerateResult) {
-	if len(srcInfo.testSrcs) == 0 {
+	// Sources with inlined tests are built in dedicated rules, they're already a part of a library
+	// Headers classified as tests, e.g. mocks, are defined in a testonly library that tests depend on
+	testSrcs, inlineTestSrcs, testHdrs := []sourceFile{}, []sourceFile{}, []sourceFile{}
+	for _, src := range srcInfo.testSrcs {
+		switch {
+		case srcInfo.isInlineTestSource(src):
+			inlineTestSrcs = append(inlineTestSrcs, src)
+		case src.isHeader():
+			testHdrs = append(testHdrs, src)
+		default:
+			testSrcs = append(testSrcs, src)
+		}
+	}
+	c.generateInlineTestRules(args, srcInfo, inlineTestSrcs, result)
+	c.generateTestOnlyLibraryRule(args, srcInfo, rulesInfo, testHdrs, result)
+	if len(testSrcs) == 0 {
 		return
 	}
-	// TODO: group tests by framework (unlikely but possible)
 	conf := getCcConfig(args.Config)
-	srcGroups := splitSourcesIntoGroups(args, srcInfo.testSrcs, srcInfo)
-	ambigiousRuleAssignments := srcGroups.adjustToExistingRules(rulesInfo)
+	srcGroups := splitSourcesIntoGroups(args, testSrcs, srcInfo, rulesInfo)
+	srcGroups.splitByTestFramework(args, srcInfo)
+	ambigiousRuleAssignments, _ := srcGroups.adjustToExistingRules(rulesInfo, "")
 
 	for _, groupId := range srcGroups.groupIds() {
 		group := srcGroups[groupId]
@@ -179,7 +537,20 @@ func (c *ccLanguage) generateTestRules(args language.GenerateArgs, srcInfo ccSou
 		if !(strings.HasSuffix(ruleName, "test") || strings.HasPrefix(ruleName, "test")) {
 			ruleName = ruleName + "_test"
 		}
-		newRule := newOrExistingRule("cc_test", ruleName, srcGroups, rulesInfo, args)
+		var newRule *rule.Rule
+		if conf.testLayout == inlineTestLayout {
+			// Tests are compiled into a library linked by the custom test runner, it should never reuse the regular library.
+			// Tests are typically registered by static initializers, these need to be linked even if not referenced.
+			newRule = rule.NewRule("cc_library", ruleName)
+			newRule.SetAttr("testonly", true)
+			newRule.SetAttr("alwayslink", true)
+			setDefaultVisibility(args, newRule, true)
+		} else {
+			newRule = newOrExistingRule("cc_test", ruleName, srcGroups, rulesInfo, args)
+			setDefaultVisibility(args, newRule, false)
+			setTestAttrs(conf, newRule)
+		}
+		checkReservedRuleName(args, rulesInfo, newRule)
 
 		// Deal with rules that conflict with existing defintions
 		if ambigiousRuleAssignments, exists := ambigiousRuleAssignments[groupId]; exists {
@@ -188,8 +559,159 @@ func (c *ccLanguage) generateTestRules(args language.GenerateArgs, srcInfo ccSou
 			}
 		}
 		newRule.SetAttr("srcs", toRelativePaths(args.Rel, group.sources))
+		if testArgs := srcInfo.testArgs(group.sources); conf.testLayout != inlineTestLayout && len(testArgs) > 0 {
+			newRule.SetAttr("args", testArgs)
+		}
 		result.Gen = append(result.Gen, newRule)
-		result.Imports = append(result.Imports, extractImports(args, group.sources, srcInfo.sourceInfos))
+		result.Imports = append(result.Imports, extractImports(args, group.sources, srcInfo))
+	}
+}
+
+// Include path prefixes used to detect the test framework used by the source
+var testFrameworkIncludePrefixes = []struct {
+	framework string
+	prefix    string
+}{
+	{framework: "gtest", prefix: "gtest/"},
+	{framework: "gtest", prefix: "gmock/"},
+	{framework: "catch2", prefix: "catch2/"},
+	{framework: "boost", prefix: "boost/test/"},
+}
+
+// Returns the name of the test framework used by the source based on its bracketed includes.
+// Returns empty string if no framework or multiple frameworks were detected.
+func detectTestFramework(sourceInfo parser.SourceInfo) string {
+	detected := ""
+	for _, include := range sourceInfo.Includes.Bracket {
+		for _, candidate := range testFrameworkIncludePrefixes {
+			if !strings.HasPrefix(include, candidate.prefix) {
+				continue
+			}
+			if detected != "" && detected != candidate.framework {
+				return "" // Mixed frameworks
+			}
+			detected = candidate.framework
+		}
+	}
+	return detected
+}
+
+// Splits groups of test sources using different test frameworks into groups named after the framework, e.g. 'gtest_test'.
+// Sources without detected framework remain in the original group. Groups are kept intact if any of
+// their sources includes a source using other framework, as these cannot be built independently.
+func (srcGroups *sourceGroups) splitByTestFramework(args language.GenerateArgs, srcInfo ccSourceInfoSet) {
+	for _, id := range srcGroups.groupIds() {
+		group := (*srcGroups)[id]
+		frameworks := make(map[sourceFile]string, len(group.sources))
+		partitions := make(map[string][]sourceFile)
+		for _, src := range group.sources {
+			framework := detectTestFramework(srcInfo.sourceInfos[src])
+			frameworks[src] = framework
+			partitions[framework] = append(partitions[framework], src)
+		}
+		if len(partitions) < 2 {
+			continue
+		}
+		independent := true
+		for _, src := range group.sources {
+			for _, include := range srcInfo.sourceInfos[src].Includes.DoubleQuote {
+				if framework, isGroupSource := frameworks[newSourceFile(args.Rel, path.Clean(include))]; isGroupSource && framework != frameworks[src] {
+					independent = false
+				}
+			}
+		}
+		frameworkGroupId := func(framework string) groupId {
+			if framework == "" {
+				return id
+			}
+			return groupId(framework + "_test")
+		}
+		for framework := range partitions {
+			if _, exists := (*srcGroups)[frameworkGroupId(framework)]; exists && framework != "" {
+				independent = false // Name of the framework group is already used
+			}
+		}
+		if !independent {
+			continue
+		}
+		delete(*srcGroups, id)
+		for framework, sources := range partitions {
+			(*srcGroups)[frameworkGroupId(framework)] = &sourceGroup{sources: sources}
+		}
+	}
+}
+
+// Assigns attributes defined using 'cc_test_attrs' directive to the generated cc_test rule
+func setTestAttrs(conf *ccConfig, r *rule.Rule) {
+	for _, key := range slices.Sorted(maps.Keys(conf.testAttrs)) {
+		r.SetAttr(key, conf.testAttrs[key])
+	}
+}
+
+// Generates a cc_test rule for each source containing tests inlined in the implementation.
+// Tests are enabled by defining the inlineTestDefine macro when compiling the source
+// Generates a testonly cc_library defining headers classified as tests using 'cc_classify', e.g. mocks, named after the directory with '_test_utils' suffix.
+// An existing library defining only such headers and test sources is reused. Tests including the headers depend on the library when resolved.
+func (c *ccLanguage) generateTestOnlyLibraryRule(args language.GenerateArgs, srcInfo ccSourceInfoSet, rulesInfo rulesInfo, testHdrs []sourceFile, result *language.GenerateResult) {
+	if len(testHdrs) == 0 {
+		return
+	}
+	ruleName := filepath.Base(args.Dir) + testUtilsRuleSuffix
+	for _, existingName := range slices.Sorted(maps.Keys(rulesInfo.ccRuleSources)) {
+		existing := rulesInfo.ccRuleSources[existingName]
+		if resolveCCRuleKind(rulesInfo.definedRules[existingName].Kind(), args.Config) != "cc_library" {
+			continue
+		}
+		definesTestHdrs := slices.ContainsFunc(testHdrs, func(hdr sourceFile) bool { return existing[hdr] })
+		definesOnlyTests := !slices.ContainsFunc(slices.Collect(maps.Keys(existing)), func(src sourceFile) bool { return !slices.Contains(srcInfo.testSrcs, src) })
+		if definesTestHdrs && definesOnlyTests {
+			ruleName = existingName
+			break
+		}
+	}
+	newRule := rule.NewRule("cc_library", ruleName)
+	checkReservedRuleName(args, rulesInfo, newRule)
+	newRule.SetAttr("hdrs", toRelativePaths(args.Rel, testHdrs))
+	newRule.SetAttr("testonly", true)
+	setDefaultVisibility(args, newRule, true)
+	// Test sources are treated as non-header files, includes of the exposed headers are always resolved to public deps
+	imports := extractImports(args, testHdrs, srcInfo)
+	imports.hdrIncludes, imports.srcIncludes = slices.Concat(imports.hdrIncludes, imports.srcIncludes), nil
+	imports.hdrNamespaces, imports.srcNamespaces = slices.Concat(imports.hdrNamespaces, imports.srcNamespaces), nil
+	result.Gen = append(result.Gen, newRule)
+	result.Imports = append(result.Imports, imports)
+}
+
+func (c *ccLanguage) generateInlineTestRules(args language.GenerateArgs, srcInfo ccSourceInfoSet, inlineTestSrcs []sourceFile, result *language.GenerateResult) {
+	for _, src := range inlineTestSrcs {
+		newRule := rule.NewRule("cc_test", src.baseName()+inlineTestRuleSuffix)
+		// The source is compiled again, depending on the library compiling it would link its symbols twice.
+		// Headers of the library are compiled together with the source instead
+		testSrcs := []sourceFile{src}
+		var owner *rule.Rule
+		for _, r := range result.Gen {
+			if resolveCCRuleKind(r.Kind(), args.Config) == "cc_library" && slices.Contains(r.AttrStrings("srcs"), toRelativePaths(args.Rel, []sourceFile{src})[0]) {
+				owner = r
+				break
+			}
+		}
+		if owner != nil {
+			for _, hdr := range slices.Concat(owner.AttrStrings("hdrs"), owner.AttrStrings("textual_hdrs")) {
+				testSrcs = append(testSrcs, newSourceFile(args.Rel, hdr))
+			}
+		}
+		newRule.SetAttr("srcs", toRelativePaths(args.Rel, testSrcs))
+		newRule.SetAttr("local_defines", []string{inlineTestDefine})
+		setTestAttrs(getCcConfig(args.Config), newRule)
+		if testArgs := srcInfo.testArgs([]sourceFile{src}); len(testArgs) > 0 {
+			newRule.SetAttr("args", testArgs)
+		}
+		imports := extractImports(args, testSrcs, srcInfo)
+		if owner != nil {
+			imports.excludedDeps = append(imports.excludedDeps, label.New(args.Config.RepoName, args.Rel, owner.Name()))
+		}
+		result.Gen = append(result.Gen, newRule)
+		result.Imports = append(result.Imports, imports)
 	}
 }
 
@@ -213,6 +735,7 @@ func (c *ccLanguage) generateProtoLibraryRules(args language.GenerateArgs, rules
 			}
 			for _, file := range protoFiles {
 				// If generated pb.h files exists exclude it, refer to cc_proto_library instead
+				// Files generated in the source tree are placed next to the .proto file, independently of the import prefixes
 				if baseName, isProto := strings.CutSuffix(file, ".proto"); isProto {
 					consumedProtoFiles[newSourceFile(args.Rel, baseName+".pb.h")] = true
 					consumedProtoFiles[newSourceFile(args.Rel, baseName+".pb.cc")] = true
@@ -229,10 +752,10 @@ func (c *ccLanguage) generateProtoLibraryRules(args language.GenerateArgs, rules
 			// https://github.com/protocolbuffers/protobuf/blob/d3560e72e791cb61c24df2a1b35946efbd972738/bazel/private/bazel_cc_proto_library.bzl#L132-L142
 			newRule.SetAttr("deps", []label.Label{protoRuleLabel})
 			newRule.SetPrivateAttr(ccProtoLibraryFilesKey, protoFiles)
+			newRule.SetPrivateAttr(ccProtoLibraryStripImportPrefixKey, protoRule.AttrString("strip_import_prefix"))
+			newRule.SetPrivateAttr(ccProtoLibraryImportPrefixKey, protoRule.AttrString("import_prefix"))
 
-			if args.File == nil || !args.File.HasDefaultVisibility() {
-				newRule.SetAttr("visibility", []string{"//visibility:public"})
-			}
+			setDefaultVisibility(args, newRule, true)
 
 			result.Gen = append(result.Gen, newRule)
 			result.Imports = append(result.Imports, ccImports{})
@@ -255,6 +778,14 @@ type ccSourceInfoSet struct {
 	srcs []sourceFile
 	// Headers
 	hdrs []sourceFile
+	// Headers that are not compiled standalone, only included textually by other files, e.g. '.inc'
+	textualHdrs []sourceFile
+	// CUDA sources, collected only when enabled using 'cc_cuda_kind' directive
+	cudaSrcs []sourceFile
+	// CUDA headers, collected only when enabled using 'cc_cuda_kind' directive
+	cudaHdrs []sourceFile
+	// Prebuilt static or shared libraries, e.g. '.a' or '.so'
+	importArtifacts []sourceFile
 	// Sources containing main methods
 	mainSrcs []sourceFile
 	// Sources containing tests or defined in tests context
@@ -263,6 +794,8 @@ type ccSourceInfoSet struct {
 	unmatched []sourceFile
 	// Map containing information extracted from recognized CC source
 	sourceInfos sourceInfos
+	// Headers produced by other rules defined in the same package, mapped to the generating rule
+	generatedHeaders map[sourceFile]label.Label
 }
 
 func newSourceFile(directory string, filename string) sourceFile {
@@ -272,50 +805,256 @@ func newSourceFile(directory string, filename string) sourceFile {
 func (s *ccSourceInfoSet) containsBuildableSource(src sourceFile) bool {
 	return slices.Contains(s.srcs, src) ||
 		slices.Contains(s.hdrs, src) ||
+		slices.Contains(s.textualHdrs, src) ||
+		slices.Contains(s.cudaSrcs, src) ||
+		slices.Contains(s.cudaHdrs, src) ||
+		slices.Contains(s.importArtifacts, src) ||
 		slices.Contains(s.mainSrcs, src) ||
 		slices.Contains(s.testSrcs, src)
 }
 
 // Collects and groups files that can be used to generate CC rules based on it's local context
 // Parses all matched CC source files to extract additional context
+// Sources matching 'cc_inline_test_files' patterns are routed both to library sources and test sources
+// Classification of sources matching 'cc_classify' rules is overridden, independently of their extension or content
 func collectSourceInfos(args language.GenerateArgs) ccSourceInfoSet {
+	conf := getCcConfig(args.Config)
 	res := ccSourceInfoSet{}
 	res.sourceInfos = map[sourceFile]parser.SourceInfo{}
+	res.generatedHeaders = collectGeneratedHeaders(args)
 
 	for _, fileName := range args.RegularFiles {
 		file := newSourceFile(args.Rel, fileName)
-		if !hasMatchingExtension(fileName, cExtensions) {
+		if hasMatchingExtension(fileName, importArtifactExtensions) {
+			res.importArtifacts = append(res.importArtifacts, file)
+			continue
+		}
+		isCuda := conf.cudaKind != "" && hasMatchingExtension(fileName, slices.Concat(cudaSourceExtensions, cudaHeaderExtensions))
+		if !isCuda && !conf.isCSource(fileName) {
 			res.unmatched = append(res.unmatched, file)
 			continue
 		}
+		if _, isGenerated := res.generatedHeaders[file]; isGenerated {
+			// Generated headers present on disk, e.g. checked in or created by a previous build, are not assigned to any rule
+			continue
+		}
 		filePath := filepath.Join(args.Dir, fileName)
-		sourceInfo, err := parser.ParseSourceFile(filePath)
+		sourceInfo, err := parser.ParseSourceFileWithOptions(filePath, parser.ParseOptions{
+			// Used namespaces are needed only to resolve dependencies using 'cc_namespace_dep'
+			UsedNamespaces: len(conf.namespaceDeps) > 0,
+		})
 		if err != nil {
-			log.Printf("Failed to parse source %v, reason: %v", filePath, err)
-			continue
+			// Information extracted before the failure is still used, the file remains a part of generated rules
+			log.Printf("Failed to parse source %v, its dependencies might be incomplete. Reason: %v", filePath, err)
 		}
 		res.sourceInfos[file] = sourceInfo
+		if isCuda {
+			// Defined in a dedicated rule, independently of classification of other sources
+			if hasMatchingExtension(fileName, cudaHeaderExtensions) {
+				res.cudaHdrs = append(res.cudaHdrs, file)
+			} else {
+				res.cudaSrcs = append(res.cudaSrcs, file)
+			}
+			continue
+		}
 		baseName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
 		baseName = strings.ToLower(baseName)
+		if classification, ok := conf.classifySource(fileName); ok {
+			switch classification {
+			case srcsClassification:
+				res.srcs = append(res.srcs, file)
+			case hdrsClassification:
+				res.hdrs = append(res.hdrs, file)
+			case mainClassification:
+				res.mainSrcs = append(res.mainSrcs, file)
+			case testClassification:
+				res.testSrcs = append(res.testSrcs, file)
+			}
+			continue
+		}
 		switch {
-		case hasMatchingExtension(fileName, headerExtensions):
+		case hasMatchingExtension(fileName, headerExtensions) || conf.isCustomHeader(fileName):
 			res.hdrs = append(res.hdrs, file)
+		case hasMatchingExtension(fileName, textualHeaderExtensions):
+			res.textualHdrs = append(res.textualHdrs, file)
 		case strings.HasPrefix(baseName, "test") || strings.HasSuffix(baseName, "test"):
 			res.testSrcs = append(res.testSrcs, file)
 		case sourceInfo.HasMain:
 			res.mainSrcs = append(res.mainSrcs, file)
 		default:
 			res.srcs = append(res.srcs, file)
+			if conf.isInlineTestFile(fileName) {
+				res.testSrcs = append(res.testSrcs, file)
+			}
 		}
 	}
 	return res
 }
 
+// Includes sources of subdirectories without a build file collected using '# gazelle:cc_group unit-global' into the sources of the package.
+// Subdirectories are visited before their parent, so sources of nested packages were already claimed by them.
+func (c *ccLanguage) includeUnitGlobalSources(args language.GenerateArgs, srcInfo *ccSourceInfoSet) {
+	for _, rel := range slices.Sorted(maps.Keys(c.unitGlobalSources)) {
+		if args.Rel != "" && !strings.HasPrefix(rel, args.Rel+"/") {
+			continue
+		}
+		subdir := c.unitGlobalSources[rel]
+		srcInfo.srcs = append(srcInfo.srcs, subdir.srcs...)
+		srcInfo.hdrs = append(srcInfo.hdrs, subdir.hdrs...)
+		srcInfo.textualHdrs = append(srcInfo.textualHdrs, subdir.textualHdrs...)
+		srcInfo.cudaSrcs = append(srcInfo.cudaSrcs, subdir.cudaSrcs...)
+		srcInfo.cudaHdrs = append(srcInfo.cudaHdrs, subdir.cudaHdrs...)
+		srcInfo.mainSrcs = append(srcInfo.mainSrcs, subdir.mainSrcs...)
+		srcInfo.testSrcs = append(srcInfo.testSrcs, subdir.testSrcs...)
+		maps.Copy(srcInfo.sourceInfos, subdir.sourceInfos)
+		delete(c.unitGlobalSources, rel)
+	}
+}
+
+// Reports sources of directories without a build file collected using '# gazelle:cc_group unit-global' that were not included by any package.
+// It happens when the closest enclosing package was not visited, e.g. when Gazelle runs only on a subdirectory, such sources are not defined in any rule
+func (c *ccLanguage) reportUnclaimedUnitGlobalSources() {
+	for _, rel := range slices.Sorted(maps.Keys(c.unitGlobalSources)) {
+		subdir := c.unitGlobalSources[rel]
+		files := slices.Concat(subdir.srcs, subdir.hdrs, subdir.textualHdrs, subdir.cudaSrcs, subdir.cudaHdrs, subdir.mainSrcs, subdir.testSrcs)
+		if len(files) == 0 {
+			continue
+		}
+		slices.Sort(files)
+		log.Printf("gazelle_cc: sources %v of directory %v without a build file are not defined in any rule, the closest enclosing package grouping them using '# gazelle:%v %v' was not visited. Run Gazelle on the enclosing package or add a build file to the directory",
+			slices.Compact(files), rel, cc_group, groupSourcesByUnitGlobal)
+	}
+	clear(c.unitGlobalSources)
+}
+
+// Collects headers listed in 'outs' of rules generated by other languages or defined in the existing build file.
+// Such headers should not be used as sources, instead the rules including them depend on the generating rule.
+func collectGeneratedHeaders(args language.GenerateArgs) map[sourceFile]label.Label {
+	generated := map[sourceFile]label.Label{}
+	rules := slices.Clone(args.OtherGen)
+	if args.File != nil {
+		rules = append(rules, args.File.Rules...)
+	}
+	for _, r := range rules {
+		for _, out := range r.AttrStrings("outs") {
+			if hasMatchingExtension(out, headerExtensions) {
+				generated[newSourceFile(args.Rel, out)] = label.New("", args.Rel, r.Name())
+			}
+		}
+	}
+	return generated
+}
+
+// Informs once about template implementation files, e.g. '.ipp', that were not assigned to any existing rule.
+// Previous versions ignored such files, now these are collected as headers which might change the generated rules.
+func (c *ccLanguage) reportNewTemplateHeaders(srcInfo ccSourceInfoSet, rulesInfo rulesInfo) {
+	if c.reportedTemplateHeaders {
+		return
+	}
+	for _, hdr := range srcInfo.hdrs {
+		if !hasMatchingExtension(hdr.stringValue(), templateHeaderExtensions) {
+			continue
+		}
+		assigned := false
+		for _, ruleSources := range rulesInfo.ccRuleSources {
+			if ruleSources[hdr] {
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			log.Printf("gazelle_cc: template implementation files %v are now collected as headers, %v would be added to hdrs. "+
+				"Use '# gazelle:exclude' to keep these files unassigned", templateHeaderExtensions, hdr)
+			c.reportedTemplateHeaders = true
+			return
+		}
+	}
+}
+
+// Removes given sources from buildable sources, information extracted from them is still available
+func (s *ccSourceInfoSet) excludeSources(excluded sourceFileSet) {
+	if len(excluded) == 0 {
+		return
+	}
+	isExcluded := func(src sourceFile) bool { return excluded[src] }
+	s.srcs = slices.DeleteFunc(s.srcs, isExcluded)
+	s.hdrs = slices.DeleteFunc(s.hdrs, isExcluded)
+	s.textualHdrs = slices.DeleteFunc(s.textualHdrs, isExcluded)
+	s.cudaSrcs = slices.DeleteFunc(s.cudaSrcs, isExcluded)
+	s.cudaHdrs = slices.DeleteFunc(s.cudaHdrs, isExcluded)
+	s.importArtifacts = slices.DeleteFunc(s.importArtifacts, isExcluded)
+	s.mainSrcs = slices.DeleteFunc(s.mainSrcs, isExcluded)
+	s.testSrcs = slices.DeleteFunc(s.testSrcs, isExcluded)
+}
+
+// Classifies headers listed in textual_hdrs of existing rules as textual headers, e.g. '.h' file included in the middle of other file.
+// Such files would be otherwise moved to hdrs of the generated rule, while textual_hdrs of the existing rule is preserved.
+func (s *ccSourceInfoSet) keepTextualHeaders(textualHeaders sourceFileSet) {
+	for _, hdr := range s.hdrs {
+		if textualHeaders[hdr] {
+			s.textualHdrs = append(s.textualHdrs, hdr)
+		}
+	}
+	s.hdrs = slices.DeleteFunc(s.hdrs, func(hdr sourceFile) bool { return textualHeaders[hdr] })
+}
+
+// Checks if include refers to the header paired with the including file - header defined in the same directory with the same name, excluding extension.
+// Such header is recognized when included using quotes or brackets, possibly prefixed with './', e.g. "foo.h", "./foo.h" or <foo.h> included in foo.cc
+func (s *ccSourceInfoSet) isOwnHeaderInclude(file sourceFile, include string) bool {
+	if isLabelInclude(include) {
+		return false
+	}
+	header := newSourceFile(path.Dir(file.stringValue()), include)
+	return header != file && header.toGroupId() == file.toGroupId() && slices.Contains(s.hdrs, header)
+}
+
+// Splits the files into sources, headers and textual headers based on their classification when these were collected.
+// Files that were not collected are classified using their extension
+func (s *ccSourceInfoSet) partitionSources(files []sourceFile) (srcs []sourceFile, hdrs []sourceFile, textualHdrs []sourceFile) {
+	for _, file := range files {
+		switch {
+		case slices.Contains(s.hdrs, file) || slices.Contains(s.cudaHdrs, file):
+			hdrs = append(hdrs, file)
+		case slices.Contains(s.textualHdrs, file):
+			textualHdrs = append(textualHdrs, file)
+		case s.containsBuildableSource(file):
+			srcs = append(srcs, file)
+		default:
+			fileSrcs, fileHdrs, fileTextualHdrs := partitionCSources([]sourceFile{file})
+			srcs = append(srcs, fileSrcs...)
+			hdrs = append(hdrs, fileHdrs...)
+			textualHdrs = append(textualHdrs, fileTextualHdrs...)
+		}
+	}
+	return srcs, hdrs, textualHdrs
+}
+
+// Checks if the file is a header or a textual header, see partitionSources
+func (s *ccSourceInfoSet) isHeader(file sourceFile) bool {
+	srcs, _, _ := s.partitionSources([]sourceFile{file})
+	return len(srcs) == 0
+}
+
+// Checks if the source file is used both as a library source and a test source
+func (s *ccSourceInfoSet) isInlineTestSource(src sourceFile) bool {
+	return slices.Contains(s.srcs, src) && slices.Contains(s.testSrcs, src)
+}
+
+// Collects arguments of the test defined in the sources using `// gazelle:test_args` comments, in order of the sources
+func (s *ccSourceInfoSet) testArgs(srcs []sourceFile) []string {
+	args := []string{}
+	for _, src := range srcs {
+		args = append(args, s.sourceInfos[src].TestArgs...)
+	}
+	return args
+}
+
 // Adjust created sourceGroups based of information from existing rules defintions.
 // * merges with or renames group if all of it sources were previously assigned to existing rule
+// * keeps the name of pinnedGroupId group, if not empty, existing rules previously containing its sources are returned as replacedRules
 // Returns ambigiousRuleAssignments defining a list of groupIds leading to ambigious assignment under the new state -
 // it typically happens when previously independant rules are now creating a cycle
-func (srcGroups *sourceGroups) adjustToExistingRules(rulesInfo rulesInfo) (ambigiousRuleAssignments map[groupId][]string) {
+func (srcGroups *sourceGroups) adjustToExistingRules(rulesInfo rulesInfo, pinnedGroupId groupId) (ambigiousRuleAssignments map[groupId][]string, replacedRules []string) {
 	ambigiousRuleAssignments = make(map[groupId][]string)
 	// Dictionary of groups that previously were assignled to multiple rules
 	for id, group := range *srcGroups {
@@ -326,7 +1065,16 @@ func (srcGroups *sourceGroups) adjustToExistingRules(rulesInfo rulesInfo) (ambig
 				assignedToRules[groupName] = true
 			}
 		}
-		assignedToRuleNames := slices.Collect(maps.Keys(assignedToRules))
+		assignedToRuleNames := slices.Sorted(maps.Keys(assignedToRules))
+		if id == pinnedGroupId {
+			// Name of the group is fixed, existing rules defining its sources are replaced instead of renaming the group
+			for _, ruleName := range assignedToRuleNames {
+				if groupId(ruleName) != id {
+					replacedRules = append(replacedRules, ruleName)
+				}
+			}
+			continue
+		}
 		switch len(assignedToRuleNames) {
 		case 0:
 			// None of the sources are assigned to existing groups, would create a fresh one
@@ -340,7 +1088,7 @@ func (srcGroups *sourceGroups) adjustToExistingRules(rulesInfo rulesInfo) (ambig
 			ambigiousRuleAssignments[id] = assignedToRuleNames
 		}
 	}
-	return ambigiousRuleAssignments
+	return ambigiousRuleAssignments, replacedRules
 }
 
 // Resolve conflicts when resolved sourceGroups do conflict with existing rule definitions.
@@ -356,7 +1104,7 @@ func (c *ccLanguage) handleAmbigiousRulesAssignment(args language.GenerateArgs,
 		switch conf.groupingMode {
 		case groupSourcesByDirectory:
 			mergeReason = "are invalidating the 'cc_group directive' setting"
-		case groupSourcesByUnit:
+		case groupSourcesByUnit, groupSourcesByUnitGlobal, groupSourcesByUnitHeaderMerge:
 			mergeReason = "create a cyclic dependency"
 		default:
 			log.Panicf("Unexpected groupingMode: %v", conf.groupingMode)
@@ -374,6 +1122,8 @@ func (c *ccLanguage) handleAmbigiousRulesAssignment(args language.GenerateArgs,
 			}
 			// Remove no longer exisitng rules
 			if referedRuleName != newRule.Name() && slices.Contains(group.subGroups, groupId(newRule.Name())) {
+				c.explainRemovedRule(args, referedRuleName, "its sources %v were merged into rule '%v'",
+					toRelativePaths(args.Rel, slices.Sorted(maps.Keys(rulesInfo.ccRuleSources[referedRuleName]))), newRule.Name())
 				result.Empty = append(result.Empty, rule.NewRule(referedRule.Kind(), referedRule.Name()))
 			}
 		}
@@ -400,7 +1150,7 @@ func (c *ccLanguage) handleAmbigiousRulesAssignment(args language.GenerateArgs,
 			}
 			rule.SetAttr("deps", deps)
 			result.Gen = append(result.Gen, rule)
-			result.Imports = append(result.Imports, extractImports(args, group.sources, srcInfo.sourceInfos))
+			result.Imports = append(result.Imports, extractImports(args, group.sources, srcInfo))
 		}
 		return false // Skip processing these groups, keep existing rules unchanged
 	default:
@@ -411,7 +1161,8 @@ func (c *ccLanguage) handleAmbigiousRulesAssignment(args language.GenerateArgs,
 
 func (c *ccLanguage) findEmptyRules(args language.GenerateArgs, srcInfo ccSourceInfoSet, rulesInfo rulesInfo, generatedRules []*rule.Rule) []*rule.Rule {
 	file := args.File
-	if file == nil {
+	conf := getCcConfig(args.Config)
+	if file == nil || conf.keepEmptyRules {
 		return nil
 	}
 	emptyRules := []*rule.Rule{}
@@ -423,10 +1174,14 @@ func (c *ccLanguage) findEmptyRules(args language.GenerateArgs, srcInfo ccSource
 			continue
 		}
 
-		if !slices.Contains(knownRuleKinds, resolveCCRuleKind(r.Kind(), args.Config)) {
+		if kind := resolveCCRuleKind(r.Kind(), args.Config); !slices.Contains(knownRuleKinds, kind) && kind != conf.cudaKind {
 			// This rule is not managed by gazelle_cc
 			continue
 		}
+		if rulesInfo.managedExternally[r.Name()] {
+			// Sources of the rule cannot be determined
+			continue
+		}
 
 		sourceFiles := slices.Collect(maps.Keys(rulesInfo.ccRuleSources[r.Name()]))
 		// Check whether at least 1 file mentioned in rule definition sources is buildable (exists)
@@ -437,6 +1192,11 @@ func (c *ccLanguage) findEmptyRules(args language.GenerateArgs, srcInfo ccSource
 		if srcsExist {
 			continue
 		}
+		if len(sourceFiles) == 0 {
+			c.explainRemovedRule(args, r.Name(), "it does not define any sources")
+		} else {
+			c.explainRemovedRule(args, r.Name(), "none of its sources %v exist", toRelativePaths(args.Rel, slices.Sorted(slices.Values(sourceFiles))))
+		}
 		// Create a copy of the rule, using the original one might prevent it from deletion
 		emptyRules = append(emptyRules, rule.NewRule(r.Kind(), r.Name()))
 	}
@@ -444,6 +1204,15 @@ func (c *ccLanguage) findEmptyRules(args language.GenerateArgs, srcInfo ccSource
 	return emptyRules
 }
 
+// Logs the reason for removal of the existing rule, used only when -cc_verbose flag is set
+func (c *ccLanguage) explainRemovedRule(args language.GenerateArgs, ruleName string, reasonFormat string, reasonArgs ...any) {
+	if !c.verbose {
+		return
+	}
+	log.Printf("gazelle_cc: rule %v would be removed, "+reasonFormat,
+		slices.Concat([]any{label.New(args.Config.RepoName, args.Rel, ruleName)}, reasonArgs)...)
+}
+
 func (c *ccLanguage) listRelsToIndex(args language.GenerateArgs, srcInfo ccSourceInfoSet) []string {
 	relsToIndex := []string{}
 	relsToIndexSeen := make(map[string]struct{})
@@ -451,6 +1220,9 @@ func (c *ccLanguage) listRelsToIndex(args language.GenerateArgs, srcInfo ccSourc
 	for _, si := range srcInfo.sourceInfos {
 		for _, incs := range [][]string{si.Includes.DoubleQuote, si.Includes.Bracket} {
 			for _, inc := range incs {
+				if isLabelInclude(inc) {
+					continue // Resolved directly to the referenced label
+				}
 				dir := path.Dir(path.Clean(inc))
 				if dir == "." {
 					dir = ""
@@ -477,38 +1249,125 @@ type rulesInfo struct {
 	ccRuleSources map[string]sourceFileSet
 	// Mapping between groupId created from sourceFile and existing rule name to which it was previously assigned
 	groupAssignment map[groupId]string
+	// Names of existing cc rules defining sources using glob() or other expressions that are not evaluated by gazelle_cc, or matching 'cc_unmanaged' patterns.
+	// Such rules are managed by the user, they're never reported as empty and generated rules are never merged into them
+	managedExternally map[string]bool
+	// Sources assigned to rules managed externally, these are never assigned to generated rules
+	externalSources sourceFileSet
+	// Number of sources listed in srcs of multiple rules
+	duplicatedSources int
+	// Headers listed in textual_hdrs of existing cc_library rules, these stay textual headers independently of their extension
+	textualHeaders sourceFileSet
+}
+
+// Returns an error if any of the sources listed in srcs of multiple rules was reported using '# gazelle:cc_on_duplicate_source error'
+func (c *ccLanguage) duplicateSourcesError() error {
+	if c.duplicateSourceErrors == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of the sources are listed in srcs of multiple rules, see the errors reported above", c.duplicateSourceErrors)
 }
 
 func extractRulesInfo(args language.GenerateArgs) rulesInfo {
 	info := rulesInfo{
-		definedRules:    make(map[string]*rule.Rule),
-		ccRuleSources:   make(map[string]sourceFileSet),
-		groupAssignment: make(map[groupId]string),
+		definedRules:      make(map[string]*rule.Rule),
+		ccRuleSources:     make(map[string]sourceFileSet),
+		groupAssignment:   make(map[groupId]string),
+		managedExternally: make(map[string]bool),
+		externalSources:   make(sourceFileSet),
+		textualHeaders:    make(sourceFileSet),
 	}
 	if args.File == nil {
 		return info
 	}
+	conf := getCcConfig(args.Config)
+	// Names of rules listing each of the files in their 'srcs' attribute
+	srcsRules := make(map[sourceFile][]string)
 	for _, rule := range args.File.Rules {
 		ruleName := rule.Name()
 		info.definedRules[ruleName] = rule
-		assignSources := func(srcs []string) {
+		assignSources := func(attr string, srcs []string) {
 			for _, filename := range srcs {
 				srcFile := newSourceFile(args.Rel, filename)
 				if _, exists := info.ccRuleSources[ruleName]; !exists {
 					info.ccRuleSources[ruleName] = make(sourceFileSet)
 				}
 				info.ccRuleSources[ruleName][srcFile] = true
+				if conf.isInlineTestFile(filename) && resolveCCRuleKind(rule.Kind(), args.Config) == "cc_test" {
+					// Sources with inlined tests are grouped based on the library they're assigned to
+					continue
+				}
+				if attr == "srcs" && !slices.Contains(srcsRules[srcFile], ruleName) {
+					srcsRules[srcFile] = append(srcsRules[srcFile], ruleName)
+				}
 				info.groupAssignment[srcFile.toGroupId()] = ruleName
 			}
 		}
-		switch resolveCCRuleKind(rule.Kind(), args.Config) {
+		kind := resolveCCRuleKind(rule.Kind(), args.Config)
+		isManagedKind := slices.Contains(knownRuleKinds, kind) || kind == conf.cudaKind
+		if isManagedKind && (hasComputedSources(rule) || conf.isUnmanagedRule(ruleName)) {
+			info.managedExternally[ruleName] = true
+			for _, attr := range []string{"srcs", "hdrs", "textual_hdrs"} {
+				for _, filename := range rule.AttrStrings(attr) {
+					info.externalSources[newSourceFile(args.Rel, filename)] = true
+				}
+			}
+			continue
+		}
+		switch kind {
 		case "cc_library":
-			assignSources(rule.AttrStrings("srcs"))
-			assignSources(rule.AttrStrings("hdrs"))
-		case "cc_binary":
-			assignSources(rule.AttrStrings("srcs"))
-		case "cc_test":
-			assignSources(rule.AttrStrings("srcs"))
+			for _, attr := range []string{"srcs", "hdrs", "textual_hdrs"} {
+				assignSources(attr, rule.AttrStrings(attr))
+			}
+			for _, filename := range rule.AttrStrings("textual_hdrs") {
+				info.textualHeaders[newSourceFile(args.Rel, filename)] = true
+			}
+		case "cc_binary", "cc_test":
+			assignSources("srcs", rule.AttrStrings("srcs"))
+		case conf.cudaKind:
+			// CUDA sources are never grouped together with other sources, these are tracked only to detect empty rules
+			for _, filename := range slices.Concat(rule.AttrStrings("srcs"), rule.AttrStrings("hdrs")) {
+				if _, exists := info.ccRuleSources[ruleName]; !exists {
+					info.ccRuleSources[ruleName] = make(sourceFileSet)
+				}
+				info.ccRuleSources[ruleName][newSourceFile(args.Rel, filename)] = true
+			}
+		case "cc_import":
+			assignSources("hdrs", rule.AttrStrings("hdrs"))
+			for _, attr := range importArtifactAttrs {
+				if artifact := rule.AttrString(attr); artifact != "" {
+					assignSources(attr, []string{artifact})
+				}
+			}
+		}
+	}
+	// Each source is assigned to exactly one generated rule, when it's listed in multiple rules
+	// it's kept in the rule with the lexicographically smallest name, independently of the order of rules in the file
+	for _, srcFile := range slices.Sorted(maps.Keys(srcsRules)) {
+		ruleNames := srcsRules[srcFile]
+		if len(ruleNames) < 2 {
+			continue
+		}
+		slices.Sort(ruleNames)
+		owner := ruleNames[0]
+		log.Printf("Rules %v defined in %v list the same source %v in srcs, it would be assigned only to rule '%v'",
+			ruleNames, args.File.Path, toRelativePaths(args.Rel, []sourceFile{srcFile})[0], owner)
+		info.duplicatedSources++
+		for _, ruleName := range ruleNames {
+			if ruleName != owner {
+				delete(info.ccRuleSources[ruleName], srcFile)
+			}
+		}
+		info.groupAssignment[srcFile.toGroupId()] = owner
+	}
+	if conf.splitHeaders {
+		// Headers assigned to header-only library belong to the same group as the sources of its implementation library
+		for groupId, ruleName := range info.groupAssignment {
+			if implRuleName, isHeadersRule := strings.CutSuffix(ruleName, splitHeadersRuleSuffix); isHeadersRule {
+				if _, exists := info.definedRules[implRuleName]; exists {
+					info.groupAssignment[groupId] = implRuleName
+				}
+			}
 		}
 	}
 	return info
@@ -526,10 +1385,42 @@ func resolveCCRuleKind(kind string, config *config.Config) string {
 	return kind
 }
 
-// Return list of existing rules of kind or with matching kind mapping
+// Checks if sources of the rule are defined using an expression that is not a list of strings, typically a glob()
+func hasComputedSources(r *rule.Rule) bool {
+	for _, attr := range []string{"srcs", "hdrs"} {
+		if r.Attr(attr) != nil && r.AttrStrings(attr) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes generated rules that would be merged into existing rules managed externally, together with their imports
+func (info *rulesInfo) dropRulesManagedExternally(args language.GenerateArgs, result *language.GenerateResult) {
+	if len(info.managedExternally) == 0 {
+		return
+	}
+	gen := make([]*rule.Rule, 0, len(result.Gen))
+	imports := make([]any, 0, len(result.Imports))
+	for idx, r := range result.Gen {
+		if info.managedExternally[r.Name()] {
+			log.Printf("%v: existing rule is not managed by gazelle_cc, generated rule with sources %v would not be merged into it",
+				label.New(args.Config.RepoName, args.Rel, r.Name()), slices.Concat(r.AttrStrings("srcs"), r.AttrStrings("hdrs")))
+			continue
+		}
+		gen = append(gen, r)
+		imports = append(imports, result.Imports[idx])
+	}
+	result.Gen, result.Imports = gen, imports
+}
+
+// Return list of existing rules of kind or with matching kind mapping, excluding rules managed externally
 func (info *rulesInfo) existingRulesOfKind(kind string, args language.GenerateArgs) []*rule.Rule {
 	rules := make([]*rule.Rule, 0, len(info.ccRuleSources))
 	for _, rule := range info.definedRules {
+		if info.managedExternally[rule.Name()] {
+			continue
+		}
 		if resolveCCRuleKind(rule.Kind(), args.Config) == kind {
 			rules = append(rules, rule)
 		}
@@ -0,0 +1,950 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/EngFlow/gazelle_cc/language/internal/cc/parser"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/merger"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectSourceInfosInlineTests(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"math.h":       "#pragma once\n",
+		"math.cc":      "#include \"math.h\"\n#ifdef UNIT_TEST\n#include <gtest/gtest.h>\n#endif\n",
+		"util.cc":      "#include \"math.h\"\n",
+		"math_test.cc": "#include <gtest/gtest.h>\n",
+		"main.cc":      "int main() { return 0; }\n",
+	}
+	fileNames := []string{}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+		fileNames = append(fileNames, name)
+	}
+
+	for _, tc := range []struct {
+		clue             string
+		patterns         []string
+		expectedSrcs     []sourceFile
+		expectedTestSrcs []sourceFile
+	}{
+		{
+			clue:             "Without patterns sources are not routed to tests",
+			patterns:         []string{},
+			expectedSrcs:     []sourceFile{"lib/math.cc", "lib/util.cc"},
+			expectedTestSrcs: []sourceFile{"lib/math_test.cc"},
+		},
+		{
+			clue:             "Sources matching pattern are routed both to library and tests",
+			patterns:         []string{"math*.cc"},
+			expectedSrcs:     []sourceFile{"lib/math.cc", "lib/util.cc"},
+			expectedTestSrcs: []sourceFile{"lib/math.cc", "lib/math_test.cc"},
+		},
+		{
+			clue:             "Headers and sources with main are never routed to tests",
+			patterns:         []string{"*"},
+			expectedSrcs:     []sourceFile{"lib/math.cc", "lib/util.cc"},
+			expectedTestSrcs: []sourceFile{"lib/math.cc", "lib/math_test.cc", "lib/util.cc"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			conf := newCcConfig()
+			conf.inlineTestPatterns = tc.patterns
+			c := config.New()
+			c.Exts[languageName] = conf
+
+			result := collectSourceInfos(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          "lib",
+				RegularFiles: fileNames,
+			})
+			require.ElementsMatch(t, tc.expectedSrcs, result.srcs)
+			require.ElementsMatch(t, tc.expectedTestSrcs, result.testSrcs)
+			require.ElementsMatch(t, []sourceFile{"lib/math.h"}, result.hdrs)
+			require.ElementsMatch(t, []sourceFile{"lib/main.cc"}, result.mainSrcs)
+			for _, src := range tc.expectedTestSrcs {
+				require.Equal(t, src != "lib/math_test.cc", result.isInlineTestSource(src), src)
+			}
+		})
+	}
+}
+
+func TestGenerateRulesReservedNames(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "all")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	files := []string{"all.h", "all.cc", "util.h", "util.cc"}
+	for _, name := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("int f();\n"), 0666))
+	}
+
+	for _, tc := range []struct {
+		clue          string
+		buildFile     string
+		expectedNames []string
+		expectedLogs  []string
+	}{
+		{
+			clue:          "Rule named as the target pattern wildcard is reported",
+			buildFile:     "",
+			expectedNames: []string{"all"},
+			expectedLogs:  []string{"//all: name of the generated rule collides with a reserved name, rename it or set '# gazelle:cc_reserved_name_suffix <suffix>'"},
+		},
+		{
+			clue:          "Colliding rules are renamed using the suffix",
+			buildFile:     "# gazelle:cc_group unit\n# gazelle:cc_reserved_name_suffix _lib\n# gazelle:cc_reserved_names util\n",
+			expectedNames: []string{"all_lib", "util_lib"},
+			expectedLogs:  []string{"//all:util: name of the generated rule collides with a reserved name, it would be named 'util_lib'"},
+		},
+		{
+			clue:          "Rule named as a symbol loaded in the build file is reported",
+			buildFile:     "# gazelle:cc_group unit\n# gazelle:cc_reserved_names\nload(\"//tools:defs.bzl\", \"util\")\n",
+			expectedNames: []string{"all", "util"},
+			expectedLogs:  []string{"//all:util: name of the generated rule collides with a symbol loaded from //tools:defs.bzl"},
+		},
+		{
+			clue:          "Existing rules are never renamed",
+			buildFile:     "# gazelle:cc_reserved_name_suffix _lib\ncc_library(name = \"all\", srcs = [\"all.cc\"])\n",
+			expectedNames: []string{"all"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			existingFile, err := rule.LoadData("all/BUILD.bazel", "all", []byte(tc.buildFile))
+			require.NoError(t, err)
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			c := config.New()
+			lang := NewLanguage()
+			lang.Configure(c, "all", existingFile)
+			result := lang.GenerateRules(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          "all",
+				File:         existingFile,
+				RegularFiles: files,
+			})
+			names := []string{}
+			for _, r := range result.Gen {
+				names = append(names, r.Name())
+			}
+			require.ElementsMatch(t, tc.expectedNames, names)
+			for _, expected := range tc.expectedLogs {
+				require.Contains(t, logs.String(), expected)
+			}
+			if len(tc.expectedLogs) == 0 {
+				require.NotContains(t, logs.String(), "collides")
+			}
+		})
+	}
+}
+
+func TestGenerateRulesCustomExtensions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "gpu")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	files := []string{"kernel.cu", "kernel.cuh", "util.cc"}
+	for _, name := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("int f();\n"), 0666))
+	}
+
+	for _, tc := range []struct {
+		clue         string
+		directive    string
+		expectedSrcs []string
+		expectedHdrs []string
+	}{
+		{
+			clue:         "Files with unknown extensions are ignored by default",
+			expectedSrcs: []string{"util.cc"},
+		},
+		{
+			clue:         "Extensions defined using the directive are recognized",
+			directive:    "# gazelle:cc_extensions source=.cu header=.cuh\n",
+			expectedSrcs: []string{"kernel.cu", "util.cc"},
+			expectedHdrs: []string{"kernel.cuh"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			existingFile, err := rule.LoadData("gpu/BUILD.bazel", "gpu", []byte(tc.directive))
+			require.NoError(t, err)
+			c := config.New()
+			lang := NewLanguage()
+			lang.Configure(c, "gpu", existingFile)
+			result := lang.GenerateRules(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          "gpu",
+				File:         existingFile,
+				RegularFiles: files,
+			})
+			require.Len(t, result.Gen, 1)
+			require.Equal(t, tc.expectedSrcs, result.Gen[0].AttrStrings("srcs"))
+			require.Equal(t, tc.expectedHdrs, result.Gen[0].AttrStrings("hdrs"))
+		})
+	}
+}
+
+func TestCollectSourceInfosClassify(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"server.h":       "#pragma once\n",
+		"server.cc":      "#include \"server.h\"\n",
+		"server_mock.h":  "#pragma once\n#include <gmock/gmock.h>\n",
+		"server_main.cc": "#include \"server.h\"\nRUN_SERVER(Server)\n",
+		"server_test.cc": "#include \"server_mock.h\"\n",
+	}
+	fileNames := []string{}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+		fileNames = append(fileNames, name)
+	}
+
+	for _, tc := range []struct {
+		clue             string
+		rules            []classifyRule
+		expectedSrcs     []sourceFile
+		expectedHdrs     []sourceFile
+		expectedMainSrcs []sourceFile
+		expectedTestSrcs []sourceFile
+	}{
+		{
+			clue:             "Without rules files are classified by their extension and content",
+			rules:            []classifyRule{},
+			expectedSrcs:     []sourceFile{"srv/server.cc", "srv/server_main.cc"},
+			expectedHdrs:     []sourceFile{"srv/server.h", "srv/server_mock.h"},
+			expectedTestSrcs: []sourceFile{"srv/server_test.cc"},
+		},
+		{
+			clue: "Mock headers are classified as tests and main sources by their name",
+			rules: []classifyRule{
+				{pattern: "*_mock.h", classification: testClassification},
+				{pattern: "*_main.cc", classification: mainClassification},
+			},
+			expectedSrcs:     []sourceFile{"srv/server.cc"},
+			expectedHdrs:     []sourceFile{"srv/server.h"},
+			expectedMainSrcs: []sourceFile{"srv/server_main.cc"},
+			expectedTestSrcs: []sourceFile{"srv/server_mock.h", "srv/server_test.cc"},
+		},
+		{
+			clue: "Later rules take precedence",
+			rules: []classifyRule{
+				{pattern: "server*", classification: mainClassification},
+				{pattern: "*.h", classification: hdrsClassification},
+				{pattern: "server.cc", classification: srcsClassification},
+			},
+			expectedSrcs:     []sourceFile{"srv/server.cc"},
+			expectedHdrs:     []sourceFile{"srv/server.h", "srv/server_mock.h"},
+			expectedMainSrcs: []sourceFile{"srv/server_main.cc", "srv/server_test.cc"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			conf := newCcConfig()
+			conf.classifyRules = tc.rules
+			c := config.New()
+			c.Exts[languageName] = conf
+
+			result := collectSourceInfos(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          "srv",
+				RegularFiles: fileNames,
+			})
+			require.ElementsMatch(t, tc.expectedSrcs, result.srcs)
+			require.ElementsMatch(t, tc.expectedHdrs, result.hdrs)
+			require.ElementsMatch(t, tc.expectedMainSrcs, result.mainSrcs)
+			require.ElementsMatch(t, tc.expectedTestSrcs, result.testSrcs)
+		})
+	}
+}
+
+func TestCollectSourceInfosTemplateHeaders(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vector.hpp": "#pragma once\ntemplate <typename T> class Vector {};\n#include \"vector.ipp\"\n",
+		"vector.ipp": "#include \"memory/alloc.h\"\ntemplate <typename T> void Vector<T>::push_back(const T&) {}\n",
+		"list.tcc":   "#include <memory>\n",
+		"vector.cc":  "#include \"vector.hpp\"\n",
+	}
+	fileNames := []string{}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+		fileNames = append(fileNames, name)
+	}
+	c := config.New()
+	c.Exts[languageName] = newCcConfig()
+
+	result := collectSourceInfos(language.GenerateArgs{
+		Config:       c,
+		Dir:          dir,
+		Rel:          "container",
+		RegularFiles: fileNames,
+	})
+	require.ElementsMatch(t, []sourceFile{"container/vector.hpp"}, result.hdrs)
+	require.ElementsMatch(t, []sourceFile{"container/vector.ipp", "container/list.tcc"}, result.textualHdrs)
+	require.ElementsMatch(t, []sourceFile{"container/vector.cc"}, result.srcs)
+	require.Empty(t, result.unmatched)
+	require.Equal(t, []string{"memory/alloc.h"}, result.sourceInfos["container/vector.ipp"].Includes.DoubleQuote)
+	require.Equal(t, []string{"memory"}, result.sourceInfos["container/list.tcc"].Includes.Bracket)
+}
+
+func TestGenerateRulesPackageRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"util.h":       "#pragma once\n",
+		"util.cc":      "#include \"util.h\"\n",
+		"util_test.cc": "#include \"util.h\"\n",
+	}
+	fileNames := []string{}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+		fileNames = append(fileNames, name)
+	}
+
+	// Sources are stored relative to the repository root, but always rendered relative to the package
+	for _, rel := range []string{"", "util", "third_party/util"} {
+		t.Run(rel, func(t *testing.T) {
+			c := config.New()
+			c.Exts[languageName] = newCcConfig()
+			result := NewLanguage().GenerateRules(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          rel,
+				RegularFiles: fileNames,
+			})
+			rendered := map[string][]string{}
+			for _, r := range result.Gen {
+				rendered[r.Kind()+".srcs"] = r.AttrStrings("srcs")
+				rendered[r.Kind()+".hdrs"] = r.AttrStrings("hdrs")
+			}
+			require.Equal(t, map[string][]string{
+				"cc_library.srcs": {"util.cc"},
+				"cc_library.hdrs": {"util.h"},
+				"cc_test.srcs":    {"util_test.cc"},
+				"cc_test.hdrs":    nil,
+			}, rendered)
+		})
+	}
+}
+
+func TestGenerateRulesTestArgs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"data_test.cc":  "// gazelle:test_args --data_dir testdata\n#include <gtest/gtest.h>\n",
+		"plain_test.cc": "#include <gtest/gtest.h>\n",
+	}
+	fileNames := []string{}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+		fileNames = append(fileNames, name)
+	}
+
+	c := config.New()
+	conf := newCcConfig()
+	conf.groupingMode = groupSourcesByUnit
+	c.Exts[languageName] = conf
+	result := NewLanguage().GenerateRules(language.GenerateArgs{
+		Config:       c,
+		Dir:          dir,
+		Rel:          "pkg",
+		RegularFiles: fileNames,
+	})
+	testArgs := map[string][]string{}
+	for _, r := range result.Gen {
+		testArgs[r.Name()] = r.AttrStrings("args")
+	}
+	require.Equal(t, map[string][]string{
+		"data_test":  {"--data_dir", "testdata"},
+		"plain_test": nil,
+	}, testArgs)
+}
+
+func TestGenerateRulesGlobSources(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	for _, name := range []string{"lib.cc", "lib.h", "tool.cc"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("int f();\n"), 0666))
+	}
+	existingFile, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(`
+cc_library(
+    name = "lib",
+    srcs = glob(["*.cc"], exclude = ["tool.cc"]),
+    hdrs = glob(["*.h"]),
+)
+
+cc_library(
+    name = "generated",
+    srcs = ["gen.cc"] + glob(["gen/*.cc"]),
+)
+`))
+	require.NoError(t, err)
+
+	c := config.New()
+	c.Exts[languageName] = newCcConfig()
+	result := NewLanguage().GenerateRules(language.GenerateArgs{
+		Config:       c,
+		Dir:          dir,
+		Rel:          "lib",
+		File:         existingFile,
+		RegularFiles: []string{"lib.cc", "lib.h", "tool.cc"},
+	})
+
+	// Rules using glob are neither merged with generated rules nor removed
+	require.Empty(t, result.Gen)
+	require.Empty(t, result.Imports)
+	for _, r := range result.Empty {
+		require.NotContains(t, []string{"lib", "generated"}, r.Name())
+	}
+	require.Equal(t, `cc_library(
+    name = "lib",
+    srcs = glob(
+        ["*.cc"],
+        exclude = ["tool.cc"],
+    ),
+    hdrs = glob(["*.h"]),
+)
+
+cc_library(
+    name = "generated",
+    srcs = ["gen.cc"] + glob(["gen/*.cc"]),
+)
+`, string(existingFile.Format()))
+}
+
+func TestGenerateRulesTextualHeaders(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	files := map[string]string{
+		"lib.h":      "#pragma once\nclass Lib {\n#include \"lib.inc\"\n};\n",
+		"lib.inc":    "#include \"util/util.h\"\nvoid run();\n",
+		"lib.cc":     "#include \"lib.h\"\n",
+		"tables.def": "TABLE(a)\nTABLE(b)\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+	}
+	existingFile, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(`
+cc_library(
+    name = "lib",
+    textual_hdrs = ["lib.inc"],
+)
+`))
+	require.NoError(t, err)
+
+	c := config.New()
+	c.Exts[languageName] = newCcConfig()
+	result := NewLanguage().GenerateRules(language.GenerateArgs{
+		Config:       c,
+		Dir:          dir,
+		Rel:          "lib",
+		File:         existingFile,
+		RegularFiles: slices.Sorted(maps.Keys(files)),
+	})
+
+	// Existing rule defining only textual headers is not removed
+	require.Empty(t, result.Empty)
+	require.Len(t, result.Gen, 1)
+	lib := result.Gen[0]
+	require.Equal(t, "lib", lib.Name())
+	require.Equal(t, []string{"lib.cc"}, lib.AttrStrings("srcs"))
+	require.Equal(t, []string{"lib.h"}, lib.AttrStrings("hdrs"))
+	require.Equal(t, []string{"lib.inc", "tables.def"}, lib.AttrStrings("textual_hdrs"))
+
+	// Textual headers are included by public headers, their includes are dependencies of the library
+	require.Contains(t, result.Imports[0].(ccImports).hdrIncludes, ccInclude{
+		rawPath:        "util/util.h",
+		normalizedPath: "lib/util/util.h",
+		location:       "lib/lib.inc:1",
+	})
+}
+
+func TestMergePreservesManualTextualHeaders(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	files := map[string]string{
+		"lib.h":     "#pragma once\nclass Lib {\n#include \"members.h\"\n};\n",
+		"members.h": "void run();\n",
+		"lib.cc":    "#include \"lib.h\"\n",
+		"new.inc":   "void stop();\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+	}
+	existingFile, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(`
+cc_library(
+    name = "lib",
+    srcs = ["lib.cc"],
+    hdrs = ["lib.h"],
+    textual_hdrs = ["members.h"],
+)
+`))
+	require.NoError(t, err)
+
+	c := config.New()
+	c.Exts[languageName] = newCcConfig()
+	lang := NewLanguage()
+	result := lang.GenerateRules(language.GenerateArgs{
+		Config:       c,
+		Dir:          dir,
+		Rel:          "lib",
+		File:         existingFile,
+		RegularFiles: slices.Sorted(maps.Keys(files)),
+	})
+	merger.MergeFile(existingFile, result.Empty, result.Gen, merger.PreResolve, lang.Kinds(), nil)
+	merger.MergeFile(existingFile, nil, result.Gen, merger.PostResolve, lang.Kinds(), nil)
+
+	// Header listed in textual_hdrs is not moved to hdrs, hand-written textual_hdrs are never modified
+	require.Len(t, existingFile.Rules, 1)
+	merged := existingFile.Rules[0]
+	require.Equal(t, []string{"lib.h"}, merged.AttrStrings("hdrs"))
+	require.Equal(t, []string{"members.h"}, merged.AttrStrings("textual_hdrs"))
+}
+
+func TestGenerateRulesUnmanagedRules(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	files := []string{"hand.cc", "hand.h", "lib.cc", "lib.h"}
+	for _, name := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("int f();\n"), 0666))
+	}
+	existingFile, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(`
+# gazelle:cc_unmanaged hand_* other
+
+cc_library(
+    name = "hand_made",
+    srcs = ["hand.cc"],
+    hdrs = ["hand.h"],
+    deps = [":manual"],
+)
+
+cc_library(
+    name = "other",
+    srcs = ["missing.cc"],
+)
+`))
+	require.NoError(t, err)
+
+	c := config.New()
+	lang := NewLanguage()
+	lang.Configure(c, "lib", existingFile)
+	result := lang.GenerateRules(language.GenerateArgs{
+		Config:       c,
+		Dir:          dir,
+		Rel:          "lib",
+		File:         existingFile,
+		RegularFiles: files,
+	})
+
+	// Sources of unmanaged rules are not assigned to generated rules, unmanaged rules are never removed
+	require.Len(t, result.Gen, 1)
+	require.Equal(t, "lib", result.Gen[0].Name())
+	require.Equal(t, []string{"lib.cc"}, result.Gen[0].AttrStrings("srcs"))
+	require.Equal(t, []string{"lib.h"}, result.Gen[0].AttrStrings("hdrs"))
+	require.Empty(t, result.Empty)
+}
+
+func TestGenerateRulesDuplicatedSources(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	files := []string{"a.cc", "a.h", "b.cc", "b.h", "shared.cc"}
+	for _, name := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("int f();\n"), 0666))
+	}
+	ruleA := `
+cc_library(
+    name = "a",
+    srcs = ["a.cc", "shared.cc"],
+    hdrs = ["a.h"],
+)
+`
+	ruleB := `
+cc_library(
+    name = "b",
+    srcs = ["b.cc", "shared.cc"],
+    hdrs = ["b.h"],
+)
+`
+
+	for _, tc := range []struct {
+		clue           string
+		buildFile      string
+		expectedErrors int
+	}{
+		{clue: "Owner defined first", buildFile: ruleA + ruleB},
+		{clue: "Owner defined last", buildFile: ruleB + ruleA},
+		{clue: "Duplicates reported as errors", buildFile: "# gazelle:cc_on_duplicate_source error\n" + ruleA + ruleB, expectedErrors: 1},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			existingFile, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte("# gazelle:cc_group unit\n"+tc.buildFile))
+			require.NoError(t, err)
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			c := config.New()
+			lang := NewLanguage().(*ccLanguage)
+			lang.Configure(c, "lib", existingFile)
+			result := lang.GenerateRules(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          "lib",
+				File:         existingFile,
+				RegularFiles: files,
+			})
+			require.Equal(t, tc.expectedErrors, lang.duplicateSourceErrors)
+
+			// The source is assigned to the rule with the lexicographically smallest name, independently of the order of rules
+			generated := make(map[string][]string)
+			for _, r := range result.Gen {
+				generated[r.Name()] = r.AttrStrings("srcs")
+			}
+			require.Equal(t, map[string][]string{"a": {"a.cc", "shared.cc"}, "b": {"b.cc"}}, generated)
+			require.Empty(t, result.Empty)
+			require.Contains(t, logs.String(), "Rules [a b] defined in lib/BUILD.bazel list the same source shared.cc in srcs, it would be assigned only to rule 'a'")
+		})
+	}
+}
+
+func TestGenerateRulesUnclaimedUnitGlobalSources(t *testing.T) {
+	repo := t.TempDir()
+	srcDir := filepath.Join(repo, "lib", "src")
+	require.NoError(t, os.MkdirAll(srcDir, 0777))
+	files := []string{"foo.cc", "foo.h"}
+	for _, name := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte("int foo();\n"), 0666))
+	}
+	rootFile, err := rule.LoadData("BUILD.bazel", "", []byte("# gazelle:cc_group unit-global\n"))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		clue          string
+		visitRoot     bool
+		expectWarning bool
+	}{
+		{clue: "Enclosing package visited", visitRoot: true},
+		{clue: "Only the subdirectory visited", expectWarning: true},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			c := config.New()
+			lang := NewLanguage().(*ccLanguage)
+			// Configuration of parent directories is always applied, rules are generated only for visited directories
+			lang.Configure(c, "", rootFile)
+			libConfig := c.Clone()
+			lang.Configure(libConfig, "lib", nil)
+			srcConfig := libConfig.Clone()
+			lang.Configure(srcConfig, "lib/src", nil)
+
+			srcResult := lang.GenerateRules(language.GenerateArgs{Config: srcConfig, Dir: srcDir, Rel: "lib/src", RegularFiles: files})
+			require.Empty(t, srcResult.Gen, "sources of directories without a build file are defined by the enclosing package")
+			lang.GenerateRules(language.GenerateArgs{Config: libConfig, Dir: filepath.Join(repo, "lib"), Rel: "lib"})
+			if tc.visitRoot {
+				rootResult := lang.GenerateRules(language.GenerateArgs{Config: c, Dir: repo, Rel: "", File: rootFile})
+				require.Len(t, rootResult.Gen, 1)
+				require.Equal(t, []string{"lib/src/foo.cc"}, rootResult.Gen[0].AttrStrings("srcs"))
+			}
+			lang.DoneGeneratingRules()
+
+			const warning = "sources [lib/src/foo.cc lib/src/foo.h] of directory lib/src without a build file are not defined in any rule"
+			if tc.expectWarning {
+				require.Contains(t, logs.String(), warning)
+			} else {
+				require.NotContains(t, logs.String(), warning)
+			}
+		})
+	}
+}
+
+func TestGenerateRulesLibraryName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	files := []string{"lib.cc", "lib.h", "util.h"}
+	for _, name := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("int f();\n"), 0666))
+	}
+
+	for _, tc := range []struct {
+		clue          string
+		buildFile     string
+		expectedEmpty []string
+	}{
+		{
+			clue:          "New library",
+			buildFile:     "# gazelle:cc_library_name core\n",
+			expectedEmpty: []string{},
+		},
+		{
+			clue: "Existing library with the pinned name",
+			buildFile: `# gazelle:cc_library_name core
+
+cc_library(
+    name = "core",
+    srcs = ["lib.cc"],
+)
+`,
+			expectedEmpty: []string{},
+		},
+		{
+			clue: "Existing library named after the directory",
+			buildFile: `# gazelle:cc_library_name core
+
+cc_library(
+    name = "lib",
+    srcs = ["lib.cc"],
+    hdrs = ["lib.h"],
+)
+`,
+			expectedEmpty: []string{"lib"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			existingFile, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(tc.buildFile))
+			require.NoError(t, err)
+
+			c := config.New()
+			lang := NewLanguage()
+			lang.Configure(c, "lib", existingFile)
+			result := lang.GenerateRules(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          "lib",
+				File:         existingFile,
+				RegularFiles: files,
+			})
+
+			require.Len(t, result.Gen, 1)
+			require.Equal(t, "core", result.Gen[0].Name())
+			require.Equal(t, []string{"lib.cc"}, result.Gen[0].AttrStrings("srcs"))
+			require.Equal(t, []string{"lib.h", "util.h"}, result.Gen[0].AttrStrings("hdrs"))
+			emptyRules := []string{}
+			for _, r := range result.Empty {
+				emptyRules = append(emptyRules, r.Name())
+			}
+			require.Equal(t, tc.expectedEmpty, emptyRules)
+		})
+	}
+
+	// The directive applies only to the package defining it
+	c := config.New()
+	lang := NewLanguage()
+	parentFile, err := rule.LoadData("BUILD.bazel", "", []byte("# gazelle:cc_library_name core\n"))
+	require.NoError(t, err)
+	lang.Configure(c, "", parentFile)
+	lang.Configure(c, "lib", nil)
+	require.Empty(t, getCcConfig(c).libraryName)
+}
+
+func TestMergePreservesManualAttributes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	for _, name := range []string{"lib.cc", "lib.h"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("int f();\n"), 0666))
+	}
+
+	for _, attr := range []string{"copts", "defines", "local_defines", "linkopts", "includes", "textual_hdrs"} {
+		t.Run(attr, func(t *testing.T) {
+			existingFile, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(fmt.Sprintf(`
+cc_library(
+    name = "lib",
+    srcs = ["old.cc"],
+    %v = ["manual"],
+)
+`, attr)))
+			require.NoError(t, err)
+
+			c := config.New()
+			c.Exts[languageName] = newCcConfig()
+			lang := NewLanguage()
+			result := lang.GenerateRules(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          "lib",
+				File:         existingFile,
+				RegularFiles: []string{"lib.cc", "lib.h"},
+			})
+			merger.MergeFile(existingFile, result.Empty, result.Gen, merger.PreResolve, lang.Kinds(), nil)
+			merger.MergeFile(existingFile, nil, result.Gen, merger.PostResolve, lang.Kinds(), nil)
+
+			require.Len(t, existingFile.Rules, 1)
+			merged := existingFile.Rules[0]
+			require.Equal(t, []string{"lib.cc"}, merged.AttrStrings("srcs"))
+			require.Equal(t, []string{"manual"}, merged.AttrStrings(attr))
+		})
+	}
+}
+
+func TestMergePreservesManualTestAttributes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lib")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib_test.cc"), []byte("#include <gtest/gtest.h>\n"), 0666))
+
+	testCases := []struct {
+		attr      string
+		value     string
+		directive string
+	}{
+		{attr: "size", value: `"small"`},
+		{attr: "size", value: `"small"`, directive: "size=large"},
+		{attr: "timeout", value: `"short"`},
+		{attr: "shard_count", value: `4`, directive: "shard_count=2"},
+		{attr: "args", value: `["--manual"]`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.attr+" "+tc.directive, func(t *testing.T) {
+			existingFile, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(fmt.Sprintf(`
+# gazelle:cc_test_attrs %v
+cc_test(
+    name = "lib_test",
+    srcs = ["lib_test.cc"],
+    %v = %v,
+)
+`, tc.directive, tc.attr, tc.value)))
+			require.NoError(t, err)
+
+			c := config.New()
+			lang := NewLanguage()
+			lang.Configure(c, "lib", existingFile)
+			result := lang.GenerateRules(language.GenerateArgs{
+				Config:       c,
+				Dir:          dir,
+				Rel:          "lib",
+				File:         existingFile,
+				RegularFiles: []string{"lib_test.cc"},
+			})
+			merger.MergeFile(existingFile, result.Empty, result.Gen, merger.PreResolve, lang.Kinds(), nil)
+			merger.MergeFile(existingFile, nil, result.Gen, merger.PostResolve, lang.Kinds(), nil)
+
+			require.Len(t, existingFile.Rules, 1)
+			require.Equal(t, tc.value, bzl.FormatString(existingFile.Rules[0].Attr(tc.attr)))
+		})
+	}
+}
+
+func TestGenerateRulesBinaryImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"util.h":       "#pragma once\n#include \"base/types.h\"\n",
+		"util.cc":      "#include \"util.h\"\n#include \"base/strings.h\"\n",
+		"main.cc":      "#include \"util.h\"\n#include \"base/flags.h\"\nint main() {}\n",
+		"util_test.cc": "#include \"util.h\"\n#include <gtest/gtest.h>\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0666))
+	}
+
+	c := config.New()
+	c.Exts[languageName] = newCcConfig()
+	result := NewLanguage().GenerateRules(language.GenerateArgs{
+		Config:       c,
+		Dir:          dir,
+		Rel:          "pkg",
+		RegularFiles: slices.Sorted(maps.Keys(files)),
+	})
+
+	type splitIncludes struct{ hdrs, srcs []string }
+	includes := map[string]splitIncludes{}
+	for i, r := range result.Gen {
+		imports := result.Imports[i].(ccImports)
+		var split splitIncludes
+		for _, include := range imports.hdrIncludes {
+			split.hdrs = append(split.hdrs, include.rawPath)
+		}
+		for _, include := range imports.srcIncludes {
+			split.srcs = append(split.srcs, include.rawPath)
+		}
+		includes[r.Kind()] = split
+	}
+	// Includes of sources of binaries and tests are tracked separately from includes of headers, same as for libraries
+	require.Equal(t, map[string]splitIncludes{
+		"cc_library": {hdrs: []string{"base/types.h"}, srcs: []string{"base/strings.h"}},
+		"cc_binary":  {srcs: []string{"util.h", "base/flags.h"}},
+		"cc_test":    {srcs: []string{"util.h", "gtest/gtest.h"}},
+	}, includes)
+}
+
+func TestExtractImportsOwnHeader(t *testing.T) {
+	for _, tc := range []struct {
+		clue     string
+		file     sourceFile
+		include  parser.IncludeLocation
+		expected []string
+	}{
+		{clue: "quoted", file: "pkg/foo.cc", include: parser.IncludeLocation{Path: "foo.h", Line: 1}},
+		{clue: "quoted relative", file: "pkg/foo.cc", include: parser.IncludeLocation{Path: "./foo.h", Line: 1}},
+		{clue: "bracket", file: "pkg/foo.cc", include: parser.IncludeLocation{Path: "foo.h", Line: 1, IsSystem: true}},
+		{clue: "other header", file: "pkg/foo.cc", include: parser.IncludeLocation{Path: "bar.h", Line: 1}, expected: []string{"pkg/bar.h"}},
+		{clue: "not existing header", file: "pkg/zlib.cc", include: parser.IncludeLocation{Path: "zlib.h", Line: 1, IsSystem: true}, expected: []string{"zlib.h"}},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			c := config.New()
+			c.Exts[languageName] = newCcConfig()
+			srcInfo := ccSourceInfoSet{
+				srcs:        []sourceFile{"pkg/foo.cc", "pkg/zlib.cc"},
+				hdrs:        []sourceFile{"pkg/foo.h", "pkg/bar.h"},
+				sourceInfos: sourceInfos{tc.file: {IncludeLocations: []parser.IncludeLocation{tc.include}}},
+			}
+			imports := extractImports(language.GenerateArgs{Config: c, Rel: "pkg"}, []sourceFile{tc.file}, srcInfo)
+			var includes []string
+			for _, include := range imports.srcIncludes {
+				includes = append(includes, include.normalizedPath)
+			}
+			require.Equal(t, tc.expected, includes)
+		})
+	}
+}
+
+func TestInferIncludePrefixes(t *testing.T) {
+	fooSearch := ccSearch{stripIncludePrefix: "foo", includePrefix: "third_party/foo"}
+	for _, tc := range []struct {
+		clue          string
+		searches      []ccSearch
+		rel           string
+		expectedStrip string
+		expectedAdd   string
+		expectedOk    bool
+	}{
+		{clue: "Default search path", searches: defaultCcSearch(), rel: "third_party/foo"},
+		{clue: "Package outside of search path", searches: []ccSearch{{}, fooSearch}, rel: "app"},
+		{clue: "Package matching search path", searches: []ccSearch{{}, fooSearch}, rel: "third_party/foo", expectedAdd: "foo", expectedOk: true},
+		{clue: "Nested package", searches: []ccSearch{{}, fooSearch}, rel: "third_party/foo/inc", expectedStrip: "/third_party/foo", expectedAdd: "foo", expectedOk: true},
+		{clue: "Only stripped prefix", searches: []ccSearch{{includePrefix: "vendor"}}, rel: "vendor/zlib", expectedStrip: "/vendor", expectedOk: true},
+		{clue: "Duplicated search paths", searches: []ccSearch{fooSearch, fooSearch}, rel: "third_party/foo/inc", expectedStrip: "/third_party/foo", expectedAdd: "foo", expectedOk: true},
+		{clue: "Inconsistent search paths", searches: []ccSearch{fooSearch, {stripIncludePrefix: "bar", includePrefix: "third_party"}}, rel: "third_party/foo"},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			strip, add, ok := inferIncludePrefixes(tc.searches, tc.rel)
+			require.Equal(t, tc.expectedOk, ok)
+			require.Equal(t, tc.expectedStrip, strip)
+			require.Equal(t, tc.expectedAdd, add)
+		})
+	}
+}
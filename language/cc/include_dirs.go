@@ -0,0 +1,64 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+// Directory which headers are all provided by a single rule, defined using '# gazelle:cc_include_dir'
+type ccIncludeDir struct {
+	// Path of the directory relative to the repository root
+	dir   string
+	label label.Label
+	// Headers found in the directory when the directive was configured, relative to the directory
+	headers map[string]bool
+}
+
+// Enumerates headers of the directory located relative to the repository root
+func loadIncludeDir(repoRoot string, dir string, l label.Label) (ccIncludeDir, error) {
+	includeDir := ccIncludeDir{dir: dir, label: l, headers: make(map[string]bool)}
+	root := filepath.Join(repoRoot, filepath.FromSlash(dir))
+	err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !(hasMatchingExtension(file, headerExtensions) || hasMatchingExtension(file, textualHeaderExtensions)) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		includeDir.headers[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return includeDir, err
+}
+
+// Checks if the include path refers to one of the headers in the directory, either using a path relative to the repository root
+// or treating the directory as an include root, e.g. 'sdk/api.h' and 'api.h' for 'sdk/include/api.h' in 'sdk/include'
+func (includeDir ccIncludeDir) contains(includePath string) bool {
+	includePath = path.Clean(includePath)
+	if rel, ok := strings.CutPrefix(includePath, includeDir.dir+"/"); ok && includeDir.headers[rel] {
+		return true
+	}
+	return includeDir.headers[includePath]
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// Library generated in a package using '# gazelle:cc_emit_includes', which headers could be included relative to one of its parent directories
+type includeRootCandidate struct {
+	from label.Label
+	// Rule that would define the 'includes' attribute after generated rules are merged into the build file
+	rule *rule.Rule
+	// Directory relative to the package, that would be added to 'includes' attribute
+	dir string
+}
+
+// Records include paths of headers of generated libraries relative to each of their parent directories, excluding the repository root and the package directory.
+// Such paths are available only when the directory is listed in the 'includes' attribute of the library.
+func (lang *ccLanguage) recordIncludeRoots(args language.GenerateArgs, generated []*rule.Rule) {
+	if !getCcConfig(args.Config).emitIncludes {
+		return
+	}
+	for _, genRule := range generated {
+		if resolveCCRuleKind(genRule.Kind(), args.Config) != "cc_library" {
+			continue
+		}
+		from := label.New(args.Config.RepoName, args.Rel, genRule.Name())
+		mergeTarget := findMergeTarget(args, genRule)
+		for _, hdr := range genRule.AttrStrings("hdrs") {
+			hdrRel := path.Join(args.Rel, hdr)
+			for dir := path.Dir(hdrRel); dir != "."; dir = path.Dir(dir) {
+				includeDir := relativeDir(args.Rel, dir)
+				if includeDir == "." {
+					// Bare names of headers in the package directory would match unrelated headers, e.g. system headers
+					continue
+				}
+				includePath := strings.TrimPrefix(hdrRel, dir+"/")
+				lang.includeRootCandidates[includePath] = append(lang.includeRootCandidates[includePath], includeRootCandidate{from: from, rule: mergeTarget, dir: includeDir})
+			}
+		}
+	}
+}
+
+// Returns the path of the directory relative to the package directory, possibly ascending above the package
+func relativeDir(pkg string, dir string) string {
+	ascend := ""
+	for pkg != "" && pkg != dir && !strings.HasPrefix(dir, pkg+"/") {
+		pkg = path.Dir(pkg)
+		if pkg == "." {
+			pkg = ""
+		}
+		ascend = path.Join(ascend, "..")
+	}
+	return path.Join(".", ascend, strings.TrimPrefix(strings.TrimPrefix(dir, pkg), "/"))
+}
+
+// Resolves the include that could not be resolved otherwise to the only library which header would be available under its path
+// after adding one of its parent directories to the 'includes' attribute of the library. The directory is added after resolution.
+func (lang *ccLanguage) resolveIncludeRoot(from label.Label, include ccInclude) (label.Label, bool) {
+	candidates := lang.includeRootCandidates[path.Clean(include.rawPath)]
+	if len(candidates) != 1 || candidates[0].from == from {
+		// Ambiguous include paths are not resolved, these likely refer to headers of other libraries or the toolchain
+		return label.NoLabel, false
+	}
+	candidate := candidates[0]
+	if !slices.Contains(lang.requiredIncludes[candidate.rule], candidate.dir) {
+		lang.requiredIncludes[candidate.rule] = append(lang.requiredIncludes[candidate.rule], candidate.dir)
+	}
+	return candidate.from, true
+}
+
+// Adds directories required to resolve includes of other rules to the 'includes' attribute of libraries, keeping the existing entries
+func (lang *ccLanguage) emitRequiredIncludes() {
+	for r, dirs := range lang.requiredIncludes {
+		includes := r.AttrStrings("includes")
+		for _, dir := range dirs {
+			if !slices.Contains(includes, dir) {
+				includes = append(includes, dir)
+			}
+		}
+		slices.Sort(includes)
+		r.SetAttr("includes", includes)
+	}
+}
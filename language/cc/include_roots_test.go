@@ -0,0 +1,73 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelativeDir(t *testing.T) {
+	for _, tc := range []struct {
+		pkg      string
+		dir      string
+		expected string
+	}{
+		{pkg: "libs/util", dir: "libs/util", expected: "."},
+		{pkg: "libs/util", dir: "libs/util/include", expected: "include"},
+		{pkg: "libs/util", dir: "libs", expected: ".."},
+		{pkg: "libs/util", dir: "other", expected: "../../other"},
+		{pkg: "", dir: "include", expected: "include"},
+	} {
+		require.Equal(t, tc.expected, relativeDir(tc.pkg, tc.dir), "pkg=%q dir=%q", tc.pkg, tc.dir)
+	}
+}
+
+func TestResolveIncludeRoot(t *testing.T) {
+	util := rule.NewRule("cc_library", "util")
+	other := rule.NewRule("cc_library", "other")
+	utilLabel := label.New("", "libs/util", "util")
+	otherLabel := label.New("", "other/util", "other")
+	app := label.New("", "app", "app")
+	lang := &ccLanguage{
+		includeRootCandidates: map[string][]includeRootCandidate{
+			"util/strings.h": {{from: utilLabel, rule: util, dir: ".."}},
+			"util/common.h": {
+				{from: utilLabel, rule: util, dir: ".."},
+				{from: otherLabel, rule: other, dir: ".."},
+			},
+		},
+		requiredIncludes: make(map[*rule.Rule][]string),
+	}
+
+	resolved, ok := lang.resolveIncludeRoot(app, ccInclude{rawPath: "util/common.h"})
+	require.False(t, ok, "ambiguous include paths should not be resolved")
+	require.Equal(t, label.NoLabel, resolved)
+
+	_, ok = lang.resolveIncludeRoot(utilLabel, ccInclude{rawPath: "util/strings.h"})
+	require.False(t, ok, "headers of the library should not be resolved to itself")
+
+	resolved, ok = lang.resolveIncludeRoot(app, ccInclude{rawPath: "util/strings.h"})
+	require.True(t, ok)
+	require.Equal(t, utilLabel, resolved)
+
+	util.SetAttr("includes", []string{"include"})
+	lang.emitRequiredIncludes()
+	require.Equal(t, []string{"..", "include"}, util.AttrStrings("includes"))
+	require.Nil(t, other.Attr("includes"))
+}
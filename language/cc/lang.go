@@ -15,11 +15,16 @@
 package cc
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"maps"
@@ -34,11 +39,49 @@ const languageName = "cc"
 
 type (
 	ccLanguage struct {
+		language.BaseLifecycleManager
 		// Index of header includes parsed from Bazel Central Registry
 		bzlmodBuiltInIndex ccDependencyIndex
 		// Set of missing bazel_dep modules referenced in includes but not defined
 		// Used for deduplication of missing modul_dep warnings
 		notFoundBzlModDeps map[string]bool
+		// Set after informing about template implementation files newly assigned to rules, the message is emitted only once
+		reportedTemplateHeaders bool
+		// Value of -cc_deps_report flag
+		depsReportFile string
+		// Report of dependency changes, nil unless requested using -cc_deps_report flag
+		depsReport *depsReport
+		// Value of -cc_unresolved_report flag
+		unresolvedReportFile string
+		// Report of includes that could not be resolved, nil unless requested using -cc_unresolved_report flag
+		unresolvedReport *unresolvedReport
+		// Headers of directories without a build file located in roots marked using '# gazelle:cc_external_root', keyed by the directory.
+		// These are indexed together with the closest enclosing package, visited after all of its subdirectories.
+		externalRootHeaders map[string][]string
+		// Index of headers defined by rules located in roots marked using '# gazelle:cc_external_root'
+		externalRootIndex ccDependencyIndex
+		// Number of unresolved includes reported with '# gazelle:cc_unresolved_includes error', the run fails after resolution if any
+		unresolvedIncludeErrors int
+		// Number of sources listed in multiple rules reported with '# gazelle:cc_on_duplicate_source error', the run fails after resolution if any
+		duplicateSourceErrors int
+		// Libraries generated with '# gazelle:cc_emit_includes' keyed by paths under which their headers could be included
+		// after adding one of their parent directories to the 'includes' attribute
+		includeRootCandidates map[string][]includeRootCandidate
+		// Directories added to the 'includes' attribute of rules after resolution, required to resolve includes of other rules
+		requiredIncludes map[*rule.Rule][]string
+		// Rules which dependencies are ordered after resolution, see '# gazelle:cc_deps_order'
+		orderedRules []orderedRule
+		// Rules which dependencies are deduplicated after resolution, dependencies might refer to the same rule using different labels
+		dedupedRules []dedupedRule
+		// Actual targets of 'alias' rules defined in visited packages, keyed by the label of the alias
+		aliases map[label.Label]label.Label
+		// Results of looking up import specs in the indexes shared by all the rules, memoized during the run. Nil when memoization is disabled
+		resolveCache *resolveCache
+		// Sources of directories without a build file collected using '# gazelle:cc_group unit-global', keyed by the directory.
+		// These are defined in rules of the closest enclosing package, generated after all of its subdirectories.
+		unitGlobalSources map[string]ccSourceInfoSet
+		// Value of -cc_verbose flag
+		verbose bool
 	}
 	ccInclude struct {
 		// Include path extracted from brackets or double quotes
@@ -47,23 +90,67 @@ type (
 		normalizedPath string
 		// True when include defined using brackets
 		isSystemInclude bool
+		// Position of the include directive in form of 'file:line', used for diagnostics
+		location string
+		// True when included from C source ('.c' file)
+		isCSource bool
 	}
+	// Includes are split by the kind of file for every generated rule, including binaries and tests.
+	// Only cc_library resolves them to distinct attributes, see '# gazelle:cc_implementation_deps'
 	ccImports struct {
 		// #include directives found in header files
 		hdrIncludes []ccInclude
 		// #include directives found in non-header files
 		srcIncludes []ccInclude
+		// Namespaces used in header files, see '# gazelle:cc_namespace_dep'
+		hdrNamespaces []string
+		// Namespaces used in non-header files
+		srcNamespaces []string
+		// Labels of rules that should always be added to deps, independently of includes
+		deps []label.Label
+		// Labels of rules that should never be added to deps, e.g. the library compiling the same sources as the resolved test
+		excludedDeps []label.Label
 		// TODO: module imports / exports
 	}
 	ccDependencyIndex map[string]label.Label
+	// Direct dependencies of rules defined in index files, rules without dependencies are not listed
+	ccDependencyGraph map[label.Label][]label.Label
 )
 
 const ccProtoLibraryFilesKey = "_protos"
 
+// Private attributes of cc_proto_library storing 'strip_import_prefix' and 'import_prefix' of the proto_library,
+// these change the paths under which generated headers can be included
+const (
+	ccProtoLibraryStripImportPrefixKey = "_proto_strip_import_prefix"
+	ccProtoLibraryImportPrefixKey      = "_proto_import_prefix"
+)
+
+// Suffix of header-only library name created when 'cc_split_headers' is enabled
+const splitHeadersRuleSuffix = "_headers"
+
+// Suffix of cc_test name created for sources matching 'cc_inline_test_files'
+const inlineTestRuleSuffix = "_inline_test"
+
+// Suffix of testonly cc_library name created for headers classified as tests using 'cc_classify'
+const testUtilsRuleSuffix = "_test_utils"
+
+const cudaRuleSuffix = "_cuda"
+
+// Macro defined when compiling sources matching 'cc_inline_test_files' as tests
+const inlineTestDefine = "UNIT_TEST"
+
 func NewLanguage() language.Language {
 	return &ccLanguage{
-		bzlmodBuiltInIndex: loadBuiltInBzlModDependenciesIndex(),
-		notFoundBzlModDeps: make(map[string]bool),
+		bzlmodBuiltInIndex:    loadBuiltInBzlModDependenciesIndex(),
+		notFoundBzlModDeps:    make(map[string]bool),
+		unitGlobalSources:     make(map[string]ccSourceInfoSet),
+		externalRootHeaders:   make(map[string][]string),
+		externalRootIndex:     make(ccDependencyIndex),
+		includeRootCandidates: make(map[string][]includeRootCandidate),
+		requiredIncludes:      make(map[*rule.Rule][]string),
+		aliases:               make(map[label.Label]label.Label),
+		resolveCache:          newResolveCache(),
 	}
 }
 
@@ -79,6 +166,9 @@ func (c *ccLanguage) Kinds() map[string]rule.KindInfo {
 
 	for _, commonDef := range ccRuleDefs {
 		// Attributes common to all rules
+		// Attributes that are never generated, e.g. copts, defines, local_defines, linkopts, includes or textual_hdrs, are intentionally not mergeable.
+		// Gazelle preserves values of non-mergeable attributes verbatim, declaring them as mergeable would remove manually added values from existing rules.
+		// The same applies to attributes of cc_test defined using 'cc_test_attrs' directive or 'gazelle:test_args' comments, these are assigned only to rules not defining them yet.
 		kindInfo := rule.KindInfo{
 			NonEmptyAttrs:  map[string]bool{"srcs": true, "deps": true},
 			MergeableAttrs: map[string]bool{"srcs": true, "deps": true},
@@ -88,8 +178,10 @@ func (c *ccLanguage) Kinds() map[string]rule.KindInfo {
 		case "cc_library":
 			kindInfo.NonEmptyAttrs = mergeMaps(kindInfo.NonEmptyAttrs, map[string]bool{
 				"hdrs":                true,
+				"textual_hdrs":        true,
 				"implementation_deps": true,
 			})
+			// textual_hdrs is assigned only to rules not defining it yet, hand-written values are never modified
 			kindInfo.MergeableAttrs = mergeMaps(kindInfo.MergeableAttrs, map[string]bool{
 				"hdrs":                true,
 				"implementation_deps": true,
@@ -97,6 +189,15 @@ func (c *ccLanguage) Kinds() map[string]rule.KindInfo {
 			kindInfo.ResolveAttrs = mergeMaps(kindInfo.ResolveAttrs, map[string]bool{
 				"implementation_deps": true,
 			})
+		case "cc_import":
+			importAttrs := map[string]bool{
+				"hdrs":              true,
+				"static_library":    true,
+				"shared_library":    true,
+				"interface_library": true,
+			}
+			kindInfo.NonEmptyAttrs = mergeMaps(kindInfo.NonEmptyAttrs, importAttrs)
+			kindInfo.MergeableAttrs = mergeMaps(kindInfo.MergeableAttrs, importAttrs)
 		}
 		kinds[commonDef] = kindInfo
 	}
@@ -106,6 +207,14 @@ func (c *ccLanguage) Kinds() map[string]rule.KindInfo {
 		MergeableAttrs: map[string]bool{"deps": true},
 		ResolveAttrs:   map[string]bool{"deps": true},
 	}
+	for _, cudaDef := range cudaRuleDefs {
+		// Generated only when enabled using 'cc_cuda_kind' directive
+		kinds[cudaDef] = rule.KindInfo{
+			NonEmptyAttrs:  map[string]bool{"srcs": true, "hdrs": true, "deps": true},
+			MergeableAttrs: map[string]bool{"srcs": true, "hdrs": true, "deps": true},
+			ResolveAttrs:   map[string]bool{"deps": true},
+		}
+	}
 
 	return kinds
 }
@@ -118,6 +227,9 @@ var ccRuleDefs = []string{
 }
 var knownRuleKinds = append(ccRuleDefs, "cc_proto_library")
 
+// Kinds of rules defined in rules_cuda that can be selected using 'cc_cuda_kind' directive
+var cudaRuleDefs = []string{"cuda_library", "cuda_objects"}
+
 func (c *ccLanguage) Loads() []rule.LoadInfo {
 	panic("ApparentLoads should be called instead")
 }
@@ -140,13 +252,61 @@ func (*ccLanguage) ApparentLoads(moduleToApparentName func(string) string) []rul
 			Name:    fmt.Sprintf("@%s//bazel:cc_proto_library.bzl", apparentOfDefaultName("protobuf", "com_google_protobuf")),
 			Symbols: []string{"cc_proto_library"},
 		},
+		{
+			Name:    fmt.Sprintf("@%s//cuda:defs.bzl", apparentOfDefaultName("rules_cuda", "rules_cuda")),
+			Symbols: cudaRuleDefs,
+		},
 	}
 }
 func (*ccLanguage) Fix(c *config.Config, f *rule.File) {}
 
-var sourceExtensions = []string{".c", ".cc", ".cpp", ".cxx", ".c++", ".S"}
-var headerExtensions = []string{".h", ".hh", ".hpp", ".hxx"}
-var cExtensions = append(sourceExtensions, headerExtensions...)
+// language.LifecycleManager methods
+func (lang *ccLanguage) DoneGeneratingRules() {
+	// Rules are indexed only after generating all of them, memoized lookups would miss rules indexed later
+	if lang.resolveCache != nil {
+		lang.resolveCache.clear()
+	}
+	lang.reportUnclaimedUnitGlobalSources()
+}
+
+// Applies changes requiring the final state of all rules, writes the requested reports and fails the run if any errors were reported
+func (lang *ccLanguage) AfterResolvingDeps(ctx context.Context) {
+	for _, record := range lang.dedupedRules {
+		lang.dedupeDeps(record)
+	}
+	for _, record := range lang.orderedRules {
+		record.orderDeps()
+	}
+	lang.emitRequiredIncludes()
+	if lang.depsReport != nil {
+		lang.depsReport.write()
+	}
+	if lang.unresolvedReport != nil {
+		lang.unresolvedReport.write()
+	}
+	for _, err := range []error{lang.unresolvedIncludesError(), lang.duplicateSourcesError()} {
+		if err != nil {
+			log.Fatalf("gazelle_cc: %v", err)
+		}
+	}
+}
+
+var sourceExtensions = []string{".c", ".cc", ".cpp", ".cxx", ".c++", ".S", ".m", ".mm"}
+var headerExtensions = append([]string{".h", ".hh", ".hpp", ".hxx"}, templateHeaderExtensions...)
+
+// Extensions of files containing implementations of templates, these are included by other headers
+var templateHeaderExtensions = []string{".inl", ".tpp"}
+
+// Extensions of files included in the middle of other files, e.g. X-macro definitions or inline implementations, these cannot be compiled standalone
+var textualHeaderExtensions = []string{".inc", ".ipp", ".tcc", ".def"}
+var cExtensions = slices.Concat(sourceExtensions, headerExtensions, textualHeaderExtensions)
+
+// Extensions of CUDA sources and headers, these are defined in rules_cuda rules selected using 'cc_cuda_kind' directive
+var cudaSourceExtensions = []string{".cu"}
+var cudaHeaderExtensions = []string{".cuh"}
+
+// Extensions of prebuilt static and shared libraries, these are defined in cc_import rules
+var importArtifactExtensions = []string{".a", ".so", ".lib", ".dll", ".dylib"}
 
 func hasMatchingExtension(filename string, extensions []string) bool {
 	ext := filepath.Ext(filename)
@@ -162,25 +322,41 @@ func hasMatchingExtension(filename string, extensions []string) bool {
 var bzlDepHeadersIndex string
 
 func loadBuiltInBzlModDependenciesIndex() ccDependencyIndex {
-	index, err := unmarshalDependencyIndex([]byte(bzlDepHeadersIndex))
+	index, _, err := unmarshalDependencyIndex([]byte(bzlDepHeadersIndex))
 	if err != nil {
 		index = make(ccDependencyIndex)
 	}
 	return index
 }
 
-func loadDependencyIndex(file string) (ccDependencyIndex, error) {
+func loadDependencyIndex(file string) (ccDependencyIndex, ccDependencyGraph, error) {
 	data, err := os.ReadFile(file)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	return unmarshalDependencyIndex(data)
 }
 
-func unmarshalDependencyIndex(data []byte) (ccDependencyIndex, error) {
+// Parses the content of index file. Two formats are accepted:
+//   - legacy JSON object mapping include paths to labels
+//   - versioned JSON object, e.g. `{"version": 1, "mappings": {...}, "ambiguous": {...}, "deps": {...}}`, ambiguous headers are not used
+//
+// Dependencies of indexed rules are available only in the versioned format, the returned graph is empty for legacy files.
+func unmarshalDependencyIndex(data []byte) (ccDependencyIndex, ccDependencyGraph, error) {
+	var versioned struct {
+		Version  int                 `json:"version"`
+		Mappings map[string]string   `json:"mappings"`
+		Deps     map[string][]string `json:"deps"`
+	}
 	var rawLabels map[string]string
-	if err := json.Unmarshal(data, &rawLabels); err != nil {
-		return nil, err
+	// Legacy format has no numeric 'version' entry
+	if err := json.Unmarshal(data, &versioned); err == nil && versioned.Version != 0 {
+		if versioned.Version != 1 {
+			return nil, nil, fmt.Errorf("unsupported index format version %v", versioned.Version)
+		}
+		rawLabels = versioned.Mappings
+	} else if err := json.Unmarshal(data, &rawLabels); err != nil {
+		return nil, nil, err
 	}
 
 	index := make(ccDependencyIndex, len(rawLabels))
@@ -189,5 +365,142 @@ func unmarshalDependencyIndex(data []byte) (ccDependencyIndex, error) {
 			index[hdr] = decoded
 		}
 	}
-	return index, nil
+	graph := make(ccDependencyGraph, len(versioned.Deps))
+	for target, deps := range versioned.Deps {
+		decoded, err := label.Parse(target)
+		if err != nil {
+			continue
+		}
+		for _, dep := range deps {
+			if decodedDep, err := label.Parse(dep); err == nil {
+				graph[decoded] = append(graph[decoded], decodedDep)
+			}
+		}
+	}
+	return index, graph, nil
+}
+
+// Returns a new graph containing dependencies from both graphs. Repositories of labels in the other graph are translated
+// from module names, as stored in index files, to apparent names if the module is defined using bazel_dep
+func (graph ccDependencyGraph) merge(other ccDependencyGraph, moduleToApparentName func(string) string) ccDependencyGraph {
+	apparentLabel := func(l label.Label) label.Label {
+		if l.Repo != "" && moduleToApparentName != nil {
+			if apparentName := moduleToApparentName(l.Repo); apparentName != "" {
+				return label.New(apparentName, l.Pkg, l.Name)
+			}
+		}
+		return label.New(l.Repo, l.Pkg, l.Name)
+	}
+	merged := make(ccDependencyGraph, len(graph)+len(other))
+	maps.Copy(merged, graph)
+	for target, deps := range other {
+		translated := make([]label.Label, len(deps))
+		for i, dep := range deps {
+			translated[i] = apparentLabel(dep)
+		}
+		merged[apparentLabel(target)] = translated
+	}
+	return merged
+}
+
+// Returns the length of the longest path from the target to any of its transitive dependencies, 0 for rules not defined in the graph.
+// Computed depths are stored in the depths map, reused in subsequent calls. Dependency cycles are cut at the first revisited rule
+func (graph ccDependencyGraph) depth(target label.Label, depths map[label.Label]int) int {
+	if depth, ok := depths[target]; ok {
+		return depth
+	}
+	depths[target] = 0
+	depth := 0
+	for _, dep := range graph[target] {
+		depth = max(depth, graph.depth(dep, depths)+1)
+	}
+	depths[target] = depth
+	return depth
+}
+
+// Checks if the dependency is reachable from the target through any path in the graph, the target is not reachable from itself
+func (graph ccDependencyGraph) reaches(target label.Label, dependency label.Label, visited map[label.Label]bool) bool {
+	for _, dep := range graph[target] {
+		if dep == dependency {
+			return true
+		}
+		if !visited[dep] {
+			visited[dep] = true
+			if graph.reaches(dep, dependency, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Loads a file defining user provided include to label overrides.
+// Malformed entries are reported and skipped, the remaining entries are still used.
+func loadResolveOverrides(file string) (ccDependencyIndex, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	overrides, malformed, err := unmarshalResolveOverrides(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, reason := range malformed {
+		log.Printf("gazelle_cc: skipping malformed entry in cc resolve overrides %v: %v", file, reason)
+	}
+	return overrides, nil
+}
+
+// Parses the content of resolve overrides file. Two formats are accepted:
+//   - JSON object mapping include paths to labels, the same format as used by cc_indexfile
+//   - plain text, each non-empty line contains an include path and a label separated by whitespace,
+//     lines starting with '#' are treated as comments
+//
+// Returns the successfully parsed overrides and a list of reasons for rejecting malformed entries.
+func unmarshalResolveOverrides(data []byte) (ccDependencyIndex, []error, error) {
+	type entry struct {
+		location string
+		include  string
+		target   string
+	}
+	var entries []entry
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		var rawLabels map[string]string
+		if err := json.Unmarshal(trimmed, &rawLabels); err != nil {
+			return nil, nil, err
+		}
+		for _, include := range slices.Sorted(maps.Keys(rawLabels)) {
+			entries = append(entries, entry{location: fmt.Sprintf("%q", include), include: include, target: rawLabels[include]})
+		}
+	} else {
+		for idx, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			location := fmt.Sprintf("line %d", idx+1)
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				entries = append(entries, entry{location: location})
+				continue
+			}
+			entries = append(entries, entry{location: location, include: fields[0], target: fields[1]})
+		}
+	}
+
+	overrides := make(ccDependencyIndex, len(entries))
+	var malformed []error
+	for _, e := range entries {
+		if e.include == "" || e.target == "" {
+			malformed = append(malformed, fmt.Errorf("%v: expected an include path and a label", e.location))
+			continue
+		}
+		decoded, err := label.Parse(e.target)
+		if err != nil {
+			malformed = append(malformed, fmt.Errorf("%v: invalid label %q: %w", e.location, e.target, err))
+			continue
+		}
+		overrides[path.Clean(e.include)] = decoded
+	}
+	return overrides, malformed, nil
 }
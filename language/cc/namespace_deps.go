@@ -0,0 +1,52 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+// Checks if name is a sequence of identifiers separated by '::', e.g. 'foo::bar'
+func isQualifiedNamespace(name string) bool {
+	for _, part := range strings.Split(name, "::") {
+		if part == "" {
+			return false
+		}
+		for i, char := range part {
+			if !(char == '_' || unicode.IsLetter(char) || (i > 0 && unicode.IsDigit(char))) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Finds the dependency mapped using '# gazelle:cc_namespace_dep' to the namespace or the closest of its enclosing namespaces,
+// e.g. mapping of 'foo::bar' is used for 'foo::bar::detail'
+func (conf *ccConfig) namespaceDep(namespace string) (label.Label, bool) {
+	for {
+		if dep, exists := conf.namespaceDeps[namespace]; exists {
+			return dep, true
+		}
+		idx := strings.LastIndex(namespace, "::")
+		if idx < 0 {
+			return label.NoLabel, false
+		}
+		namespace = namespace[:idx]
+	}
+}
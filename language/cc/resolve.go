@@ -15,9 +15,12 @@
 package cc
 
 import (
+	"fmt"
 	"log"
 	"maps"
+	"os"
 	"path"
+	"path/filepath"
 	"slices"
 	"strings"
 
@@ -41,15 +44,26 @@ func (*ccLanguage) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resol
 			break
 		}
 		protos := r.PrivateAttr(ccProtoLibraryFilesKey).([]string)
+		// Generated headers are available under the same paths as .proto files, adjusted using the import prefixes
+		stripImportPrefix, _ := r.PrivateAttr(ccProtoLibraryStripImportPrefixKey).(string)
+		if stripImportPrefix != "" {
+			stripImportPrefix = path.Clean(stripImportPrefix)
+		}
+		importPrefix, _ := r.PrivateAttr(ccProtoLibraryImportPrefixKey).(string)
+		if importPrefix != "" {
+			importPrefix = path.Clean(importPrefix)
+		}
 		imports = make([]resolve.ImportSpec, len(protos))
 		for i, protoFile := range protos {
 			if baseFileName, isProto := strings.CutSuffix(protoFile, ".proto"); isProto {
-				generatedHeaderName := baseFileName + ".pb.h"
-				imports[i] = resolve.ImportSpec{Lang: languageName, Imp: path.Join(f.Pkg, generatedHeaderName)}
+				generatedHeader := path.Join(f.Pkg, baseFileName+".pb.h")
+				imports[i] = resolve.ImportSpec{Lang: languageName, Imp: transformIncludePath(f.Pkg, stripImportPrefix, importPrefix, generatedHeader)}
 			}
 		}
 	default:
-		hdrs := r.AttrStrings("hdrs")
+		// Textual headers are not compiled standalone, but dependents can include them the same as hdrs.
+		// Files listed in 'additional_compiler_inputs' are available only to the rule itself and are not indexed
+		hdrs := slices.Concat(r.AttrStrings("hdrs"), r.AttrStrings("textual_hdrs"))
 		stripIncludePrefix := r.AttrString("strip_include_prefix")
 		if stripIncludePrefix != "" {
 			stripIncludePrefix = path.Clean(stripIncludePrefix)
@@ -58,17 +72,43 @@ func (*ccLanguage) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resol
 		if includePrefix != "" {
 			includePrefix = path.Clean(includePrefix)
 		}
-		imports = make([]resolve.ImportSpec, len(hdrs))
-		for i, hdr := range hdrs {
-			hdrRel := path.Join(f.Pkg, hdr)
-			inc := transformIncludePath(f.Pkg, stripIncludePrefix, includePrefix, hdrRel)
-			imports[i] = resolve.ImportSpec{Lang: languageName, Imp: inc}
+		includeDirs := r.AttrStrings("includes")
+		imports = make([]resolve.ImportSpec, 0, len(hdrs))
+		for _, hdr := range hdrs {
+			hdrRel := ruleFilePath(f.Pkg, hdr)
+			for _, inc := range possibleIncludePaths(f.Pkg, stripIncludePrefix, includePrefix, includeDirs, hdrRel) {
+				imports = append(imports, resolve.ImportSpec{Lang: languageName, Imp: inc})
+			}
 		}
 	}
 
 	return imports
 }
 
+// possibleIncludePaths returns all paths under which the header file might be included
+// when depending on the library, mirroring the indexing of external dependencies.
+// The header is always available using the path transformed by transformIncludePath and its repo-root-relative path.
+// When only include_prefix is set Bazel strips the package path before adding the prefix.
+// Paths relative to each of the directories listed in `includes` attribute are also available.
+func possibleIncludePaths(libRel, stripIncludePrefix, includePrefix string, includeDirs []string, hdrRel string) []string {
+	paths := []string{transformIncludePath(libRel, stripIncludePrefix, includePrefix, hdrRel)}
+	addPath := func(p string) {
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	addPath(hdrRel)
+	if includePrefix != "" && stripIncludePrefix == "" {
+		addPath(transformIncludePath(libRel, ".", includePrefix, hdrRel))
+	}
+	for _, includeDir := range includeDirs {
+		if rel := pathtools.TrimPrefix(hdrRel, path.Join(libRel, includeDir)); rel != hdrRel && rel != "" {
+			addPath(rel)
+		}
+	}
+	return paths
+}
+
 // transformIncludePath converts a path to a header file into a string by which the
 // header file may be included, accounting for the library's
 // strip_include_prefix and include_prefix attributes.
@@ -110,26 +150,107 @@ func (lang *ccLanguage) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *rep
 		return
 	}
 	ccImports := imports.(ccImports)
+	// Existing rules matching 'cc_unmanaged' patterns are never generated, these are not resolved.
+	// Newly generated rules are always resolved, even if their name matches one of the patterns
+	conf := getCcConfig(c)
+
+	// Files assigned to the resolved rule, these never create a dependency, even if they're indexed or mapped to other rules.
+	// Sources of the rule might be different than when it was first indexed, e.g. after merging rules creating a cyclic dependency
+	ownFiles := make(map[string]bool)
+	for _, attr := range []string{"srcs", "hdrs"} {
+		for _, file := range r.AttrStrings(attr) {
+			ownFiles[ruleFilePath(from.Pkg, file)] = true
+		}
+	}
+	self := from.Rel(from.Repo, from.Pkg)
+	// Number of includes that could not be resolved to any rule so far, excluding likely headers of the toolchain
+	unresolvedIncludes := 0
+
+	// Resolves include to the label of rule providing it, relative to the resolved rule.
+	// Returns false if include should not create a dependency or cannot be resolved
+	resolveInclude := func(include ccInclude) (label.Label, bool) {
+		if conf.isIgnoredInclude(include) || ownFiles[include.normalizedPath] {
+			return label.NoLabel, false
+		}
+		if include.isSystemInclude && conf.bracketIncludesMode == localBracketIncludes && ownFiles[path.Join(from.Pkg, include.rawPath)] {
+			return label.NoLabel, false
+		}
+		var resolvedLabel label.Label
+		switch {
+		case conf.isNoResolveInclude(include):
+			// Only mappings explicitly defined by the user are used for such includes
+			resolvedLabel, _ = resolveExplicitMapping(c, resolve.ImportSpec{Lang: languageName, Imp: include.rawPath})
+		case isLabelInclude(include.rawPath):
+			// Label-form includes refer to the header directly, bypassing path-based matching
+			resolvedLabel = lang.resolveLabelInclude(c, ix, from, include)
+		default:
+			resolvedLabel = lang.resolveIncludePath(c, ix, from, include)
+			if resolvedLabel == label.NoLabel && !include.isSystemInclude {
+				// Retry using headers of generated libraries that would become available after extending their 'includes' attribute
+				resolvedLabel, _ = lang.resolveIncludeRoot(from, include)
+			}
+			if resolvedLabel == label.NoLabel && !include.isSystemInclude && conf.defaultDep != label.NoLabel {
+				// Fallback to the catch-all library, e.g. during incremental migration of the repository
+				resolvedLabel = conf.defaultDep
+			}
+		}
+		if resolvedLabel == label.NoLabel {
+			// We typically can get here is given file does not exists or if is assigned to the resolved rule
+			// Bracketed includes without a directory typically refer to the standard library, e.g. <vector>
+			isUnresolved := !conf.isNoResolveInclude(include) && (!include.isSystemInclude || strings.Contains(include.rawPath, "/"))
+			if lang.unresolvedReport != nil && isUnresolved {
+				lang.unresolvedReport.record(from, resolve.ImportSpec{Lang: languageName, Imp: include.rawPath})
+			}
+			lang.reportUnresolvedInclude(conf, from, include)
+			if isUnresolved {
+				unresolvedIncludes++
+			}
+			return label.NoLabel, false // failed to resolve
+		}
+		resolvedLabel = resolvedLabel.Rel(from.Repo, from.Pkg)
+		return resolvedLabel, resolvedLabel != self
+	}
+
+	kind := resolveCCRuleKind(r.Kind(), c)
+	// Dependencies resolved from includes of each of the source files, collected only when needed for diagnostics
+	var depsBySource map[string][]label.Label
+	shouldSuggestUnitSplit := conf.suggestUnitSplits && conf.groupingMode.groupsByUnits() && kind == "cc_library"
+	shouldSuggestImplementationDeps := conf.suggestImplDeps && !conf.implementationDeps && kind == "cc_library"
+	if shouldSuggestUnitSplit || shouldSuggestImplementationDeps {
+		depsBySource = make(map[string][]label.Label)
+	}
 
 	type labelsSet map[label.Label]struct{}
-	// Resolves given includes to rule labels and assigns them to given attribute.
+	// Resolves given includes to rule labels and assigns them, together with initial labels, to given attribute.
+	// When some of the includes cannot be resolved, dependencies mapped to used namespaces are assigned as well.
 	// Excludes explicitly provided labels from being assigned
 	// Returns a set of successfully assigned labels, allowing to exclude them in following invocations
-	resolveIncludes := func(includes []ccInclude, attributeName string, excluded labelsSet) labelsSet {
+	resolveIncludes := func(includes []ccInclude, namespaces []string, initial []label.Label, attributeName string, excluded labelsSet) labelsSet {
 		deps := make(map[label.Label]struct{})
+		for _, dep := range initial {
+			deps[dep.Rel(from.Repo, from.Pkg)] = struct{}{}
+		}
+		unresolvedBefore := unresolvedIncludes
 		for _, include := range includes {
-			resolvedLabel := lang.resolveImportSpec(c, ix, from, resolve.ImportSpec{Lang: languageName, Imp: include.normalizedPath})
-			if resolvedLabel == label.NoLabel && !include.isSystemInclude {
-				// Retry to resolve is external dependency was defined using quotes instead of braces
-				resolvedLabel = lang.resolveImportSpec(c, ix, from, resolve.ImportSpec{Lang: languageName, Imp: include.rawPath})
-			}
-			if resolvedLabel == label.NoLabel {
-				// We typically can get here is given file does not exists or if is assigned to the resolved rule
-				continue // failed to resolve
+			if resolvedLabel, ok := resolveInclude(include); ok {
+				if _, isExcluded := excluded[resolvedLabel]; !isExcluded {
+					deps[resolvedLabel] = struct{}{}
+				}
+				if depsBySource != nil {
+					file := include.location[:strings.LastIndex(include.location, ":")]
+					depsBySource[file] = append(depsBySource[file], resolvedLabel)
+				}
 			}
-			resolvedLabel = resolvedLabel.Rel(from.Repo, from.Pkg)
-			if _, isExcluded := excluded[resolvedLabel]; !isExcluded {
-				deps[resolvedLabel] = struct{}{}
+		}
+		if unresolvedIncludes > unresolvedBefore {
+			// Used namespaces hint at dependencies providing the headers that could not be resolved
+			for _, namespace := range namespaces {
+				if dep, ok := conf.namespaceDep(namespace); ok {
+					dep = dep.Rel(from.Repo, from.Pkg)
+					if _, isExcluded := excluded[dep]; !isExcluded && dep != self {
+						deps[dep] = struct{}{}
+					}
+				}
 			}
 		}
 		if len(deps) > 0 {
@@ -140,35 +261,223 @@ func (lang *ccLanguage) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *rep
 		return deps
 	}
 
-	switch resolveCCRuleKind(r.Kind(), c) {
-	case "cc_library":
+	excludedDeps := make(labelsSet)
+	for _, dep := range ccImports.excludedDeps {
+		excludedDeps[dep.Rel(from.Repo, from.Pkg)] = struct{}{}
+	}
+	switch {
+	case kind == "cc_library" && conf.implementationDeps:
 		// Only cc_library has 'implementation_deps' attribute
 		// If depenedncy is added by header (via 'deps') ensure it would not be duplicated inside 'implementation_deps'
-		publicDeps := resolveIncludes(ccImports.hdrIncludes, "deps", make(labelsSet))
-		resolveIncludes(ccImports.srcIncludes, "implementation_deps", publicDeps)
+		publicDeps := resolveIncludes(ccImports.hdrIncludes, ccImports.hdrNamespaces, ccImports.deps, "deps", excludedDeps)
+		maps.Copy(publicDeps, excludedDeps)
+		resolveIncludes(ccImports.srcIncludes, ccImports.srcNamespaces, nil, "implementation_deps", publicDeps)
 	default:
 		includes := slices.Concat(ccImports.hdrIncludes, ccImports.srcIncludes)
-		resolveIncludes(includes, "deps", make(labelsSet))
+		namespaces := slices.Concat(ccImports.hdrNamespaces, ccImports.srcNamespaces)
+		resolveIncludes(includes, namespaces, ccImports.deps, "deps", excludedDeps)
+	}
+	if shouldSuggestUnitSplit {
+		suggestUnitSplit(from, r, depsBySource)
+	}
+	if shouldSuggestImplementationDeps {
+		suggestImplementationDeps(from, conf.dependencyGraph, ccImports.deps, depsBySource)
+	}
+}
+
+// Reports a warning if some of dependencies of the library are used only by its sources, but are not reachable through dependencies used by its headers.
+// Such dependencies are exposed to dependants of the library only because they're assigned to 'deps', using 'implementation_deps' would hide them.
+// Reachability is checked using the dependency graph of index files, dependencies of first-party rules are not known.
+func suggestImplementationDeps(from label.Label, graph ccDependencyGraph, initial []label.Label, depsBySource map[string][]label.Label) {
+	absolute := func(l label.Label) label.Label {
+		l = l.Abs(from.Repo, from.Pkg)
+		return label.New(l.Repo, l.Pkg, l.Name)
+	}
+	publicDeps := make(map[label.Label]bool)
+	for _, dep := range initial {
+		publicDeps[absolute(dep)] = true
+	}
+	sourceDeps := make(map[label.Label]label.Label)
+	for file, deps := range depsBySource {
+		source := sourceFile(file)
+		for _, dep := range deps {
+			if source.isHeader() || source.isTextualHeader() {
+				publicDeps[absolute(dep)] = true
+			} else {
+				sourceDeps[absolute(dep)] = dep
+			}
+		}
+	}
+
+	violations := []string{}
+	for dep, relative := range sourceDeps {
+		if publicDeps[dep] {
+			continue
+		}
+		reachable := false
+		for publicDep := range publicDeps {
+			if graph.reaches(publicDep, dep, make(map[label.Label]bool)) {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			violations = append(violations, relative.String())
+		}
+	}
+	if len(violations) == 0 {
+		return
+	}
+	slices.Sort(violations)
+	log.Printf("%v: dependencies %v are used only by sources and not reachable through dependencies of headers, consider assigning them to 'implementation_deps' using '# gazelle:cc_implementation_deps on'", from, strings.Join(violations, ", "))
+}
+
+// Reports a warning if some of dependencies of the library are used only by a subset of its translation units - sources sharing the same name without extension.
+// Splitting such library into multiple rules would allow the remaining units to not depend on them.
+func suggestUnitSplit(from label.Label, r *rule.Rule, depsBySource map[string][]label.Label) {
+	unitOf := func(file string) string {
+		return strings.TrimSuffix(file, path.Ext(file))
+	}
+	units := make(map[string]bool)
+	for _, attr := range []string{"srcs", "hdrs"} {
+		for _, file := range r.AttrStrings(attr) {
+			units[unitOf(ruleFilePath(from.Pkg, file))] = true
+		}
+	}
+	if len(units) < 2 {
+		return
+	}
+
+	usedBy := make(map[label.Label]map[string]bool)
+	for file, deps := range depsBySource {
+		unit := unitOf(file)
+		if !units[unit] {
+			continue
+		}
+		for _, dep := range deps {
+			if usedBy[dep] == nil {
+				usedBy[dep] = make(map[string]bool)
+			}
+			usedBy[dep][unit] = true
+		}
 	}
+	unitDeps := make(map[string][]string)
+	for dep, usingUnits := range usedBy {
+		if len(usingUnits) == len(units) {
+			continue
+		}
+		for unit := range usingUnits {
+			unitDeps[unit] = append(unitDeps[unit], dep.String())
+		}
+	}
+	if len(unitDeps) == 0 {
+		return
+	}
+	suggestions := []string{}
+	for _, unit := range slices.Sorted(maps.Keys(unitDeps)) {
+		deps := unitDeps[unit]
+		slices.Sort(deps)
+		suggestions = append(suggestions, fmt.Sprintf("%v uses %v", strings.TrimPrefix(unit, from.Pkg+"/"), strings.Join(deps, ", ")))
+	}
+	log.Printf("%v: dependencies are not used by all translation units, consider splitting the library to reduce them: %v", from, strings.Join(suggestions, "; "))
 }
 
-func (lang *ccLanguage) resolveImportSpec(c *config.Config, ix *resolve.RuleIndex, from label.Label, importSpec resolve.ImportSpec) label.Label {
+// Resolves the include to the label of rule providing it using its normalized path, its raw path and paths translated by 'cc_search' directives.
+// Results are memoized unless the cache is disabled, see resolveCache
+func (lang *ccLanguage) resolveIncludePath(c *config.Config, ix *resolve.RuleIndex, from label.Label, include ccInclude) label.Label {
 	conf := getCcConfig(c)
-	// Resolve the gazele:resolve overrides if defined
-	if resolvedLabel, ok := resolve.FindRuleWithOverride(c, importSpec, languageName); ok {
-		return resolvedLabel
+	key := resolveCacheKey{
+		scope:           conf.resolveScope,
+		rawPath:         include.rawPath,
+		normalizedPath:  include.normalizedPath,
+		isSystemInclude: include.isSystemInclude,
+		isCSource:       include.isCSource,
+	}
+	if include.isSystemInclude && conf.bracketIncludesMode == localBracketIncludes {
+		key.pkg = from.Pkg
+	}
+	if lang.resolveCache != nil {
+		if entry, exists := lang.resolveCache.get(key); exists && !entry.isSelfImport(from) {
+			return entry.label
+		}
+	}
+	entry, skippedSelfImport := lang.lookupIncludePath(c, ix, from, include)
+	if lang.resolveCache != nil && !skippedSelfImport {
+		// Results found after skipping self imports are valid only for the resolved rule
+		lang.resolveCache.put(key, entry)
+	}
+	return entry.label
+}
+
+// Looks up the rule providing the include using each of its possible paths, the first path resolved to a rule is used.
+// Returns true if some of the rules providing the include were skipped as self imports of the resolved rule
+func (lang *ccLanguage) lookupIncludePath(c *config.Config, ix *resolve.RuleIndex, from label.Label, include ccInclude) (resolveCacheEntry, bool) {
+	conf := getCcConfig(c)
+	importPaths := []string{include.normalizedPath}
+	if !include.isSystemInclude {
+		// Retry to resolve is external dependency was defined using quotes instead of braces
+		importPaths = append(importPaths, include.rawPath)
+	}
+	if include.isSystemInclude && conf.bracketIncludesMode == localBracketIncludes {
+		// Retry to resolve first-party header relative to the including file defined using braces instead of quotes
+		importPaths = append(importPaths, path.Join(from.Pkg, include.rawPath))
+	}
+	// Retry using paths translated by 'cc_search' directives, e.g. first-party library exposing its headers using a stripped prefix.
+	for _, search := range conf.ccSearch {
+		translated, matches := search.includedFile(include.rawPath)
+		if matches && string(translated) != include.rawPath && string(translated) != include.normalizedPath {
+			importPaths = append(importPaths, string(translated))
+		}
+	}
+	skippedSelfImport := false
+	for _, importPath := range importPaths {
+		entry, skipped := lang.resolveImportSpec(c, ix, from, resolve.ImportSpec{Lang: languageName, Imp: importPath}, include.isCSource)
+		skippedSelfImport = skippedSelfImport || skipped
+		if entry.label != label.NoLabel {
+			return entry, skippedSelfImport
+		}
+	}
+	return resolveCacheEntry{label: label.NoLabel}, skippedSelfImport
+}
+
+// Resolves import spec to the label of rule providing it.
+// Returns true if some of the rules providing it were skipped as self imports of the resolved rule
+func (lang *ccLanguage) resolveImportSpec(c *config.Config, ix *resolve.RuleIndex, from label.Label, importSpec resolve.ImportSpec, isCSource bool) (resolveCacheEntry, bool) {
+	conf := getCcConfig(c)
+	if resolvedLabel, ok := resolveExplicitMapping(c, importSpec); ok {
+		return resolveCacheEntry{label: resolvedLabel}, false
 	}
 
 	// Resolve using imports registered in Imports
+	skippedSelfImport := false
 	for _, searchResult := range ix.FindRulesByImportWithConfig(c, importSpec, languageName) {
-		if !searchResult.IsSelfImport(from) {
-			return searchResult.Label
+		if searchResult.IsSelfImport(from) {
+			skippedSelfImport = true
+			continue
 		}
+		return resolveCacheEntry{label: searchResult.Label, ruleIndexResult: &searchResult}, skippedSelfImport
+	}
+	if provider, exists := lang.externalRootIndex[importSpec.Imp]; exists {
+		if provider != from {
+			return resolveCacheEntry{label: provider, isExternalRoot: true}, skippedSelfImport
+		}
+		skippedSelfImport = true
 	}
 
-	for _, index := range conf.dependencyIndexes {
+	indexes := conf.dependencyIndexes
+	if isCSource {
+		// C specific indexes take precedence over the general ones
+		indexes = slices.Concat(conf.cDependencyIndexes, indexes)
+	}
+	for _, index := range indexes {
 		if label, exists := index[importSpec.Imp]; exists {
-			return label
+			// Index stores module names, translate them to apparent names if defined using bazel_dep
+			if label.Repo != "" {
+				if apparentName := c.ModuleToApparentName(label.Repo); apparentName != "" {
+					label.Repo = apparentName
+				}
+			}
+			return resolveCacheEntry{label: label}, skippedSelfImport
 		}
 	}
 
@@ -177,7 +486,7 @@ func (lang *ccLanguage) resolveImportSpec(c *config.Config, ix *resolve.RuleInde
 		// Empty apparentName means that there is no such a repository added by bazel_dep
 		if apparantName != "" {
 			label.Repo = apparantName
-			return label
+			return resolveCacheEntry{label: label}, skippedSelfImport
 		}
 		if _, exists := lang.notFoundBzlModDeps[label.Repo]; !exists {
 			// Warn only once per missing module_dep
@@ -186,5 +495,104 @@ func (lang *ccLanguage) resolveImportSpec(c *config.Config, ix *resolve.RuleInde
 		}
 	}
 
+	return resolveCacheEntry{label: label.NoLabel}, skippedSelfImport
+}
+
+// Returns repository root relative path of the file assigned to the rule defined in pkg.
+// Files might be referenced using labels, possibly to other packages, e.g. when sources of a single library span multiple packages
+// or headers are exported from other packages using 'exports_files'. Labels might refer to the main repository explicitly, e.g. '@//pkg:foo.h'
+func ruleFilePath(pkg string, file string) string {
+	if strings.HasPrefix(file, "//") || strings.HasPrefix(file, ":") || strings.HasPrefix(file, "@//") || strings.HasPrefix(file, "@@//") {
+		if l, err := label.Parse(file); err == nil {
+			if l.Relative {
+				return path.Join(pkg, l.Name)
+			}
+			return path.Join(l.Pkg, l.Name)
+		}
+	}
+	return path.Join(pkg, file)
+}
+
+// Resolves import using mappings explicitly defined by the user
+func resolveExplicitMapping(c *config.Config, importSpec resolve.ImportSpec) (label.Label, bool) {
+	// Resolve using overrides loaded from cc_resolve_file, these take precedence over any other mapping
+	for _, overrides := range getCcConfig(c).resolveOverrides {
+		if label, exists := overrides[importSpec.Imp]; exists {
+			return label, true
+		}
+	}
+
+	// Resolve the gazele:resolve overrides if defined
+	if label, ok := resolve.FindRuleWithOverride(c, importSpec, languageName); ok {
+		return label, true
+	}
+
+	// Resolve headers of directories mapped using cc_include_dir, directories defined later are more specific
+	includeDirs := getCcConfig(c).includeDirs
+	for i := len(includeDirs) - 1; i >= 0; i-- {
+		if includeDirs[i].contains(importSpec.Imp) {
+			return includeDirs[i].label, true
+		}
+	}
+	return label.NoLabel, false
+}
+
+// Checks if the include refers to a Bazel label instead of a file path, e.g. `#include "@repo//pkg:hdr.h"`
+func isLabelInclude(include string) bool {
+	return strings.HasPrefix(include, "@") || strings.HasPrefix(include, "//")
+}
+
+// Resolves label-form include to the label of rule providing the referenced header.
+// Headers of the main repository are resolved using the rule index, headers of external repositories using the dependency indexes.
+// Returns label.NoLabel if the label is invalid, refers to repository or package that does not exist, or no rule provides the header.
+func (lang *ccLanguage) resolveLabelInclude(c *config.Config, ix *resolve.RuleIndex, from label.Label, include ccInclude) label.Label {
+	headerLabel, err := label.Parse(include.rawPath)
+	if err != nil {
+		log.Printf("%v: %v: Invalid label in '#include %v': %v", from, include.location, include.rawPath, err)
+		return label.NoLabel
+	}
+	headerPath := path.Join(headerLabel.Pkg, headerLabel.Name)
+	if headerLabel.Repo != "" && headerLabel.Repo != c.RepoName {
+		apparentName := c.ModuleToApparentName(headerLabel.Repo)
+		if apparentName == "" {
+			log.Printf("%v: %v: '#include %v' refers to repository @%v, but 'bazel_dep(name = \"%v\")' is missing in MODULE.bazel", from, include.location, include.rawPath, headerLabel.Repo, headerLabel.Repo)
+			return label.NoLabel
+		}
+		return lang.findExternalHeaderProvider(c, headerLabel.Repo, apparentName, headerPath, include.isCSource)
+	}
+	if info, err := os.Stat(filepath.Join(c.RepoRoot, filepath.FromSlash(headerLabel.Pkg))); err != nil || !info.IsDir() {
+		log.Printf("%v: %v: '#include %v' refers to package //%v which does not exist", from, include.location, include.rawPath, headerLabel.Pkg)
+		return label.NoLabel
+	}
+	// Every header is indexed using its repository root relative path, see possibleIncludePaths
+	importSpec := resolve.ImportSpec{Lang: languageName, Imp: headerPath}
+	if searchResults := ix.FindRulesByImportWithConfig(c, importSpec, languageName); len(searchResults) > 0 {
+		return searchResults[0].Label
+	}
+	if provider, exists := lang.externalRootIndex[headerPath]; exists {
+		return provider
+	}
+	return label.NoLabel
+}
+
+// Finds the rule of the external repository providing the header located under the repository root relative path.
+// Dependency indexes store include paths, these might omit leading directories of the path stripped using 'strip_include_prefix',
+// so the path is matched together with its suffixes, only against rules defined in the same repository.
+func (lang *ccLanguage) findExternalHeaderProvider(c *config.Config, repo string, apparentName string, headerPath string, isCSource bool) label.Label {
+	conf := getCcConfig(c)
+	indexes := conf.dependencyIndexes
+	if isCSource {
+		indexes = slices.Concat(conf.cDependencyIndexes, indexes)
+	}
+	indexes = append(indexes[:len(indexes):len(indexes)], lang.bzlmodBuiltInIndex)
+	for includePath := headerPath; includePath != ""; {
+		for _, index := range indexes {
+			if provider, exists := index[includePath]; exists && (provider.Repo == repo || provider.Repo == apparentName) {
+				provider.Repo = apparentName
+				return provider
+			}
+		}
+		_, includePath, _ = strings.Cut(includePath, "/")
+	}
 	return label.NoLabel
 }
@@ -0,0 +1,84 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"sync"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
+
+// Identifies configurations resolving includes the same way. Packages not defining any directives share the scope of their parent,
+// directives of any language, e.g. '# gazelle:resolve', 'cc_indexfile' or 'cc_search', start a new scope.
+// Only the address of the scope is used, the field guarantees that distinct scopes never share the address
+type resolveScope struct{ _ byte }
+
+// Include resolved using the path based lookups, see resolveIncludePath
+type resolveCacheKey struct {
+	scope           *resolveScope
+	rawPath         string
+	normalizedPath  string
+	isSystemInclude bool
+	isCSource       bool
+	// Package of the resolved rule, set only when bracketed includes are retried relative to the including package
+	pkg string
+}
+
+// Rule providing the include, found using the path based lookups
+type resolveCacheEntry struct {
+	label label.Label
+	// Result of the rule index the label was found in, nil if it was found in other index
+	ruleIndexResult *resolve.FindResult
+	// Label was found in the index of roots marked using '# gazelle:cc_external_root'
+	isExternalRoot bool
+}
+
+// Checks if resolving the include of given rule would skip the memoized label as its self import
+func (entry resolveCacheEntry) isSelfImport(from label.Label) bool {
+	return (entry.ruleIndexResult != nil && entry.ruleIndexResult.IsSelfImport(from)) || (entry.isExternalRoot && entry.label == from)
+}
+
+// Memoizes includes resolved using the path based lookups during a single Gazelle run, including the ones that could not be resolved.
+// The same headers are typically included by many sources of multiple rules and retried using multiple translated paths, e.g. headers of the standard library.
+// Results are shared by all the rules of packages using the same resolveScope, unless some of the providers were skipped as self imports of the resolved rule.
+type resolveCache struct {
+	mu      sync.Mutex
+	entries map[resolveCacheKey]resolveCacheEntry
+}
+
+func newResolveCache() *resolveCache {
+	return &resolveCache{entries: make(map[resolveCacheKey]resolveCacheEntry)}
+}
+
+func (cache *resolveCache) get(key resolveCacheKey) (resolveCacheEntry, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry, exists := cache.entries[key]
+	return entry, exists
+}
+
+func (cache *resolveCache) put(key resolveCacheKey, entry resolveCacheEntry) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.entries[key] = entry
+}
+
+// Drops all memoized results, these are valid only after all of the rules were indexed
+func (cache *resolveCache) clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	clear(cache.entries)
+}
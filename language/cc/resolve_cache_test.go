@@ -0,0 +1,173 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates the index of libraries 'lib/libN' providing headers 'lib/libN/libN.h' and includes of these headers,
+// repeated in multiple sources and mixed with includes that cannot be resolved
+func newResolveCacheFixture(lang *ccLanguage, conf *ccConfig, libs int) (*resolve.RuleIndex, ccImports) {
+	c := newResolveTestConfig(conf)
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	var imports ccImports
+	for i := range libs {
+		pkg := fmt.Sprintf("lib/lib%d", i)
+		file := rule.EmptyFile(pkg+"/BUILD.bazel", pkg)
+		lib := rule.NewRule("cc_library", fmt.Sprintf("lib%d", i))
+		lib.SetAttr("hdrs", []string{fmt.Sprintf("lib%d.h", i)})
+		lib.Insert(file)
+		ix.AddRule(c, lib, file)
+		for source := range 3 {
+			imports.srcIncludes = append(imports.srcIncludes,
+				ccInclude{rawPath: fmt.Sprintf("lib%d.h", i), normalizedPath: fmt.Sprintf("%v/lib%d.h", pkg, i), location: fmt.Sprintf("app/src%d.cc:%d", source, i)},
+				ccInclude{rawPath: fmt.Sprintf("missing%d.h", i), normalizedPath: fmt.Sprintf("app/missing%d.h", i), location: fmt.Sprintf("app/src%d.cc:%d", source, i)},
+			)
+		}
+	}
+	ix.Finish()
+	return ix, imports
+}
+
+func TestResolveCacheMatchesUncached(t *testing.T) {
+	conf := newCcConfig()
+	conf.ccSearch = append(conf.ccSearch, ccSearch{includePrefix: "lib/lib1"})
+	c := newResolveTestConfig(conf)
+
+	resolveDeps := func(lang *ccLanguage) [][]string {
+		ix, imports := newResolveCacheFixture(lang, conf, 5)
+		var results [][]string
+		// Rules are resolved multiple times to use memoized results
+		for range 2 {
+			for _, name := range []string{"app", "lib1"} {
+				binary := rule.NewRule("cc_binary", name)
+				binary.SetAttr("srcs", []string{"src0.cc", "src1.cc", "src2.cc"})
+				pkg := "app"
+				if name == "lib1" {
+					// Self imports are never resolved, independently of the results memoized for other rules
+					pkg = "lib/lib1"
+				}
+				lang.Resolve(c, ix, nil, binary, imports, label.New("", pkg, name))
+				results = append(results, binary.AttrStrings("deps"))
+			}
+		}
+		return results
+	}
+
+	uncached := NewLanguage().(*ccLanguage)
+	uncached.resolveCache = nil
+	expected := resolveDeps(uncached)
+	require.NotContains(t, expected[1], "//lib/lib1")
+
+	cached := NewLanguage().(*ccLanguage)
+	require.Equal(t, expected, resolveDeps(cached))
+	require.NotEmpty(t, cached.resolveCache.entries)
+}
+
+func TestResolveCacheSharedByPackages(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	conf := newCcConfig()
+	ix, imports := newResolveCacheFixture(lang, conf, 3)
+	rootConfig := newResolveTestConfig(conf)
+	resolveDeps := func(pkg string, buildFile string) []string {
+		c := rootConfig.Clone()
+		f, err := rule.LoadData(path.Join(pkg, "BUILD.bazel"), pkg, []byte(buildFile))
+		require.NoError(t, err)
+		(&resolve.Configurer{}).Configure(c, pkg, f)
+		lang.Configure(c, pkg, f)
+		binary := rule.NewRule("cc_binary", "app")
+		lang.Resolve(c, ix, nil, binary, imports, label.New("", pkg, "app"))
+		return binary.AttrStrings("deps")
+	}
+
+	require.Equal(t, []string{"//lib/lib0", "//lib/lib1", "//lib/lib2"}, resolveDeps("app", ""))
+	memoized := len(lang.resolveCache.entries)
+	require.NotZero(t, memoized)
+
+	// Packages without directives resolve includes the same way, these reuse the memoized results
+	require.Equal(t, []string{"//lib/lib0", "//lib/lib1", "//lib/lib2"}, resolveDeps("other", ""))
+	require.Equal(t, memoized, len(lang.resolveCache.entries))
+
+	// Directives might change how includes are resolved, mappings defined by the user are applied
+	require.Equal(t, []string{"//custom:lib0", "//lib/lib1", "//lib/lib2"}, resolveDeps("mapped", "# gazelle:resolve cc lib/lib0/lib0.h //custom:lib0\n"))
+	require.Equal(t, 2*memoized, len(lang.resolveCache.entries))
+
+	lang.DoneGeneratingRules()
+	require.Empty(t, lang.resolveCache.entries)
+}
+
+func TestResolveCacheSelfImports(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	conf := newCcConfig()
+	ix, _ := newResolveCacheFixture(lang, conf, 2)
+	c := newResolveTestConfig(conf)
+	// Header of lib0 is included using the repository root relative path, the path is not assigned to sources of lib0
+	imports := ccImports{srcIncludes: []ccInclude{{rawPath: "lib/lib0/lib0.h", normalizedPath: "lib/lib0/lib0.h"}}}
+
+	// Result memoized for other rule is not reused for the rule providing the header
+	app := rule.NewRule("cc_binary", "app")
+	lang.Resolve(c, ix, nil, app, imports, label.New("", "app", "app"))
+	require.Equal(t, []string{"//lib/lib0"}, app.AttrStrings("deps"))
+	lib0 := rule.NewRule("cc_library", "lib0")
+	lang.Resolve(c, ix, nil, lib0, imports, label.New("", "lib/lib0", "lib0"))
+	require.Empty(t, lib0.AttrStrings("deps"))
+	// Result found after skipping self import is not memoized
+	lang.resolveCache.clear()
+	lang.Resolve(c, ix, nil, rule.NewRule("cc_library", "lib0"), imports, label.New("", "lib/lib0", "lib0"))
+	require.Empty(t, lang.resolveCache.entries)
+}
+
+func BenchmarkResolve(b *testing.B) {
+	for _, tc := range []struct {
+		name     string
+		useCache bool
+	}{
+		{name: "uncached", useCache: false},
+		{name: "cached", useCache: true},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			conf := newCcConfig()
+			// Unresolved includes are retried using paths translated by each of the search rules, each path is looked up in all of the indexes
+			for i := range 20 {
+				conf.ccSearch = append(conf.ccSearch, ccSearch{includePrefix: fmt.Sprintf("include%d", i)})
+			}
+			for i := range 5 {
+				conf.dependencyIndexes = append(conf.dependencyIndexes, ccDependencyIndex{
+					fmt.Sprintf("vendor%d/vendor.h", i): label.New(fmt.Sprintf("vendor%d", i), "", "vendor"),
+				})
+			}
+			c := newResolveTestConfig(conf)
+			lang := NewLanguage().(*ccLanguage)
+			if !tc.useCache {
+				lang.resolveCache = nil
+			}
+			ix, imports := newResolveCacheFixture(lang, conf, 50)
+			from := label.New("", "app", "app")
+			b.ResetTimer()
+			for range b.N {
+				binary := rule.NewRule("cc_binary", "app")
+				lang.Resolve(c, ix, nil, binary, imports, from)
+			}
+		})
+	}
+}
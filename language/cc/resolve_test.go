@@ -0,0 +1,1001 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates configuration required to resolve dependencies without running the configurers for each of the directories
+func newResolveTestConfig(conf *ccConfig) *config.Config {
+	c := config.New()
+	c.ModuleToApparentName = func(string) string { return "" }
+	resolveConfigurer := &resolve.Configurer{}
+	resolveConfigurer.RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError), "update", c)
+	resolveConfigurer.Configure(c, "", nil)
+	c.Exts[languageName] = conf
+	return c
+}
+
+func TestResolveAfterCycleMerge(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	conf := newCcConfig()
+	// Index created before 'd' was merged into 'c' still refers to the no longer existing rule
+	conf.dependencyIndexes = []ccDependencyIndex{{
+		"pkg/d.h": label.New("", "pkg", "d"),
+	}}
+	c := newResolveTestConfig(conf)
+
+	pkgFile := rule.EmptyFile("pkg/BUILD.bazel", "pkg")
+	// Rule 'c' after merging with rule 'd' due to a cyclic dependency between their sources
+	merged := rule.NewRule("cc_library", "c")
+	merged.SetAttr("srcs", []string{"c.cc", "d.cc"})
+	merged.SetAttr("hdrs", []string{"c.h", "d.h"})
+	merged.Insert(pkgFile)
+
+	otherFile := rule.EmptyFile("other/BUILD.bazel", "other")
+	other := rule.NewRule("cc_library", "e")
+	other.SetAttr("hdrs", []string{"e.h"})
+	other.Insert(otherFile)
+
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.AddRule(c, merged, pkgFile)
+	ix.AddRule(c, other, otherFile)
+	ix.Finish()
+
+	imports := ccImports{
+		hdrIncludes: []ccInclude{
+			{rawPath: "d.h", normalizedPath: "pkg/d.h"},
+			{rawPath: "other/e.h", normalizedPath: "other/e.h"},
+		},
+		srcIncludes: []ccInclude{
+			{rawPath: "c.h", normalizedPath: "pkg/c.h"},
+			{rawPath: "d.h", normalizedPath: "pkg/d.h"},
+		},
+	}
+	lang.Resolve(c, ix, nil, merged, imports, label.New("", "pkg", "c"))
+
+	require.Equal(t, []string{"//other:e"}, merged.AttrStrings("deps"))
+	require.Empty(t, merged.AttrStrings("implementation_deps"))
+}
+
+func TestResolveCIndex(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	conf := newCcConfig()
+	conf.dependencyIndexes = []ccDependencyIndex{{
+		"codec/codec.h": label.New("", "third_party/codec", "codec_cpp"),
+		"json/json.h":   label.New("", "third_party/json", "json"),
+	}}
+	conf.cDependencyIndexes = []ccDependencyIndex{{
+		"codec/codec.h": label.New("", "third_party/codec", "codec_c"),
+	}}
+	c := newResolveTestConfig(conf)
+
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.Finish()
+
+	for _, tc := range []struct {
+		clue     string
+		source   string
+		expected []string
+	}{
+		{
+			clue:     "C sources use C specific index first and fall back to the general index",
+			source:   "main.c",
+			expected: []string{"//third_party/codec:codec_c", "//third_party/json"},
+		},
+		{
+			clue:     "C++ sources never use C specific index",
+			source:   "main.cc",
+			expected: []string{"//third_party/codec:codec_cpp", "//third_party/json"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			binary := rule.NewRule("cc_binary", "main")
+			binary.SetAttr("srcs", []string{tc.source})
+			isCSource := tc.source == "main.c"
+			imports := ccImports{
+				srcIncludes: []ccInclude{
+					{rawPath: "codec/codec.h", normalizedPath: "codec/codec.h", isSystemInclude: true, isCSource: isCSource},
+					{rawPath: "json/json.h", normalizedPath: "json/json.h", isSystemInclude: true, isCSource: isCSource},
+				},
+			}
+			lang.Resolve(c, ix, nil, binary, imports, label.New("", "app", "main"))
+			require.Equal(t, tc.expected, binary.AttrStrings("deps"))
+		})
+	}
+}
+
+func TestResolveDepsOrder(t *testing.T) {
+	imports := ccImports{
+		srcIncludes: []ccInclude{
+			{rawPath: "ext/core.h", normalizedPath: "ext/core.h", isSystemInclude: true},
+			{rawPath: "ext/mid.h", normalizedPath: "ext/mid.h", isSystemInclude: true},
+			{rawPath: "ext/base.h", normalizedPath: "ext/base.h", isSystemInclude: true},
+			{rawPath: "zlib.h", normalizedPath: "zlib.h", isSystemInclude: true},
+		},
+	}
+
+	for _, tc := range []struct {
+		clue     string
+		order    depsOrder
+		expected string
+	}{
+		{
+			clue:  "lexical order by default",
+			order: lexicalDepsOrder,
+			expected: `cc_binary(
+    name = "main",
+    srcs = ["main.cc"],
+    deps = [
+        "@ext//:base",
+        "@ext//:core",
+        "@ext//:mid",
+        "@zlib",
+    ],
+)
+`,
+		},
+		{
+			clue:  "leaf dependencies first, ties sorted lexically",
+			order: depthDepsOrder,
+			expected: `cc_binary(
+    name = "main",
+    srcs = ["main.cc"],
+    # do not sort
+    deps = [
+        "@ext//:base",
+        "@zlib",
+        "@ext//:mid",
+        "@ext//:core",
+    ],
+)
+`,
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			conf := newCcConfig()
+			conf.depsOrder = tc.order
+			conf.dependencyIndexes = []ccDependencyIndex{{
+				"ext/core.h": label.New("ext", "", "core"),
+				"ext/mid.h":  label.New("ext", "", "mid"),
+				"ext/base.h": label.New("ext", "", "base"),
+				"zlib.h":     label.New("zlib", "", "zlib"),
+			}}
+			// zlib is not defined in the graph, the same as leaf dependencies
+			conf.dependencyGraph = ccDependencyGraph{
+				label.New("ext", "", "core"): {label.New("ext", "", "mid"), label.New("ext", "", "base")},
+				label.New("ext", "", "mid"):  {label.New("ext", "", "base")},
+			}
+			c := newResolveTestConfig(conf)
+			lang := NewLanguage().(*ccLanguage)
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.Finish()
+
+			file := rule.EmptyFile("app/BUILD.bazel", "app")
+			binary := rule.NewRule("cc_binary", "main")
+			binary.SetAttr("srcs", []string{"main.cc"})
+			lang.recordOrderedRules(language.GenerateArgs{Config: c, Rel: "app"}, []*rule.Rule{binary})
+			binary.Insert(file)
+			lang.Resolve(c, ix, nil, binary, imports, label.New("", "app", "main"))
+			// Dependencies are ordered after generated rules are merged into the build file
+			lang.AfterResolvingDeps(context.Background())
+			require.Equal(t, tc.expected, string(file.Format()))
+		})
+	}
+}
+
+func TestResolveInternalFirstDepsOrder(t *testing.T) {
+	for _, tc := range []struct {
+		clue     string
+		deps     []string
+		expected string
+	}{
+		{
+			clue: "dependencies of the same repository come first, regardless of their spelling",
+			deps: []string{"@zlib", "@main//lib:util", "@ext//:core", "//app/base"},
+			expected: `cc_binary(
+    name = "main",
+    # do not sort
+    deps = [
+        "//app/base",
+        "@main//lib:util",
+        "@ext//:core",
+        "@zlib",
+    ],
+)
+`,
+		},
+		{
+			clue: "lexically sorted dependencies are not modified",
+			deps: []string{"@ext//:core", ":util", "//app/base"},
+			expected: `cc_binary(
+    name = "main",
+    deps = [
+        ":util",
+        "//app/base",
+        "@ext//:core",
+    ],
+)
+`,
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			conf := newCcConfig()
+			conf.depsOrder = internalFirstDepsOrder
+			c := newResolveTestConfig(conf)
+			c.RepoName = "main"
+			lang := NewLanguage().(*ccLanguage)
+
+			file := rule.EmptyFile("app/BUILD.bazel", "app")
+			binary := rule.NewRule("cc_binary", "main")
+			binary.SetAttr("deps", tc.deps)
+			lang.recordOrderedRules(language.GenerateArgs{Config: c, Rel: "app"}, []*rule.Rule{binary})
+			binary.Insert(file)
+			lang.AfterResolvingDeps(context.Background())
+			require.Equal(t, tc.expected, string(file.Format()))
+		})
+	}
+}
+
+func TestResolveDedupeDeps(t *testing.T) {
+	conf := newCcConfig()
+	c := newResolveTestConfig(conf)
+	c.RepoName = "main"
+	c.ModuleToApparentName = func(module string) string {
+		if module == "zlib_module" {
+			return "zlib"
+		}
+		return ""
+	}
+	lang := NewLanguage().(*ccLanguage)
+
+	thirdPartyFile, err := rule.LoadData("third_party/BUILD.bazel", "third_party", []byte(`
+alias(
+    name = "zlib",
+    actual = "@zlib",
+)
+`))
+	require.NoError(t, err)
+	appFile, err := rule.LoadData("app/BUILD.bazel", "app", []byte(`
+cc_binary(
+    name = "main",
+    srcs = ["main.cc"],
+    deps = [
+        "//lib:util",
+        "//third_party:zlib",  # keep
+        "@main//lib:util",
+        "@zlib",
+        "@zlib_module//:zlib",
+    ],
+)
+`))
+	require.NoError(t, err)
+	lang.recordAliases(language.GenerateArgs{Config: c, Rel: "third_party", File: thirdPartyFile})
+	binary := rule.NewRule("cc_binary", "main")
+	lang.recordDedupedRules(language.GenerateArgs{Config: c, Rel: "app", File: appFile}, []*rule.Rule{binary})
+	lang.AfterResolvingDeps(context.Background())
+	require.Equal(t, `cc_binary(
+    name = "main",
+    srcs = ["main.cc"],
+    deps = [
+        "//lib:util",
+        "//third_party:zlib",  # keep
+    ],
+)
+`, string(appFile.Format()))
+}
+
+func TestResolveLocalBracketIncludes(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	libFile := rule.EmptyFile("lib/BUILD.bazel", "lib")
+	util := rule.NewRule("cc_library", "util")
+	util.SetAttr("hdrs", []string{"util.h"})
+	util.Insert(libFile)
+
+	for _, tc := range []struct {
+		clue     string
+		mode     bracketIncludesMode
+		expected []string
+	}{
+		{
+			clue:     "Bracketed includes are resolved only using their raw path by default",
+			mode:     systemBracketIncludes,
+			expected: []string{":util"},
+		},
+		{
+			clue:     "Bracketed includes are resolved relative to the including file in local mode",
+			mode:     localBracketIncludes,
+			expected: []string{"//lib/nested", ":util"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			conf := newCcConfig()
+			conf.bracketIncludesMode = tc.mode
+			c := newResolveTestConfig(conf)
+
+			nestedFile := rule.EmptyFile("lib/nested/BUILD.bazel", "lib/nested")
+			nested := rule.NewRule("cc_library", "nested")
+			nested.SetAttr("hdrs", []string{"nested.h"})
+			nested.Insert(nestedFile)
+
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.AddRule(c, util, libFile)
+			ix.AddRule(c, nested, nestedFile)
+			ix.Finish()
+
+			app := rule.NewRule("cc_binary", "app")
+			app.SetAttr("srcs", []string{"app.cc"})
+			imports := ccImports{
+				srcIncludes: []ccInclude{
+					{rawPath: "lib/util.h", normalizedPath: "lib/util.h", isSystemInclude: true},
+					{rawPath: "nested/nested.h", normalizedPath: "nested/nested.h", isSystemInclude: true},
+					{rawPath: "util.h", normalizedPath: "util.h", isSystemInclude: true},
+					{rawPath: "vector", normalizedPath: "vector", isSystemInclude: true},
+				},
+			}
+			lang.Resolve(c, ix, nil, app, imports, label.New("", "lib", "app"))
+			require.Equal(t, tc.expected, app.AttrStrings("deps"))
+		})
+	}
+}
+
+func TestResolveBracketedFirstPartyIncludes(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	pkgFile := rule.EmptyFile("pkg/BUILD.bazel", "pkg")
+	foo := rule.NewRule("cc_library", "foo")
+	foo.SetAttr("hdrs", []string{"foo.h"})
+	foo.Insert(pkgFile)
+
+	// Repository root relative paths are resolved in both modes, e.g. when sources are compiled using '-I.'
+	for _, mode := range bracketIncludesModes {
+		t.Run(string(mode), func(t *testing.T) {
+			conf := newCcConfig()
+			conf.bracketIncludesMode = mode
+			c := newResolveTestConfig(conf)
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.AddRule(c, foo, pkgFile)
+			ix.Finish()
+
+			app := rule.NewRule("cc_binary", "app")
+			app.SetAttr("srcs", []string{"app.cc"})
+			imports := ccImports{
+				srcIncludes: []ccInclude{
+					{rawPath: "pkg/foo.h", normalizedPath: "pkg/foo.h", isSystemInclude: true},
+				},
+			}
+			lang.Resolve(c, ix, nil, app, imports, label.New("", "app", "app"))
+			require.Equal(t, []string{"//pkg:foo"}, app.AttrStrings("deps"))
+		})
+	}
+}
+
+func TestResolveIncludesUsingSearchRules(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	// Library exposing its headers without the 'src' prefix, e.g. using a toolchain provided include path
+	libFile := rule.EmptyFile("src/mylib/BUILD.bazel", "src/mylib")
+	mylib := rule.NewRule("cc_library", "mylib")
+	mylib.SetAttr("hdrs", []string{"foo.h"})
+	mylib.Insert(libFile)
+
+	imports := ccImports{
+		srcIncludes: []ccInclude{
+			{rawPath: "mylib/foo.h", normalizedPath: "mylib/foo.h", isSystemInclude: true},
+		},
+	}
+
+	for _, tc := range []struct {
+		clue     string
+		searches []ccSearch
+		expected []string
+	}{
+		{
+			clue:     "Bracketed includes are resolved only using their raw path by default",
+			searches: defaultCcSearch(),
+			expected: nil,
+		},
+		{
+			clue:     "Bracketed includes are resolved using paths translated by search rules",
+			searches: append(defaultCcSearch(), ccSearch{includePrefix: "src"}),
+			expected: []string{"//src/mylib"},
+		},
+		{
+			clue:     "Search rules not matching the include are skipped",
+			searches: append(defaultCcSearch(), ccSearch{stripIncludePrefix: "other", includePrefix: "src"}),
+			expected: nil,
+		},
+		{
+			clue:     "First search rule resolving the include is used",
+			searches: append(defaultCcSearch(), ccSearch{includePrefix: "third_party"}, ccSearch{stripIncludePrefix: "mylib", includePrefix: "src/mylib"}, ccSearch{includePrefix: "src"}),
+			expected: []string{"//src/mylib"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			conf := newCcConfig()
+			conf.ccSearch = tc.searches
+			c := newResolveTestConfig(conf)
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.AddRule(c, mylib, libFile)
+			ix.Finish()
+
+			app := rule.NewRule("cc_binary", "app")
+			app.SetAttr("srcs", []string{"app.cc"})
+			lang.Resolve(c, ix, nil, app, imports, label.New("", "app", "app"))
+			if tc.expected == nil {
+				require.Empty(t, app.AttrStrings("deps"))
+			} else {
+				require.Equal(t, tc.expected, app.AttrStrings("deps"))
+			}
+		})
+	}
+}
+
+func TestResolveIncludeDirs(t *testing.T) {
+	repoRoot := t.TempDir()
+	for _, file := range []string{"sdk/include/sdk/api.h", "sdk/include/sdk/detail/impl.hpp", "sdk/include/README.md", "sdk/experimental/next.h"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repoRoot, filepath.Dir(file)), 0777))
+		require.NoError(t, os.WriteFile(filepath.Join(repoRoot, file), []byte{}, 0666))
+	}
+	lang := NewLanguage().(*ccLanguage)
+	// Local header sharing the name with one of the headers in the mapped directory
+	libFile := rule.EmptyFile("app/BUILD.bazel", "app")
+	lib := rule.NewRule("cc_library", "api")
+	lib.SetAttr("hdrs", []string{"sdk/api.h"})
+	lib.Insert(libFile)
+
+	for _, tc := range []struct {
+		clue       string
+		directives []rule.Directive
+		include    ccInclude
+		expected   []string
+	}{
+		{
+			clue:       "Headers are included relative to the mapped directory",
+			directives: []rule.Directive{{Key: cc_include_dir, Value: "sdk/include @sdk//:sdk"}},
+			include:    ccInclude{rawPath: "sdk/detail/impl.hpp", normalizedPath: "sdk/detail/impl.hpp", isSystemInclude: true},
+			expected:   []string{"@sdk//:sdk"},
+		},
+		{
+			clue:       "Headers are included relative to the repository root",
+			directives: []rule.Directive{{Key: cc_include_dir, Value: "sdk/include @sdk//:sdk"}},
+			include:    ccInclude{rawPath: "sdk/include/sdk/api.h", normalizedPath: "app/sdk/include/sdk/api.h"},
+			expected:   []string{"@sdk//:sdk"},
+		},
+		{
+			clue:       "Headers not existing in the mapped directory are not resolved",
+			directives: []rule.Directive{{Key: cc_include_dir, Value: "sdk/include @sdk//:sdk"}},
+			include:    ccInclude{rawPath: "sdk/next.h", normalizedPath: "sdk/next.h", isSystemInclude: true},
+			expected:   nil,
+		},
+		{
+			clue:       "Local headers are resolved before headers of the mapped directory",
+			directives: []rule.Directive{{Key: cc_include_dir, Value: "sdk/include @sdk//:sdk"}},
+			include:    ccInclude{rawPath: "sdk/api.h", normalizedPath: "app/sdk/api.h"},
+			expected:   []string{":api"},
+		},
+		{
+			clue: "Directories defined later take precedence",
+			directives: []rule.Directive{
+				{Key: cc_include_dir, Value: "sdk @sdk//:all"},
+				{Key: cc_include_dir, Value: "sdk/include //third_party/sdk:headers"},
+			},
+			include:  ccInclude{rawPath: "sdk/api.h", normalizedPath: "sdk/api.h", isSystemInclude: true},
+			expected: []string{"//third_party/sdk:headers"},
+		},
+		{
+			clue: "Empty value resets mapped directories",
+			directives: []rule.Directive{
+				{Key: cc_include_dir, Value: "sdk/include @sdk//:sdk"},
+				{Key: cc_include_dir, Value: ""},
+			},
+			include:  ccInclude{rawPath: "sdk/api.h", normalizedPath: "sdk/api.h", isSystemInclude: true},
+			expected: nil,
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			c := newResolveTestConfig(newCcConfig())
+			c.RepoRoot = repoRoot
+			lang.Configure(c, "", &rule.File{Directives: tc.directives})
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.AddRule(c, lib, libFile)
+			ix.Finish()
+
+			app := rule.NewRule("cc_binary", "app")
+			app.SetAttr("srcs", []string{"app.cc"})
+			lang.Resolve(c, ix, nil, app, ccImports{srcIncludes: []ccInclude{tc.include}}, label.New("", "app", "app"))
+			if tc.expected == nil {
+				require.Empty(t, app.AttrStrings("deps"))
+			} else {
+				require.Equal(t, tc.expected, app.AttrStrings("deps"))
+			}
+		})
+	}
+}
+
+func TestResolveNamespaceDeps(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	conf := newCcConfig()
+	conf.namespaceDeps = map[string]label.Label{
+		"foo::bar": label.New("", "foo/bar", "bar"),
+		"baz":      label.New("baz", "", "baz"),
+	}
+	c := newResolveTestConfig(conf)
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.Finish()
+
+	unresolved := ccInclude{rawPath: "generated/api.h", normalizedPath: "generated/api.h", isSystemInclude: true}
+	standard := ccInclude{rawPath: "vector", normalizedPath: "vector", isSystemInclude: true}
+	for _, tc := range []struct {
+		clue     string
+		imports  ccImports
+		expected map[string][]string
+	}{
+		{
+			clue: "Used namespaces are ignored when all includes are resolved",
+			imports: ccImports{
+				srcIncludes:   []ccInclude{standard},
+				srcNamespaces: []string{"foo::bar", "baz"},
+			},
+			expected: map[string][]string{},
+		},
+		{
+			clue: "Used namespaces are mapped to dependencies when some of includes cannot be resolved",
+			imports: ccImports{
+				srcIncludes:   []ccInclude{unresolved},
+				srcNamespaces: []string{"foo::bar::detail", "std", "baz"},
+			},
+			expected: map[string][]string{"implementation_deps": {"//foo/bar", "@baz//:baz"}},
+		},
+		{
+			clue: "Namespaces used in headers are assigned to public dependencies",
+			imports: ccImports{
+				hdrIncludes:   []ccInclude{unresolved},
+				hdrNamespaces: []string{"baz"},
+				srcNamespaces: []string{"foo::bar"},
+			},
+			expected: map[string][]string{"deps": {"@baz//:baz"}},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			lib := rule.NewRule("cc_library", "lib")
+			lib.SetAttr("srcs", []string{"lib.cc"})
+			lang.Resolve(c, ix, nil, lib, tc.imports, label.New("", "lib", "lib"))
+			deps := map[string][]string{}
+			for _, attr := range []string{"deps", "implementation_deps"} {
+				if values := lib.AttrStrings(attr); len(values) > 0 {
+					deps[attr] = values
+				}
+			}
+			require.Equal(t, tc.expected, deps)
+		})
+	}
+}
+
+func TestResolveDefaultDep(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	utilFile := rule.EmptyFile("lib/util/BUILD.bazel", "lib/util")
+	util := rule.NewRule("cc_library", "util")
+	util.SetAttr("hdrs", []string{"util.h"})
+	util.Insert(utilFile)
+
+	resolved := ccInclude{rawPath: "lib/util/util.h", normalizedPath: "lib/util/util.h"}
+	unresolved := ccInclude{rawPath: "legacy/misc.h", normalizedPath: "legacy/misc.h"}
+	bracketed := ccInclude{rawPath: "ext/api.h", normalizedPath: "ext/api.h", isSystemInclude: true}
+	for _, tc := range []struct {
+		clue       string
+		defaultDep label.Label
+		includes   []ccInclude
+		expected   []string
+	}{
+		{
+			clue:     "Unresolved includes create no dependency by default",
+			includes: []ccInclude{resolved, unresolved},
+			expected: []string{"//lib/util"},
+		},
+		{
+			clue:       "Default dependency is not used when all includes are resolved",
+			defaultDep: label.New("", "legacy", "misc"),
+			includes:   []ccInclude{resolved},
+			expected:   []string{"//lib/util"},
+		},
+		{
+			clue:       "Default dependency is used for unresolved quoted includes",
+			defaultDep: label.New("", "legacy", "misc"),
+			includes:   []ccInclude{resolved, unresolved},
+			expected:   []string{"//legacy:misc", "//lib/util"},
+		},
+		{
+			clue:       "Default dependency is never used for bracketed includes",
+			defaultDep: label.New("", "legacy", "misc"),
+			includes:   []ccInclude{bracketed},
+			expected:   nil,
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			conf := newCcConfig()
+			conf.defaultDep = tc.defaultDep
+			c := newResolveTestConfig(conf)
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.AddRule(c, util, utilFile)
+			ix.Finish()
+
+			binary := rule.NewRule("cc_binary", "main")
+			binary.SetAttr("srcs", []string{"main.cc"})
+			lang.Resolve(c, ix, nil, binary, ccImports{srcIncludes: tc.includes}, label.New("", "app", "main"))
+			require.Equal(t, tc.expected, binary.AttrStrings("deps"))
+		})
+	}
+}
+
+func TestResolveWindowsImportLibrary(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	c := newResolveTestConfig(newCcConfig())
+
+	sdkFile := rule.EmptyFile("third_party/sdk/BUILD.bazel", "third_party/sdk")
+	sdk := rule.NewRule("cc_import", "sdk")
+	sdk.SetAttr("hdrs", []string{"include/sdk/api.h"})
+	sdk.SetAttr("includes", []string{"include"})
+	sdk.SetAttr("interface_library", "lib/sdk.lib")
+	sdk.SetAttr("shared_library", "bin/sdk.dll")
+	sdk.Insert(sdkFile)
+
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.AddRule(c, sdk, sdkFile)
+	ix.Finish()
+
+	app := rule.NewRule("cc_binary", "app")
+	app.SetAttr("srcs", []string{"app.cc"})
+	imports := ccImports{
+		srcIncludes: []ccInclude{
+			{rawPath: "sdk/api.h", normalizedPath: "sdk/api.h", isSystemInclude: true},
+		},
+	}
+	lang.Resolve(c, ix, nil, app, imports, label.New("", "app", "app"))
+	require.Equal(t, []string{"//third_party/sdk"}, app.AttrStrings("deps"))
+}
+
+func TestResolveIncludesOfFilesInOtherPackages(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	c := newResolveTestConfig(newCcConfig())
+
+	// Library assigned sources located in its subpackages
+	libFile := rule.EmptyFile("lib/BUILD.bazel", "lib")
+	lib := rule.NewRule("cc_library", "lib")
+	lib.SetAttr("srcs", []string{"lib.cc", "//lib/internal:impl.cc", "//lib/internal:impl.h"})
+	lib.SetAttr("hdrs", []string{"lib.h", "//lib/public:api.h"})
+	lib.Insert(libFile)
+
+	// Unrelated rule exposing the same header as the library
+	internalFile := rule.EmptyFile("lib/internal/BUILD.bazel", "lib/internal")
+	internal := rule.NewRule("cc_library", "internal")
+	internal.SetAttr("hdrs", []string{"impl.h"})
+	internal.Insert(internalFile)
+
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.AddRule(c, lib, libFile)
+	ix.AddRule(c, internal, internalFile)
+	ix.Finish()
+
+	imports := ccImports{
+		hdrIncludes: []ccInclude{
+			{rawPath: "public/api.h", normalizedPath: "lib/public/api.h"},
+		},
+		srcIncludes: []ccInclude{
+			{rawPath: "lib.h", normalizedPath: "lib/lib.h"},
+			{rawPath: "internal/impl.h", normalizedPath: "lib/internal/impl.h"},
+		},
+	}
+	lang.Resolve(c, ix, nil, lib, imports, label.New("", "lib", "lib"))
+	require.Empty(t, lib.AttrStrings("deps"))
+	require.Empty(t, lib.AttrStrings("implementation_deps"))
+
+	// Headers located in other packages are indexed using their actual paths
+	app := rule.NewRule("cc_binary", "app")
+	app.SetAttr("srcs", []string{"app.cc"})
+	lang.Resolve(c, ix, nil, app, ccImports{srcIncludes: []ccInclude{
+		{rawPath: "lib/public/api.h", normalizedPath: "lib/public/api.h", isSystemInclude: true},
+	}}, label.New("", "app", "app"))
+	require.Equal(t, []string{"//lib"}, app.AttrStrings("deps"))
+}
+
+func TestResolveHeadersExportedFromOtherPackages(t *testing.T) {
+	for _, hdr := range []string{"//other:foo.h", "@//other:foo.h", "@@//other:foo.h"} {
+		t.Run(hdr, func(t *testing.T) {
+			lang := NewLanguage().(*ccLanguage)
+			c := newResolveTestConfig(newCcConfig())
+			// Header exported from its package using 'exports_files'
+			libFile := rule.EmptyFile("lib/BUILD.bazel", "lib")
+			lib := rule.NewRule("cc_library", "lib")
+			lib.SetAttr("hdrs", []string{hdr})
+			lib.Insert(libFile)
+			require.Equal(t, []resolve.ImportSpec{{Lang: languageName, Imp: "other/foo.h"}}, lang.Imports(c, lib, libFile))
+
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.AddRule(c, lib, libFile)
+			ix.Finish()
+			app := rule.NewRule("cc_binary", "app")
+			app.SetAttr("srcs", []string{"app.cc"})
+			lang.Resolve(c, ix, nil, app, ccImports{srcIncludes: []ccInclude{
+				{rawPath: "other/foo.h", normalizedPath: "other/foo.h"},
+			}}, label.New("", "app", "app"))
+			require.Equal(t, []string{"//lib"}, app.AttrStrings("deps"))
+		})
+	}
+}
+
+func TestResolveTextualHeaders(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	c := newResolveTestConfig(newCcConfig())
+	// Textual headers are not compiled standalone, but are still available to the dependents of the library
+	libFile := rule.EmptyFile("lib/BUILD.bazel", "lib")
+	lib := rule.NewRule("cc_library", "lib")
+	lib.SetAttr("hdrs", []string{"lib.h"})
+	lib.SetAttr("textual_hdrs", []string{"defs.inc"})
+	lib.Insert(libFile)
+	require.Equal(t, []resolve.ImportSpec{
+		{Lang: languageName, Imp: "lib/lib.h"},
+		{Lang: languageName, Imp: "lib/defs.inc"},
+	}, lang.Imports(c, lib, libFile))
+
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.AddRule(c, lib, libFile)
+	ix.Finish()
+	app := rule.NewRule("cc_binary", "app")
+	app.SetAttr("srcs", []string{"app.cc"})
+	lang.Resolve(c, ix, nil, app, ccImports{srcIncludes: []ccInclude{
+		{rawPath: "lib/defs.inc", normalizedPath: "lib/defs.inc", isSystemInclude: true},
+	}}, label.New("", "app", "app"))
+	require.Equal(t, []string{"//lib"}, app.AttrStrings("deps"))
+}
+
+func TestResolveExcludedDeps(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	c := newResolveTestConfig(newCcConfig())
+	libFile := rule.EmptyFile("lib/BUILD.bazel", "lib")
+	lib := rule.NewRule("cc_library", "lib")
+	lib.SetAttr("srcs", []string{"math.cc"})
+	lib.SetAttr("hdrs", []string{"math.h"})
+	lib.Insert(libFile)
+	otherFile := rule.EmptyFile("other/BUILD.bazel", "other")
+	other := rule.NewRule("cc_library", "other")
+	other.SetAttr("hdrs", []string{"other.h"})
+	other.Insert(otherFile)
+
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.AddRule(c, lib, libFile)
+	ix.AddRule(c, other, otherFile)
+	ix.Finish()
+
+	// Inline test compiles the source of the library, depending on it would link the source twice
+	test := rule.NewRule("cc_test", "math_inline_test")
+	test.SetAttr("srcs", []string{"math.cc"})
+	lang.Resolve(c, ix, nil, test, ccImports{
+		srcIncludes: []ccInclude{
+			{rawPath: "lib/math.h", normalizedPath: "lib/math.h"},
+			{rawPath: "other/other.h", normalizedPath: "other/other.h"},
+		},
+		excludedDeps: []label.Label{label.New("", "lib", "lib")},
+	}, label.New("", "lib", "math_inline_test"))
+	require.Equal(t, []string{"//other"}, test.AttrStrings("deps"))
+}
+
+func TestResolveNoResolvePrefixes(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	rootFile := rule.EmptyFile("BUILD.bazel", "")
+	headers := rule.NewRule("cc_library", "headers")
+	headers.SetAttr("hdrs", []string{"sys/types.h", "gen/config.h"})
+	headers.Insert(rootFile)
+
+	imports := ccImports{
+		srcIncludes: []ccInclude{
+			{rawPath: "sys/types.h", normalizedPath: "sys/types.h", isSystemInclude: true},
+			{rawPath: "gen/config.h", normalizedPath: "gen/config.h", isSystemInclude: true},
+		},
+	}
+
+	for _, tc := range []struct {
+		clue       string
+		prefixes   []string
+		directives string
+		expected   []string
+	}{
+		{
+			clue:     "Includes of system prefixes are not resolved by default",
+			prefixes: defaultNoResolvePrefixes(),
+			expected: []string{"//:headers"},
+		},
+		{
+			clue:     "Includes of user defined prefixes are not resolved",
+			prefixes: append(defaultNoResolvePrefixes(), "gen"),
+			expected: nil,
+		},
+		{
+			clue:       "Explicit resolve directives take precedence over ignored prefixes",
+			prefixes:   defaultNoResolvePrefixes(),
+			directives: "# gazelle:resolve cc sys/types.h //compat:types",
+			expected:   []string{"//:headers", "//compat:types"},
+		},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			conf := newCcConfig()
+			conf.noResolvePrefixes = tc.prefixes
+			c := newResolveTestConfig(conf)
+			directivesFile, err := rule.LoadData("app/BUILD.bazel", "app", []byte(tc.directives))
+			require.NoError(t, err)
+			(&resolve.Configurer{}).Configure(c, "app", directivesFile)
+
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.AddRule(c, headers, rootFile)
+			ix.Finish()
+
+			app := rule.NewRule("cc_binary", "app")
+			app.SetAttr("srcs", []string{"app.cc"})
+			lang.Resolve(c, ix, nil, app, imports, label.New("", "app", "app"))
+			if tc.expected == nil {
+				require.Empty(t, app.AttrStrings("deps"))
+			} else {
+				require.Equal(t, tc.expected, app.AttrStrings("deps"))
+			}
+		})
+	}
+}
+
+func TestResolveSuggestImplementationDeps(t *testing.T) {
+	a, b, c, d := label.New("a", "", "a"), label.New("b", "", "b"), label.New("c", "", "c"), label.New("d", "", "d")
+	imports := ccImports{
+		hdrIncludes: []ccInclude{
+			{rawPath: "a.h", normalizedPath: "a.h", isSystemInclude: true, location: "lib/lib.h:1"},
+		},
+		srcIncludes: []ccInclude{
+			{rawPath: "a.h", normalizedPath: "a.h", isSystemInclude: true, location: "lib/lib.cc:1"},
+			{rawPath: "b.h", normalizedPath: "b.h", isSystemInclude: true, location: "lib/lib.cc:2"},
+			{rawPath: "c.h", normalizedPath: "c.h", isSystemInclude: true, location: "lib/lib.cc:3"},
+			{rawPath: "d.h", normalizedPath: "d.h", isSystemInclude: true, location: "lib/lib.cc:4"},
+		},
+	}
+	const warning = "//lib: dependencies @c//:c, @d//:d are used only by sources and not reachable through dependencies of headers"
+
+	for _, tc := range []struct {
+		clue               string
+		implementationDeps bool
+		suggest            bool
+		expectWarning      bool
+	}{
+		{clue: "suggestions disabled", suggest: false},
+		{clue: "dependencies assigned to deps", suggest: true, expectWarning: true},
+		{clue: "implementation_deps already used", implementationDeps: true, suggest: true},
+	} {
+		t.Run(tc.clue, func(t *testing.T) {
+			lang := NewLanguage().(*ccLanguage)
+			conf := newCcConfig()
+			conf.implementationDeps = tc.implementationDeps
+			conf.suggestImplDeps = tc.suggest
+			conf.dependencyIndexes = []ccDependencyIndex{{"a.h": a, "b.h": b, "c.h": c, "d.h": d}}
+			// Dependency used by the header transitively exposes 'b', but not 'c' or 'd'
+			conf.dependencyGraph = ccDependencyGraph{a: {b}, c: {d}}
+			cfg := newResolveTestConfig(conf)
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.Finish()
+
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			lib := rule.NewRule("cc_library", "lib")
+			lib.SetAttr("srcs", []string{"lib.cc"})
+			lib.SetAttr("hdrs", []string{"lib.h"})
+			lang.Resolve(cfg, ix, nil, lib, imports, label.New("", "lib", "lib"))
+			if tc.expectWarning {
+				require.Contains(t, logs.String(), warning)
+			} else {
+				require.NotContains(t, logs.String(), "consider assigning them to 'implementation_deps'")
+			}
+		})
+	}
+}
+
+func TestResolveUnresolvedIncludesSeverity(t *testing.T) {
+	rootFile := rule.EmptyFile("BUILD.bazel", "")
+	headers := rule.NewRule("cc_library", "headers")
+	headers.SetAttr("hdrs", []string{"util.h"})
+	headers.Insert(rootFile)
+
+	imports := ccImports{
+		srcIncludes: []ccInclude{
+			{rawPath: "util.h", normalizedPath: "util.h", location: "app/app.cc:1"},
+			{rawPath: "missing.h", normalizedPath: "app/missing.h", location: "app/app.cc:2"},
+			// Includes using brackets are typically provided by the toolchain, these are never reported
+			{rawPath: "vector", normalizedPath: "vector", isSystemInclude: true, location: "app/app.cc:3"},
+		},
+	}
+	const warning = `app/app.cc:2: include "missing.h" of //app could not be resolved to any rule`
+
+	for _, tc := range []struct {
+		severity       unresolvedIncludesSeverity
+		expectWarning  bool
+		expectedErrors int
+	}{
+		{severity: silentUnresolvedIncludes},
+		{severity: warnUnresolvedIncludes, expectWarning: true},
+		{severity: errorUnresolvedIncludes, expectWarning: true, expectedErrors: 1},
+	} {
+		t.Run(string(tc.severity), func(t *testing.T) {
+			lang := NewLanguage().(*ccLanguage)
+			conf := newCcConfig()
+			conf.unresolvedIncludes = tc.severity
+			c := newResolveTestConfig(conf)
+			ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+			ix.AddRule(c, headers, rootFile)
+			ix.Finish()
+
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			app := rule.NewRule("cc_binary", "app")
+			app.SetAttr("srcs", []string{"app.cc"})
+			lang.Resolve(c, ix, nil, app, imports, label.New("", "app", "app"))
+
+			require.Equal(t, []string{"//:headers"}, app.AttrStrings("deps"))
+			if tc.expectWarning {
+				require.Contains(t, logs.String(), warning)
+				require.NotContains(t, logs.String(), `"vector"`)
+			} else {
+				require.Empty(t, logs.String())
+			}
+			require.Equal(t, tc.expectedErrors, lang.unresolvedIncludeErrors)
+			if tc.expectedErrors > 0 {
+				require.EqualError(t, lang.unresolvedIncludesError(), "1 of the includes could not be resolved to any rule, see the errors reported above")
+			} else {
+				require.NoError(t, lang.unresolvedIncludesError())
+			}
+		})
+	}
+}
+
+func TestResolveGeneratedRuleMatchingUnmanagedPattern(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	rootFile := rule.EmptyFile("BUILD.bazel", "")
+	headers := rule.NewRule("cc_library", "headers")
+	headers.SetAttr("hdrs", []string{"util.h"})
+	headers.Insert(rootFile)
+
+	conf := newCcConfig()
+	conf.unmanagedRulePatterns = []string{"hand_*"}
+	c := newResolveTestConfig(conf)
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.AddRule(c, headers, rootFile)
+	ix.Finish()
+
+	// Existing rules matching the pattern are never generated, newly generated rule is resolved as usual
+	r := rule.NewRule("cc_binary", "hand_made")
+	r.SetAttr("srcs", []string{"hand_made.cc"})
+	imports := ccImports{srcIncludes: []ccInclude{{rawPath: "util.h", normalizedPath: "util.h"}}}
+	lang.Resolve(c, ix, nil, r, imports, label.New("", "app", "hand_made"))
+	require.Equal(t, []string{"//:headers"}, r.AttrStrings("deps"))
+}
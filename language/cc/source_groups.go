@@ -92,10 +92,17 @@ func (g *sourceGroups) renameOrMergeWith(current groupId, replacement groupId) b
 // Splits input sources into non-recursive groups based on dependencies tracked using include directives.
 // The function panics if any of input sources is not defined sourceInfos map.
 // Header (.h) and it's corresponding implemention (.cc) are always grouped together.
-// Source files without corresponding headers are assigned to single-element groups and can never become dependency of any other group.
+// Source files without corresponding headers are assigned to single-element groups and can never become dependency of any other group,
+// unless mergeOrphans is set and they're uniquely associated with a single group containing headers, in such case they're merged into that group.
+// Sources previously assigned to existing rules (assignedSources) are never merged this way, keeping the existing rules stable.
+// Quoted includes are resolved relative to the repository root, the including file and using given search rules, e.g. to find headers defined in other directories.
 // Each source file is guaranteed to be assigned to exactly 1 group.
-func groupSourcesByUnits(sources []sourceFile, sourceInfos map[sourceFile]parser.SourceInfo) sourceGroups {
-	graph := buildDependencyGraph(sources, sourceInfos)
+func groupSourcesByUnits(sources []sourceFile, sourceInfos map[sourceFile]parser.SourceInfo, mergeOrphans bool, assignedSources sourceFileSet, searches []ccSearch) sourceGroups {
+	graph := buildDependencyGraph(sources, sourceInfos, searches)
+	graph.mergePairedSources()
+	if mergeOrphans {
+		graph.mergeOrphanSources(assignedSources)
+	}
 	sccs := graph.findStronglyConnectedComponents()
 	groups := splitIntoSourceGroups(sccs, graph)
 	groups.resolveGroupDependencies(graph)
@@ -105,6 +112,136 @@ func groupSourcesByUnits(sources []sourceFile, sourceInfos map[sourceFile]parser
 	return groups
 }
 
+// Merges header-only groups connected by include edges into a single group named after the root header of the cluster,
+// the one not included by any other header of the cluster.
+// Clusters are expanded only along include edges starting at the root, a header-only group is merged into the cluster only when all groups including it belong to that cluster.
+// Headers shared by multiple clusters, or included by groups containing implementation files, start their own clusters, so merging never introduces dependency cycles.
+// Groups containing any implementation file are never merged, dependencies on them are kept unchanged.
+func (groups *sourceGroups) mergeHeaderOnlyClusters() {
+	isHeaderOnly := func(id groupId) bool {
+		srcs, _, _ := partitionCSources((*groups)[id].sources)
+		return len(srcs) == 0
+	}
+	order, acyclic := groups.topologicalOrder()
+	if !acyclic {
+		return
+	}
+	includedBy := make(map[groupId][]groupId)
+	for _, id := range order {
+		for _, dep := range (*groups)[id].dependsOn {
+			includedBy[dep] = append(includedBy[dep], id)
+		}
+	}
+	// Groups including a header are always visited before it, their clusters are already known
+	roots := make(map[groupId]groupId)
+	clusterSize := make(map[groupId]int)
+	for _, id := range order {
+		if !isHeaderOnly(id) {
+			continue
+		}
+		root := id
+		if includers := includedBy[id]; len(includers) > 0 {
+			if includerRoot, ok := roots[includers[0]]; ok && !slices.ContainsFunc(includers, func(includer groupId) bool {
+				otherRoot, ok := roots[includer]
+				return !ok || otherRoot != includerRoot
+			}) {
+				root = includerRoot
+			}
+		}
+		roots[id] = root
+		clusterSize[root]++
+	}
+	replacements := make(map[groupId]groupId)
+	for id, root := range roots {
+		if clusterSize[root] > 1 {
+			replacements[id] = root
+		}
+	}
+	if len(replacements) == 0 {
+		return
+	}
+
+	merged := make(sourceGroups)
+	for _, id := range groups.groupIds() {
+		group := (*groups)[id]
+		target, isMerged := replacements[id]
+		if !isMerged {
+			merged[id] = group
+			continue
+		}
+		node, exists := merged[target]
+		if !exists {
+			node = &sourceGroup{}
+			merged[target] = node
+		}
+		node.sources = append(node.sources, group.sources...)
+		node.dependsOn = append(node.dependsOn, group.dependsOn...)
+	}
+	for id, group := range merged {
+		var dependsOn []groupId
+		for _, dep := range group.dependsOn {
+			if replacement, isMerged := replacements[dep]; isMerged {
+				dep = replacement
+			}
+			if dep != id && !slices.Contains(dependsOn, dep) {
+				dependsOn = append(dependsOn, dep)
+			}
+		}
+		group.dependsOn = dependsOn
+		if replacements[id] == id {
+			// Sub-groups refer to the units creating the group, required to match the existing rules
+			group.subGroups = nil
+			for _, src := range group.sources {
+				if unit := src.toGroupId(); !slices.Contains(group.subGroups, unit) {
+					group.subGroups = append(group.subGroups, unit)
+				}
+			}
+		}
+	}
+	if _, acyclic := merged.topologicalOrder(); !acyclic {
+		// Consistency check, groups are kept unchanged if merging the clusters would introduce a dependency cycle
+		return
+	}
+	merged.sort()
+	*groups = merged
+}
+
+// Returns ids of the groups ordered so that each group precedes all of its dependencies, groups are visited in sorted order.
+// Returns false if dependencies of the groups contain a cycle.
+func (groups *sourceGroups) topologicalOrder() ([]groupId, bool) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[groupId]int)
+	var postOrder []groupId
+	var visit func(id groupId) bool
+	visit = func(id groupId) bool {
+		switch state[id] {
+		case visiting:
+			return false
+		case visited:
+			return true
+		}
+		state[id] = visiting
+		for _, dep := range slices.Sorted(slices.Values((*groups)[id].dependsOn)) {
+			if _, exists := (*groups)[dep]; exists && !visit(dep) {
+				return false
+			}
+		}
+		state[id] = visited
+		postOrder = append(postOrder, id)
+		return true
+	}
+	for _, id := range groups.groupIds() {
+		if !visit(id) {
+			return nil, false
+		}
+	}
+	slices.Reverse(postOrder)
+	return postOrder, true
+}
+
 type sourceFileSet map[sourceFile]bool
 
 // represents a node in the dependency graph.
@@ -119,7 +256,7 @@ type sourceDependencyGraph map[groupId]sourceGroupNode
 // Source file (.cc) and it's corresponsing header are always grouped together and become a node in a dependency graph.
 // Nodes of the graph are constructed base on sources having the same name (excluding extension suffix)
 // Edges of the dependency graph are constructed based on include directives to local headers defined in sources of the graph node
-func buildDependencyGraph(sourceFiles []sourceFile, sourceInfos map[sourceFile]parser.SourceInfo) sourceDependencyGraph {
+func buildDependencyGraph(sourceFiles []sourceFile, sourceInfos map[sourceFile]parser.SourceInfo, searches []ccSearch) sourceDependencyGraph {
 	graph := make(sourceDependencyGraph)
 
 	// Initialize graph nodes
@@ -137,10 +274,15 @@ func buildDependencyGraph(sourceFiles []sourceFile, sourceInfos map[sourceFile]p
 		graph[node].sources[file] = true
 		for _, include := range info.Includes.DoubleQuote {
 			// Exclude non local headers, these are handled independently as target dependency
-			// The include can be either workspace relative or source file relative
-			for _, baseDir := range []string{"", path.Dir(file.stringValue())} {
-				dep := newSourceFile(baseDir, include)
-				if _, exists := graph[dep.toGroupId()]; exists {
+			// The include can be either workspace relative, source file relative or translated by one of the search rules
+			candidates := []sourceFile{newSourceFile("", include), newSourceFile(path.Dir(file.stringValue()), include)}
+			for _, search := range searches {
+				if dep, matches := search.includedFile(include); matches {
+					candidates = append(candidates, dep)
+				}
+			}
+			for _, dep := range candidates {
+				if _, exists := graph[dep.toGroupId()]; exists && slices.Contains(sourceFiles, dep) {
 					graph[node].adjacency[dep] = true
 					break
 				}
@@ -150,6 +292,107 @@ func buildDependencyGraph(sourceFiles []sourceFile, sourceInfos map[sourceFile]p
 	return graph
 }
 
+// Returns the repository relative path of the file included using given path, based on the search rule.
+// Returns false if the include does not start with the prefix stripped by the rule.
+func (search ccSearch) includedFile(include string) (sourceFile, bool) {
+	if search.stripIncludePrefix != "" && include != search.stripIncludePrefix && !strings.HasPrefix(include, search.stripIncludePrefix+"/") {
+		return "", false
+	}
+	return sourceFile(transformIncludePath("", search.stripIncludePrefix, search.includePrefix, include)), true
+}
+
+// Merges nodes containing only sources into the node of the header with the same name, excluding extension, included by them.
+// It pairs implementation files with headers defined in other directories, e.g. 'src/foo.cc' including 'include/foo.h'.
+// Nodes included by other nodes or including multiple headers with the same name are kept unchanged.
+func (graph sourceDependencyGraph) mergePairedSources() {
+	for _, id := range slices.Sorted(maps.Keys(graph)) {
+		node := graph[id]
+		if slices.ContainsFunc(slices.Collect(maps.Keys(node.sources)), func(src sourceFile) bool { return src.isHeader() }) {
+			continue
+		}
+		var pairedIds []groupId
+		for dep := range node.adjacency {
+			depId := dep.toGroupId()
+			if depId != id && dep.isHeader() && dep.baseName() == path.Base(string(id)) && !slices.Contains(pairedIds, depId) {
+				pairedIds = append(pairedIds, depId)
+			}
+		}
+		if len(pairedIds) != 1 {
+			continue
+		}
+		isIncluded := false
+		for otherId, other := range graph {
+			for dep := range other.adjacency {
+				if otherId != id && dep.toGroupId() == id {
+					isIncluded = true
+				}
+			}
+		}
+		if isIncluded {
+			continue
+		}
+		target := graph[pairedIds[0]]
+		for src := range node.sources {
+			target.sources[src] = true
+		}
+		for dep := range node.adjacency {
+			if dep.toGroupId() != pairedIds[0] {
+				target.adjacency[dep] = true
+			}
+		}
+		delete(graph, id)
+	}
+}
+
+// Merges nodes containing only sources without corresponding header into the node they're uniquely associated with.
+// The source is associated with other node if it includes one of its files or is included by it, e.g. the sole implementation of a header.
+// Nodes associated with multiple other nodes or with nodes without headers, and nodes containing any of assignedSources are kept unchanged.
+func (graph sourceDependencyGraph) mergeOrphanSources(assignedSources sourceFileSet) {
+	containsSource := func(node sourceGroupNode, predicate func(sourceFile) bool) bool {
+		for src := range node.sources {
+			if predicate(src) {
+				return true
+			}
+		}
+		return false
+	}
+	isHeader := func(src sourceFile) bool { return src.isHeader() }
+	isAssigned := func(src sourceFile) bool { return assignedSources[src] }
+	for _, id := range slices.Sorted(maps.Keys(graph)) {
+		orphan := graph[id]
+		if containsSource(orphan, isHeader) || containsSource(orphan, isAssigned) {
+			continue
+		}
+		associated := make(map[groupId]bool)
+		for dep := range orphan.adjacency {
+			if depId := dep.toGroupId(); depId != id {
+				associated[depId] = true
+			}
+		}
+		for otherId, other := range graph {
+			for dep := range other.adjacency {
+				if otherId != id && dep.toGroupId() == id {
+					associated[otherId] = true
+				}
+			}
+		}
+		if len(associated) != 1 {
+			continue
+		}
+		targetId := slices.Collect(maps.Keys(associated))[0]
+		target := graph[targetId]
+		if !containsSource(target, isHeader) {
+			continue
+		}
+		// Dependencies of the orphan refer only to the target, these are not needed after merging
+		for src := range orphan.sources {
+			target.sources[src] = true
+			delete(target.adjacency, src)
+		}
+		delete(graph, id)
+	}
+}
+
 // Split dependency graph groups using Tarjan’s algorithm to detect strongly connected components (SCCs).
 // Every component []groupId contains a list of groups that depend recursivelly on each other
 func (graph *sourceDependencyGraph) findStronglyConnectedComponents() [][]groupId {
@@ -169,7 +412,8 @@ func (graph *sourceDependencyGraph) findStronglyConnectedComponents() [][]groupI
 		onStack[node] = true
 
 		nodes := *graph
-		for sourceFile := range nodes[node].adjacency {
+		// Dependencies are visited in sorted order, the components are not affected by the random order of map iteration
+		for _, sourceFile := range slices.Sorted(maps.Keys(nodes[node].adjacency)) {
 			dep := sourceFile.toGroupId()
 			if _, exists := indices[dep]; !exists {
 				strongConnect(dep)
@@ -194,7 +438,7 @@ func (graph *sourceDependencyGraph) findStronglyConnectedComponents() [][]groupI
 		}
 	}
 
-	for groupId := range *graph {
+	for _, groupId := range slices.Sorted(maps.Keys(*graph)) {
 		if _, exists := indices[groupId]; !exists {
 			strongConnect(groupId)
 		}
@@ -206,6 +450,8 @@ func (graph *sourceDependencyGraph) findStronglyConnectedComponents() [][]groupI
 // Panics if any groupId defined in fileGroups is not defined in graph
 func splitIntoSourceGroups(fileGroups [][]groupId, graph sourceDependencyGraph) sourceGroups {
 	groups := make(sourceGroups, len(fileGroups))
+	// Names of groups created from multiple units, e.g. defined in different directories
+	var mergedGroups []groupId
 
 	for _, sourcesGroup := range fileGroups {
 		var groupSources []sourceFile
@@ -215,9 +461,25 @@ func splitIntoSourceGroups(fileGroups [][]groupId, graph sourceDependencyGraph)
 			}
 		}
 		groupName := selectGroupName(groupSources)
-		groups[groupName] = &sourceGroup{sources: groupSources}
-		if len(sourcesGroup) > 1 { // Set subgroups only if multiple groups defined
-			groups[groupName].subGroups = sourcesGroup
+		group, exists := groups[groupName]
+		if !exists {
+			group = &sourceGroup{}
+			groups[groupName] = group
+		} else if !slices.Contains(mergedGroups, groupName) {
+			mergedGroups = append(mergedGroups, groupName)
+		}
+		// Units with the same name defined in different directories are merged, none of the sources can be lost
+		group.sources = append(group.sources, groupSources...)
+		group.subGroups = append(group.subGroups, sourcesGroup...)
+	}
+	slices.Sort(mergedGroups)
+	for _, groupName := range mergedGroups {
+		log.Printf("gazelle_cc: units %v share the name '%v' and are defined in a single rule, rename their sources to define them in separate rules",
+			slices.Sorted(slices.Values(groups[groupName].subGroups)), groupName)
+	}
+	for _, group := range groups {
+		if len(group.subGroups) == 1 { // Set subgroups only if multiple groups defined
+			group.subGroups = nil
 		}
 	}
 	return groups
@@ -228,7 +490,7 @@ func (groups *sourceGroups) resolveGroupDependencies(graph sourceDependencyGraph
 	headerToGroupId := make(map[sourceFile]groupId)
 	for id, group := range *groups {
 		for _, file := range group.sources {
-			if file.isHeader() {
+			if file.isHeader() || file.isTextualHeader() {
 				headerToGroupId[file] = id
 			}
 		}
@@ -269,7 +531,7 @@ func (groups *sourceGroups) sourceToGroupIds() map[sourceFile]groupId {
 // The constructed id is lower-cased file name without the extension suffix
 func selectGroupName(files []sourceFile) groupId {
 	var selectedFile sourceFile
-	_, hdrs := partitionCSources(files)
+	_, hdrs, _ := partitionCSources(files)
 	switch len(hdrs) {
 	case 0:
 		slices.Sort(files)
@@ -284,16 +546,19 @@ func selectGroupName(files []sourceFile) groupId {
 	return groupId(groupName)
 }
 
-// Splits the source files into sources and headers
-func partitionCSources(files []sourceFile) (srcs []sourceFile, hdrs []sourceFile) {
+// Splits the source files into sources, headers and textual headers
+func partitionCSources(files []sourceFile) (srcs []sourceFile, hdrs []sourceFile, textualHdrs []sourceFile) {
 	for _, file := range files {
-		if file.isHeader() {
+		switch {
+		case file.isHeader():
 			hdrs = append(hdrs, file)
-		} else {
+		case file.isTextualHeader():
+			textualHdrs = append(textualHdrs, file)
+		default:
 			srcs = append(srcs, file)
 		}
 	}
-	return srcs, hdrs
+	return srcs, hdrs, textualHdrs
 }
 
 func (file *sourceFile) isHeader() bool {
@@ -301,6 +566,11 @@ func (file *sourceFile) isHeader() bool {
 	return slices.Contains(headerExtensions, ext)
 }
 
+func (file *sourceFile) isTextualHeader() bool {
+	ext := filepath.Ext(string(*file))
+	return slices.Contains(textualHeaderExtensions, ext)
+}
+
 func (s *sourceFile) baseName() string {
 	name := string(*s)
 	return strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
@@ -15,9 +15,13 @@
 package cc
 
 import (
+	"bytes"
 	"fmt"
+	"log"
 	"maps"
+	"os"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/EngFlow/gazelle_cc/language/internal/cc/parser"
@@ -25,9 +29,10 @@ import (
 
 func TestSourceGroups(t *testing.T) {
 	testCases := []struct {
-		clue     string
-		input    sourceInfos
-		expected sourceGroups
+		clue         string
+		input        sourceInfos
+		mergeOrphans bool
+		expected     sourceGroups
 	}{
 		{
 			clue: "A source file with no includes should be unassigned",
@@ -181,12 +186,73 @@ func TestSourceGroups(t *testing.T) {
 				"b": {sources: []sourceFile{"b.cc", "b.h"}, dependsOn: []groupId{"a"}},
 			},
 		},
+		{
+			clue: "Source without header including exactly one local header should form its own group by default",
+			input: sourceInfos{
+				"api.h":       {},
+				"api_impl.cc": {Includes: parser.Includes{DoubleQuote: []string{"api.h"}}},
+				"util.h":      {Includes: parser.Includes{DoubleQuote: []string{"api.h"}}},
+			},
+			expected: sourceGroups{
+				"api":      {sources: []sourceFile{"api.h"}},
+				"api_impl": {sources: []sourceFile{"api_impl.cc"}, dependsOn: []groupId{"api"}},
+				"util":     {sources: []sourceFile{"util.h"}, dependsOn: []groupId{"api"}},
+			},
+		},
+		{
+			clue: "Source without header including exactly one local header should merge into its group",
+			input: sourceInfos{
+				"api.h":       {},
+				"api_impl.cc": {Includes: parser.Includes{DoubleQuote: []string{"api.h"}}},
+				"util.h":      {Includes: parser.Includes{DoubleQuote: []string{"api.h"}}},
+			},
+			mergeOrphans: true,
+			expected: sourceGroups{
+				"api":  {sources: []sourceFile{"api.h", "api_impl.cc"}},
+				"util": {sources: []sourceFile{"util.h"}, dependsOn: []groupId{"api"}},
+			},
+		},
+		{
+			clue: "Source without header included by exactly one group should merge into it",
+			input: sourceInfos{
+				"table.h":       {Includes: parser.Includes{DoubleQuote: []string{"table_data.cc"}}},
+				"table.cc":      {Includes: parser.Includes{DoubleQuote: []string{"table.h"}}},
+				"table_data.cc": {},
+			},
+			mergeOrphans: true,
+			expected: sourceGroups{
+				"table": {sources: []sourceFile{"table.cc", "table.h", "table_data.cc"}},
+			},
+		},
+		{
+			clue: "Source without header associated with multiple groups should form its own group",
+			input: sourceInfos{
+				"a.h":       {},
+				"b.h":       {},
+				"ab.cc":     {Includes: parser.Includes{DoubleQuote: []string{"a.h", "b.h"}}},
+				"c.h":       {Includes: parser.Includes{DoubleQuote: []string{"shared.cc"}}},
+				"d.h":       {Includes: parser.Includes{DoubleQuote: []string{"shared.cc"}}},
+				"shared.cc": {},
+			},
+			mergeOrphans: true,
+			expected: sourceGroups{
+				"a":      {sources: []sourceFile{"a.h"}},
+				"b":      {sources: []sourceFile{"b.h"}},
+				"ab":     {sources: []sourceFile{"ab.cc"}, dependsOn: []groupId{"a", "b"}},
+				"c":      {sources: []sourceFile{"c.h"}},
+				"d":      {sources: []sourceFile{"d.h"}},
+				"shared": {sources: []sourceFile{"shared.cc"}},
+			},
+		},
 	}
 
 	for idx, tc := range testCases {
 		result := groupSourcesByUnits(
 			slices.Collect(maps.Keys(tc.input)),
 			tc.input,
+			tc.mergeOrphans,
+			nil,
+			nil,
 		)
 
 		shouldFail := false
@@ -215,3 +281,218 @@ func TestSourceGroups(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceGroupsAcrossDirectories(t *testing.T) {
+	searches := []ccSearch{{}, {includePrefix: "lib/include"}}
+	testCases := []struct {
+		clue     string
+		input    sourceInfos
+		expected sourceGroups
+	}{
+		{
+			clue: "Group source with header included from another directory",
+			input: sourceInfos{
+				"lib/include/a.h": {},
+				"lib/include/b.h": {},
+				"lib/src/a.cc":    {Includes: parser.Includes{DoubleQuote: []string{"a.h", "b.h"}}},
+				"lib/src/b.cc":    {Includes: parser.Includes{DoubleQuote: []string{"b.h"}}},
+			},
+			expected: sourceGroups{
+				"a": {sources: []sourceFile{"lib/include/a.h", "lib/src/a.cc"}, dependsOn: []groupId{"b"}},
+				"b": {sources: []sourceFile{"lib/include/b.h", "lib/src/b.cc"}},
+			},
+		},
+		{
+			clue: "Resolve workspace relative includes from other directories",
+			input: sourceInfos{
+				"lib/include/a.h": {},
+				"lib/src/a.cc":    {Includes: parser.Includes{DoubleQuote: []string{"lib/include/a.h"}}},
+			},
+			expected: sourceGroups{
+				"a": {sources: []sourceFile{"lib/include/a.h", "lib/src/a.cc"}},
+			},
+		},
+		{
+			clue: "Do not pair source with a header with different name",
+			input: sourceInfos{
+				"lib/include/a.h": {},
+				"lib/include/b.h": {},
+				"lib/src/impl.cc": {Includes: parser.Includes{DoubleQuote: []string{"a.h", "b.h"}}},
+			},
+			expected: sourceGroups{
+				"a":    {sources: []sourceFile{"lib/include/a.h"}},
+				"b":    {sources: []sourceFile{"lib/include/b.h"}},
+				"impl": {sources: []sourceFile{"lib/src/impl.cc"}, dependsOn: []groupId{"a", "b"}},
+			},
+		},
+		{
+			clue: "Merge unrelated units with the same name",
+			input: sourceInfos{
+				"lib/a/util.h": {},
+				"lib/b/util.h": {},
+			},
+			expected: sourceGroups{
+				"util": {sources: []sourceFile{"lib/a/util.h", "lib/b/util.h"}, subGroups: []groupId{"lib/a/util", "lib/b/util"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.clue, func(t *testing.T) {
+			result := groupSourcesByUnits(slices.Collect(maps.Keys(tc.input)), tc.input, false, nil, searches)
+			if !slices.Equal(tc.expected.groupIds(), result.groupIds()) {
+				t.Fatalf("groups do not match\n\t- expected: %v\n\t- obtained: %v", tc.expected.groupIds(), result.groupIds())
+			}
+			for _, id := range tc.expected.groupIds() {
+				if fmt.Sprintf("%v", *tc.expected[id]) != fmt.Sprintf("%v", *result[id]) {
+					t.Errorf("group %v does not match\n\t- expected: %+v\n\t- obtained: %+v", id, *tc.expected[id], *result[id])
+				}
+			}
+		})
+	}
+}
+
+func TestSourceGroupsMergedUnitsAreReported(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	input := sourceInfos{
+		"lib/a/util.h":  {},
+		"lib/b/util.h":  {},
+		"lib/b/util.cc": {},
+		"lib/a/other.h": {},
+	}
+	groupSourcesByUnits(slices.Collect(maps.Keys(input)), input, false, nil, nil)
+	expected := "gazelle_cc: units [lib/a/util lib/b/util] share the name 'util' and are defined in a single rule"
+	if !strings.Contains(logs.String(), expected) || strings.Count(logs.String(), "gazelle_cc: units") != 1 {
+		t.Errorf("expected merged units to be reported once\n\t- expected: %v\n\t- obtained: %v", expected, logs.String())
+	}
+}
+
+func TestSourceGroupsMergeHeaderOnlyClusters(t *testing.T) {
+	testCases := []struct {
+		clue     string
+		input    sourceInfos
+		expected sourceGroups
+	}{
+		{
+			clue: "Merge header-only dependency chain into the root header",
+			input: sourceInfos{
+				"a.h": {Includes: parser.Includes{DoubleQuote: []string{"b.h"}}},
+				"b.h": {Includes: parser.Includes{DoubleQuote: []string{"c.h"}}},
+				"c.h": {},
+			},
+			expected: sourceGroups{
+				"a": {sources: []sourceFile{"a.h", "b.h", "c.h"}, subGroups: []groupId{"a", "b", "c"}},
+			},
+		},
+		{
+			clue: "Keep header included by multiple roots in a separate group",
+			input: sourceInfos{
+				"x.h":      {Includes: parser.Includes{DoubleQuote: []string{"shared.h"}}},
+				"b.h":      {Includes: parser.Includes{DoubleQuote: []string{"shared.h", "b_impl.h"}}},
+				"b_impl.h": {},
+				"shared.h": {},
+				"other.h":  {},
+			},
+			expected: sourceGroups{
+				"b":      {sources: []sourceFile{"b.h", "b_impl.h"}, dependsOn: []groupId{"shared"}, subGroups: []groupId{"b", "b_impl"}},
+				"other":  {sources: []sourceFile{"other.h"}},
+				"shared": {sources: []sourceFile{"shared.h"}},
+				"x":      {sources: []sourceFile{"x.h"}, dependsOn: []groupId{"shared"}},
+			},
+		},
+		{
+			clue: "Keep header included by units with implementation in a separate group",
+			input: sourceInfos{
+				"a.h":  {Includes: parser.Includes{DoubleQuote: []string{"b.h", "c.h"}}},
+				"b.h":  {Includes: parser.Includes{DoubleQuote: []string{"c.h"}}},
+				"b.cc": {Includes: parser.Includes{DoubleQuote: []string{"b.h"}}},
+				"c.h":  {},
+			},
+			expected: sourceGroups{
+				"a": {sources: []sourceFile{"a.h"}, dependsOn: []groupId{"b", "c"}},
+				"b": {sources: []sourceFile{"b.cc", "b.h"}, dependsOn: []groupId{"c"}},
+				"c": {sources: []sourceFile{"c.h"}},
+			},
+		},
+		{
+			clue: "Merge header included only by headers of the same cluster",
+			input: sourceInfos{
+				"a.h": {Includes: parser.Includes{DoubleQuote: []string{"b.h", "c.h"}}},
+				"b.h": {Includes: parser.Includes{DoubleQuote: []string{"c.h"}}},
+				"c.h": {},
+			},
+			expected: sourceGroups{
+				"a": {sources: []sourceFile{"a.h", "b.h", "c.h"}, subGroups: []groupId{"a", "b", "c"}},
+			},
+		},
+		{
+			clue: "Keep units with implementation unchanged",
+			input: sourceInfos{
+				"a.h":  {Includes: parser.Includes{DoubleQuote: []string{"b.h"}}},
+				"b.h":  {Includes: parser.Includes{DoubleQuote: []string{"c.h"}}},
+				"b.cc": {Includes: parser.Includes{DoubleQuote: []string{"b.h"}}},
+				"c.h":  {Includes: parser.Includes{DoubleQuote: []string{"d.h"}}},
+				"d.h":  {},
+			},
+			expected: sourceGroups{
+				"a": {sources: []sourceFile{"a.h"}, dependsOn: []groupId{"b"}},
+				"b": {sources: []sourceFile{"b.cc", "b.h"}, dependsOn: []groupId{"c"}},
+				"c": {sources: []sourceFile{"c.h", "d.h"}, subGroups: []groupId{"c", "d"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.clue, func(t *testing.T) {
+			result := groupSourcesByUnits(slices.Collect(maps.Keys(tc.input)), tc.input, false, nil, nil)
+			result.mergeHeaderOnlyClusters()
+			if _, acyclic := result.topologicalOrder(); !acyclic {
+				t.Fatalf("merged groups contain a dependency cycle: %v", result)
+			}
+			if !slices.Equal(tc.expected.groupIds(), result.groupIds()) {
+				t.Fatalf("groups do not match\n\t- expected: %v\n\t- obtained: %v", tc.expected.groupIds(), result.groupIds())
+			}
+			for _, id := range tc.expected.groupIds() {
+				if fmt.Sprintf("%v", *tc.expected[id]) != fmt.Sprintf("%v", *result[id]) {
+					t.Errorf("group %v does not match\n\t- expected: %+v\n\t- obtained: %+v", id, *tc.expected[id], *result[id])
+				}
+			}
+		})
+	}
+}
+
+func TestSourceGroupsDeterministic(t *testing.T) {
+	// Multiple cycles sharing headers, orphan sources and sources including multiple groups
+	input := sourceInfos{
+		"a.h":    {Includes: parser.Includes{DoubleQuote: []string{"b.h"}}},
+		"b.h":    {Includes: parser.Includes{DoubleQuote: []string{"c.h"}}},
+		"c.h":    {Includes: parser.Includes{DoubleQuote: []string{"a.h", "d.h"}}},
+		"d.h":    {Includes: parser.Includes{DoubleQuote: []string{"e.h"}}},
+		"e.h":    {Includes: parser.Includes{DoubleQuote: []string{"d.h"}}},
+		"e.cc":   {Includes: parser.Includes{DoubleQuote: []string{"a.h"}}},
+		"f.h":    {Includes: parser.Includes{DoubleQuote: []string{"g.h"}}},
+		"g.h":    {Includes: parser.Includes{DoubleQuote: []string{"f.h", "a.h"}}},
+		"x.cc":   {Includes: parser.Includes{DoubleQuote: []string{"f.h", "d.h"}}},
+		"y.cc":   {Includes: parser.Includes{DoubleQuote: []string{"g.h"}}},
+		"main.c": {},
+	}
+	render := func(groups sourceGroups) string {
+		result := ""
+		for _, id := range groups.groupIds() {
+			result += fmt.Sprintf("%v: %+v\n", id, *groups[id])
+		}
+		return result
+	}
+	sources := slices.Sorted(maps.Keys(input))
+	expected := render(groupSourcesByUnits(sources, input, false, nil, nil))
+	for i := range 100 {
+		// Order of the sources is also randomized by the map iteration
+		obtained := render(groupSourcesByUnits(slices.Collect(maps.Keys(input)), input, false, nil, nil))
+		if obtained != expected {
+			t.Fatalf("Run %d produced different groups\n\t- expected:\n%v\n\t- obtained:\n%v", i, expected, obtained)
+		}
+	}
+}
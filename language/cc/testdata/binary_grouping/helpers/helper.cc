@@ -0,0 +1 @@
+int helper(int argc) { return argc; }
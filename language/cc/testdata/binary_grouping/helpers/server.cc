@@ -0,0 +1,3 @@
+int helper(int argc);
+
+int main(int argc, char** argv) { return helper(argc) + 1; }
@@ -0,0 +1,3 @@
+#pragma once
+
+int run(int argc, char** argv);
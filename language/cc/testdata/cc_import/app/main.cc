@@ -0,0 +1,4 @@
+#include "prebuilt/foo.h"
+#include "win/bar.h"
+
+int main() { return foo() + bar(); }
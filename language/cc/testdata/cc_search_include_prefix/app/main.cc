@@ -0,0 +1,4 @@
+#include <mylib/foo.h>
+#include "mylib/detail/bar.h"
+
+int main() { return foo() + bar(); }
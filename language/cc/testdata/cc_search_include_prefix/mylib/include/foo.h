@@ -0,0 +1,4 @@
+#pragma once
+#include <mylib/detail/bar.h>
+
+int foo();
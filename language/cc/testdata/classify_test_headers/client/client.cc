@@ -0,0 +1,3 @@
+#include "client.h"
+
+int connect(int port) { return port; }
@@ -0,0 +1,5 @@
+#pragma once
+
+#include "client.h"
+
+inline int fake_connect(int port) { return -port; }
@@ -0,0 +1,3 @@
+#include "client_fake_mock.h"
+
+int main() { return fake_connect(0); }
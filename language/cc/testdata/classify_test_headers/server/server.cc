@@ -0,0 +1 @@
+#include "server.h"
@@ -0,0 +1,7 @@
+#pragma once
+
+class Server {
+ public:
+  virtual ~Server() = default;
+  virtual int handle(int request) = 0;
+};
@@ -0,0 +1,10 @@
+#pragma once
+
+#include <gmock/gmock.h>
+
+#include "server.h"
+
+class MockServer : public Server {
+ public:
+  MOCK_METHOD(int, handle, (int request), (override));
+};
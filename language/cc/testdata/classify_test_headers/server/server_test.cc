@@ -0,0 +1,9 @@
+#include <gtest/gtest.h>
+
+#include "server_mock.h"
+
+TEST(ServerTest, Handle) {
+  MockServer server;
+  EXPECT_CALL(server, handle(1)).WillOnce(testing::Return(2));
+  EXPECT_EQ(server.handle(1), 2);
+}
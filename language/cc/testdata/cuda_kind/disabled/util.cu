@@ -0,0 +1 @@
+__global__ void util_kernel() {}
@@ -0,0 +1,6 @@
+#include "kernel.cuh"
+#include "lib.h"
+
+__global__ void kernel(float* data) {}
+
+void launch(float* data, int size) { kernel<<<1, scale(size)>>>(data); }
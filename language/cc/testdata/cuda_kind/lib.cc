@@ -0,0 +1,3 @@
+#include "lib.h"
+
+int scale(int value) { return value * 2; }
@@ -0,0 +1,4 @@
+#include <zlib.h>
+#include "third_party/conan/lib.h"
+
+int main() { return 0; }
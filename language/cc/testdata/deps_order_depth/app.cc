@@ -0,0 +1,7 @@
+#include <ext/core.h>
+#include <ext/mid.h>
+#include <zlib.h>
+
+#include "lib/lib.h"
+
+int main() { return 0; }
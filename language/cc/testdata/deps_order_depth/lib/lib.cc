@@ -0,0 +1,2 @@
+#include "lib/lib.h"
+#include <zlib.h>
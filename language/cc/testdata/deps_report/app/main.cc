@@ -0,0 +1,2 @@
+#include "core/core_util.h"
+int main() { return util(); }
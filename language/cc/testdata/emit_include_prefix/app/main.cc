@@ -0,0 +1,5 @@
+#include "foo/foo.h"
+#include "foo/inc/bar.h"
+#include "lib/lib.h"
+
+int main() { return 0; }
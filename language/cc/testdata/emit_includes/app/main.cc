@@ -0,0 +1,8 @@
+#include <iostream>
+
+#include "util/strings.h"
+#include "version.h"
+
+int main() {
+  std::cout << util::trim("  hello  ") << std::endl;
+}
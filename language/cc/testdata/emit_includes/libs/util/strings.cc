@@ -0,0 +1,9 @@
+#include "strings.h"
+
+namespace util {
+std::string trim(const std::string& value) {
+  auto begin = value.find_first_not_of(' ');
+  auto end = value.find_last_not_of(' ');
+  return begin == std::string::npos ? "" : value.substr(begin, end - begin + 1);
+}
+}  // namespace util
@@ -0,0 +1,7 @@
+#pragma once
+
+#include <string>
+
+namespace util {
+std::string trim(const std::string& value);
+}
@@ -0,0 +1,3 @@
+#pragma once
+
+#define UTIL_VERSION "1.0"
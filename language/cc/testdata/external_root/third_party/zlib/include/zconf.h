@@ -0,0 +1,3 @@
+#pragma once
+
+typedef unsigned char Byte;
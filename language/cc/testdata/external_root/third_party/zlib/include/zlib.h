@@ -0,0 +1,4 @@
+#pragma once
+#include "zconf.h"
+
+int inflate(void);
@@ -0,0 +1,3 @@
+#include "zlib.h"
+
+int inflate(void) { return 0; }
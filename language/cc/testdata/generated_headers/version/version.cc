@@ -0,0 +1,4 @@
+#include "version_info.h"
+#include "version.h"
+
+const char* version_info() { return VERSION; }
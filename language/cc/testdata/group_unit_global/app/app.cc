@@ -0,0 +1,3 @@
+#include "mylib/include/foo.h"
+
+int main() { return foo(); }
@@ -0,0 +1,4 @@
+#pragma once
+#include "bar.h"
+
+int foo();
@@ -0,0 +1,4 @@
+#include "mylib/plugin/plugin.h"
+#include "foo.h"
+
+void plugin() { foo(); }
@@ -0,0 +1,3 @@
+#include "bar.h"
+
+int bar() { return 42; }
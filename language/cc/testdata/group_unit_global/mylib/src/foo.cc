@@ -0,0 +1,3 @@
+#include "foo.h"
+
+int foo() { return bar() + 1; }
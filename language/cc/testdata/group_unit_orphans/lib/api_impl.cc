@@ -0,0 +1,3 @@
+#include "api.h"
+
+int api() { return 42; }
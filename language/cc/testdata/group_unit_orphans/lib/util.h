@@ -0,0 +1,5 @@
+#pragma once
+
+#include "api.h"
+
+inline int twice() { return 2 * api(); }
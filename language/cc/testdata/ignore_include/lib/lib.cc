@@ -0,0 +1,4 @@
+#include "lib/lib.h"
+#include "util/util.h"
+#include "third_party/version.h"
+#include "gen/config.h"
@@ -0,0 +1,2 @@
+#pragma once
+#define VERSION 1
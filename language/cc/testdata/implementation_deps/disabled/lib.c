@@ -0,0 +1,3 @@
+#include "lib.h"
+#include "impl_dep.h"
+#include "dep.h"
\ No newline at end of file
@@ -0,0 +1,4 @@
+#include <core/core.h>
+#include "util.h"
+
+int main() { return 0; }
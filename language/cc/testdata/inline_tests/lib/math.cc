@@ -0,0 +1,9 @@
+#include "lib/math.h"
+
+int add(int a, int b) { return a + b; }
+
+#ifdef UNIT_TEST
+#include <gtest/gtest.h>
+
+TEST(MathTest, Add) { EXPECT_EQ(add(1, 2), 3); }
+#endif
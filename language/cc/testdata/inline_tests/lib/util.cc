@@ -0,0 +1,3 @@
+#include "lib/math.h"
+
+int twice(int a) { return add(a, a); }
@@ -0,0 +1,7 @@
+#include "@fmt//include/fmt:format.h"
+#include "//lib:lib.h"
+#include "//lib:missing.h"
+#include "@unknown//pkg:unknown.h"
+#include "//missing:missing.h"
+
+int main() { return 0; }
@@ -0,0 +1,4 @@
+#include "common.pch"
+#include <common.h.gch>
+#include "common.pchi"
+#include "common.h"
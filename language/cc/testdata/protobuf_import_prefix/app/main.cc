@@ -0,0 +1,6 @@
+#include "api/model/user.pb.h"
+
+int main() {
+  model::User user;
+  return 0;
+}
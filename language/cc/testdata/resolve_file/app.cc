@@ -0,0 +1,3 @@
+#include <vendor/zlib.h>
+#include "legacy/config.h"
+#include "broken.h"
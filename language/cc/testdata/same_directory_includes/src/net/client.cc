@@ -0,0 +1,2 @@
+#include "http.h"
+#include "./socket.h"
@@ -0,0 +1 @@
+#include "http.h"
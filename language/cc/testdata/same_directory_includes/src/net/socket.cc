@@ -0,0 +1 @@
+#include "socket.h"
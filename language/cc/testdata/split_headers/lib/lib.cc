@@ -0,0 +1,2 @@
+#include "lib/lib.h"
+int lib() { return 0; }
@@ -0,0 +1 @@
+#include "unit/a.h"
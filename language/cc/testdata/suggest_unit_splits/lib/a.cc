@@ -0,0 +1,4 @@
+#include "a.h"
+#include "dep/x.h"
+
+int a() { return x(); }
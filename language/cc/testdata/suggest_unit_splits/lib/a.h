@@ -0,0 +1,4 @@
+#pragma once
+#include "b.h"
+
+int a();
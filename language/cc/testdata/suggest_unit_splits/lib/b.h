@@ -0,0 +1,4 @@
+#pragma once
+#include "a.h"
+
+int b();
@@ -0,0 +1,4 @@
+#include "c.h"
+#include "dep/y.h"
+
+int c() { return y(); }
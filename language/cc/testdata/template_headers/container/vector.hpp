@@ -0,0 +1,9 @@
+#pragma once
+
+template <typename T>
+class Vector {
+ public:
+  void push_back(const T& value);
+};
+
+#include "vector.tpp"
@@ -0,0 +1,6 @@
+#include "memory/alloc.h"
+
+template <typename T>
+void Vector<T>::push_back(const T& value) {
+  allocate(sizeof(T));
+}
@@ -0,0 +1,4 @@
+#include "memory/alloc.h"
+#include <cstdlib>
+
+void* allocate(std::size_t size) { return std::malloc(size); }
@@ -0,0 +1,4 @@
+#pragma once
+#include <cstddef>
+
+void* allocate(std::size_t size);
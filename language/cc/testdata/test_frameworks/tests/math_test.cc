@@ -0,0 +1,3 @@
+#include <gtest/gtest.h>
+
+TEST(Math, Add) { EXPECT_EQ(2, 1 + 1); }
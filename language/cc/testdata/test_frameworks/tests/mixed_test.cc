@@ -0,0 +1,2 @@
+#include <catch2/catch_test_macros.hpp>
+#include <gtest/gtest.h>
@@ -0,0 +1,6 @@
+#include <cassert>
+
+int main() {
+  assert(true);
+  return 0;
+}
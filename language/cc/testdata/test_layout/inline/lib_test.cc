@@ -0,0 +1,4 @@
+#include "lib.h"
+#include "runner/registry.h"
+
+REGISTER_TEST(answer) { return answer() == 42; }
@@ -0,0 +1,3 @@
+#pragma once
+
+#define REGISTER_TEST(name) bool name##_test()
@@ -0,0 +1,94 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
+
+// Collects includes that could not be resolved to any rule, written when requested using -cc_unresolved_report flag
+type unresolvedReport struct {
+	// Path to the JSON report file
+	file string
+	// Unresolved imports of each of the resolved rules, in order of their occurrence
+	imports map[label.Label][]resolve.ImportSpec
+}
+
+// Records the import of the rule that could not be resolved, repeated imports of the same rule are recorded once
+func (report *unresolvedReport) record(from label.Label, imp resolve.ImportSpec) {
+	if report.imports == nil {
+		report.imports = make(map[label.Label][]resolve.ImportSpec)
+	}
+	if !slices.Contains(report.imports[from], imp) {
+		report.imports[from] = append(report.imports[from], imp)
+	}
+}
+
+// Reports the quoted include that could not be resolved to any rule according to the '# gazelle:cc_unresolved_includes' directive.
+// Includes using brackets typically refer to headers provided by the toolchain, these are never reported.
+func (lang *ccLanguage) reportUnresolvedInclude(conf *ccConfig, from label.Label, include ccInclude) {
+	if include.isSystemInclude || conf.isNoResolveInclude(include) || conf.unresolvedIncludes == silentUnresolvedIncludes {
+		return
+	}
+	log.Printf("%v: include %q of %v could not be resolved to any rule", include.location, include.rawPath, from)
+	if conf.unresolvedIncludes == errorUnresolvedIncludes {
+		lang.unresolvedIncludeErrors++
+	}
+}
+
+// Returns an error if any of the unresolved includes was reported using '# gazelle:cc_unresolved_includes error'
+func (lang *ccLanguage) unresolvedIncludesError() error {
+	if lang.unresolvedIncludeErrors == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of the includes could not be resolved to any rule, see the errors reported above", lang.unresolvedIncludeErrors)
+}
+
+// Serializes the report as JSON object mapping labels of rules to sorted lists of their unresolved includes
+func (report *unresolvedReport) marshal() ([]byte, error) {
+	entries := make(map[string][]string, len(report.imports))
+	for from, imports := range report.imports {
+		includes := make([]string, len(imports))
+		for i, imp := range imports {
+			includes[i] = imp.Imp
+		}
+		slices.Sort(includes)
+		entries[from.String()] = includes
+	}
+	// Keys of the map are sorted by the encoder
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Writes the report to the requested file, failures are reported without interrupting the run
+func (report *unresolvedReport) write() {
+	data, err := report.marshal()
+	if err == nil {
+		err = os.WriteFile(report.file, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("gazelle_cc: failed to write cc unresolved includes report %v: %v", report.file, err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 EngFlow Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnresolvedReport(t *testing.T) {
+	report := unresolvedReport{}
+	lib := label.New("", "pkg", "lib")
+	tool := label.New("", "pkg", "tool")
+	report.record(tool, resolve.ImportSpec{Lang: languageName, Imp: "missing.h"})
+	report.record(lib, resolve.ImportSpec{Lang: languageName, Imp: "zlib.h"})
+	report.record(lib, resolve.ImportSpec{Lang: languageName, Imp: "pkg/gen.h"})
+	report.record(lib, resolve.ImportSpec{Lang: languageName, Imp: "zlib.h"})
+
+	require.Equal(t, []resolve.ImportSpec{
+		{Lang: languageName, Imp: "zlib.h"},
+		{Lang: languageName, Imp: "pkg/gen.h"},
+	}, report.imports[lib])
+
+	data, err := report.marshal()
+	require.NoError(t, err)
+	require.Equal(t, `{
+  "//pkg:lib": [
+    "pkg/gen.h",
+    "zlib.h"
+  ],
+  "//pkg:tool": [
+    "missing.h"
+  ]
+}
+`, string(data))
+}
+
+func TestResolveRecordsUnresolvedIncludes(t *testing.T) {
+	lang := NewLanguage().(*ccLanguage)
+	lang.unresolvedReport = &unresolvedReport{}
+	rootFile := rule.EmptyFile("BUILD.bazel", "")
+	headers := rule.NewRule("cc_library", "headers")
+	headers.SetAttr("hdrs", []string{"util.h"})
+	headers.Insert(rootFile)
+
+	c := newResolveTestConfig(newCcConfig())
+	ix := resolve.NewRuleIndex(func(r *rule.Rule, pkgRel string) resolve.Resolver { return lang })
+	ix.AddRule(c, headers, rootFile)
+	ix.Finish()
+
+	r := rule.NewRule("cc_binary", "main")
+	r.SetAttr("srcs", []string{"main.cc"})
+	from := label.New("", "app", "main")
+	lang.Resolve(c, ix, nil, r, ccImports{srcIncludes: []ccInclude{
+		{rawPath: "util.h", normalizedPath: "util.h"},
+		{rawPath: "missing.h", normalizedPath: "app/missing.h"},
+		// Includes with prefixes that are never resolved are not reported
+		{rawPath: "sys/types.h", normalizedPath: "sys/types.h", isSystemInclude: true},
+		// Bracketed includes without a directory refer to the standard library
+		{rawPath: "vector", normalizedPath: "vector", isSystemInclude: true},
+	}}, from)
+
+	require.Equal(t, []string{"//:headers"}, r.AttrStrings("deps"))
+	require.Equal(t, map[label.Label][]resolve.ImportSpec{
+		from: {{Lang: languageName, Imp: "missing.h"}},
+	}, lang.unresolvedReport.imports)
+}
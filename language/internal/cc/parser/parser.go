@@ -17,6 +17,7 @@ package parser
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -26,6 +27,37 @@ import (
 type SourceInfo struct {
 	Includes Includes
 	HasMain  bool
+	// Names of C++20 modules imported using `import foo.bar;`, partitions are prefixed with name of the declared module if known
+	ModuleImports []string
+	// Names of C++20 modules declared using `export module foo.bar;`
+	ModuleExports []string
+	// Includes guarded by preprocessor conditions, each of them is also listed in Includes
+	ConditionalIncludes []ConditionalInclude
+	// Positions of includes listed in Includes, in order of their occurrence in the source
+	IncludeLocations []IncludeLocation
+	// Arguments of the test defined using `// gazelle:test_args --flag value` comments, in order of their occurrence in the source
+	TestArgs []string
+	// Fully qualified names of namespaces used with `using namespace foo::bar;` or aliased with `namespace baz = foo::bar;`,
+	// in order of their occurrence in the source, without the leading `::`
+	UsedNamespaces []string
+}
+
+// Position of the include in the source file, used for diagnostics
+type IncludeLocation struct {
+	Path string
+	// 1-based number of the line containing the include directive
+	Line int
+	// True when include defined using brackets
+	IsSystem bool
+}
+
+// Include defined inside a block of conditional preprocessor directives
+type ConditionalInclude struct {
+	Path string
+	// Condition guarding the include, e.g. `defined(_WIN32)`. Conditions of nested blocks are joined using `&&`
+	Condition string
+	// True when include defined using brackets
+	IsSystem bool
 }
 
 type Includes struct {
@@ -33,19 +65,45 @@ type Includes struct {
 	Bracket     []string
 }
 
+// Options enabling extraction of information that is not needed by default, see ParseSourceWithOptions
+type ParseOptions struct {
+	// Should namespaces used in the source be extracted, see SourceInfo.UsedNamespaces
+	UsedNamespaces bool
+}
+
+// Parses the source code held in memory. Tokens exceeding maxTokenSize are not reported,
+// in such case the information extracted from the remaining part of the source is missing.
 func ParseSource(input string) SourceInfo {
+	return ParseSourceWithOptions(input, ParseOptions{})
+}
+
+// Variant of ParseSource allowing to extract additional information using provided options
+func ParseSourceWithOptions(input string, options ParseOptions) SourceInfo {
 	reader := strings.NewReader(input)
-	return extractSourceInfo(reader)
+	sourceInfo, _ := extractSourceInfo(reader, options)
+	return sourceInfo
 }
 
 func ParseSourceFile(filename string) (SourceInfo, error) {
+	return ParseSourceFileWithOptions(filename, ParseOptions{})
+}
+
+// Variant of ParseSourceFile allowing to extract additional information using provided options
+func ParseSourceFileWithOptions(filename string, options ParseOptions) (SourceInfo, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return SourceInfo{}, err
 	}
 	defer file.Close()
 
-	return extractSourceInfo(file), nil
+	return extractSourceInfo(file, options)
+}
+
+// Parses the source code read from the stream, the whole input is never loaded into memory at once.
+// Returns an error together with information extracted so far if the input contains a token exceeding maxTokenSize,
+// e.g. a very long line containing an include directive, or if reading the input fails.
+func ParseSourceReader(input io.Reader) (SourceInfo, error) {
+	return extractSourceInfo(input, ParseOptions{})
 }
 
 func isParanthesis(char rune) bool {
@@ -59,7 +117,8 @@ func isParanthesis(char rune) bool {
 
 // bufio.SplitFunc that skips both whitespaces, line comments (//...) and block comments (/*...*/)
 // The tokenizer splits not only by whitespace seperated words but also by: parenthesis, curly/square brackets
-func tokenizer(data []byte, atEOF bool) (advance int, token []byte, err error) {
+// In addition to bufio.SplitFunc results it returns the offset in data at which the token starts
+func tokenizer(data []byte, atEOF bool) (advance int, start int, token []byte, err error) {
 	i := 0
 	for i < len(data) {
 		char := rune(data[i])
@@ -67,10 +126,18 @@ func tokenizer(data []byte, atEOF bool) (advance int, token []byte, err error) {
 		switch {
 		// Skip line comments
 		case bytes.HasPrefix(data[i:], []byte("//")):
+			start := i
 			i += 2
 			for i < len(data) && data[i] != '\n' {
 				i++
 			}
+			// Marker comments are emitted as tokens, the whole line is required to read their arguments
+			if i == len(data) && !atEOF && mayBeTestArgsMarker(data[start:i]) {
+				return start, start, nil, nil // Request more data
+			}
+			if _, ok := parseTestArgsMarker(string(data[start:i])); ok {
+				return i, start, data[start:i], nil
+			}
 		// Skip block comments
 		case bytes.HasPrefix(data[i:], []byte("/*")):
 			i += 2
@@ -86,58 +153,636 @@ func tokenizer(data []byte, atEOF bool) (advance int, token []byte, err error) {
 			i++
 
 		case isParanthesis(char):
-			return i + 1, data[i : i+1], nil
+			return i + 1, i, data[i : i+1], nil
 
 		default:
 			start := i
 			for i < len(data) {
 				char := rune(data[i])
 				if unicode.IsSpace(char) || isParanthesis(char) {
-					return i, data[start:i], nil
+					break
 				}
 				i++
 			}
-			return i, data[start:i], nil
+			if isIncludeDirective(string(data[start:i])) {
+				// Include directives are emitted together with the rest of the physical line,
+				// the include path might contain characters splitting the generic tokens, e.g. brackets
+				end, complete := directiveLineEnd(data, i)
+				if !complete && !atEOF {
+					return start, start, nil, nil // Request more data
+				}
+				return end, start, data[start:end], nil
+			}
+			if isConditionDirective(string(data[start:i])) {
+				// Conditional directives are emitted together with their condition up to the end of line
+				end, complete := directiveLineEnd(data, i)
+				if !complete && !atEOF {
+					return start, start, nil, nil // Request more data
+				}
+				return end, start, normalizeDirective(data[start:end]), nil
+			}
+			return i, start, data[start:i], nil
 		}
 	}
 
 	if atEOF {
-		return len(data), nil, io.EOF
+		return len(data), len(data), nil, io.EOF
+	}
+	return i, i, nil, nil
+}
+
+// Tracks the number of the line at which each of the tokens starts
+type lineCounter struct {
+	// Number of newlines in the data consumed by the scanner
+	consumed int
+	// 1-based number of the line at which the last token starts
+	tokenLine int
+}
+
+// bufio.SplitFunc using the tokenizer and counting newlines consumed by the scanner
+func (c *lineCounter) split(data []byte, atEOF bool) (int, []byte, error) {
+	advance, start, token, err := tokenizer(data, atEOF)
+	if token != nil {
+		c.tokenLine = c.consumed + bytes.Count(data[:start], []byte("\n")) + 1
 	}
-	return i, nil, nil
+	c.consumed += bytes.Count(data[:advance], []byte("\n"))
+	return advance, token, err
 }
 
-func extractSourceInfo(input io.Reader) SourceInfo {
+// Checks if the word starts an include directive: `#include` or Objective-C `#import`.
+// The include path might directly follow the directive, e.g. `#include<vector>`
+func isIncludeDirective(word string) bool {
+	for _, directive := range []string{"#include", "#import"} {
+		if rest, ok := strings.CutPrefix(word, directive); ok && (rest == "" || strings.ContainsRune(" \t<\"", rune(rest[0]))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Extracts the include path together with its delimiters, e.g. `<vector>` or `"foo.h"`, from the include directive line.
+// Anything following the closing delimiter is ignored, the same as done by the preprocessor.
+// Returns false if the directive does not use quotes or brackets, e.g. when the path is defined using a macro.
+// The include path is accepted even if it is missing one of delimiters.
+func parseIncludeDirective(line string) (string, bool) {
+	for _, directive := range []string{"#include", "#import"} {
+		if rest, ok := strings.CutPrefix(line, directive); ok {
+			line = rest
+			break
+		}
+	}
+	line = strings.TrimSpace(strings.ReplaceAll(line, "\\\n", ""))
+	if line == "" {
+		return "", false
+	}
+	var closing byte
+	switch line[0] {
+	case '"':
+		closing = '"'
+	case '<':
+		closing = '>'
+	}
+	if end := strings.IndexByte(line[1:], closing); closing != 0 && end >= 0 {
+		return line[:end+2], true
+	}
+	// Malformed directive with missing delimiter, use the first word if it contains any delimiter
+	word := strings.Fields(line)[0]
+	return word, strings.ContainsAny(word, "<>\"")
+}
+
+// Comment marker defining arguments of the test, e.g. `// gazelle:test_args --flag value`
+const testArgsMarker = "gazelle:test_args"
+
+// Parses the line comment containing the test arguments marker, returns its whitespace separated arguments.
+// Returns false if the comment is not a marker.
+func parseTestArgsMarker(comment string) ([]string, bool) {
+	body, ok := strings.CutPrefix(comment, "//")
+	if !ok {
+		return nil, false
+	}
+	args, ok := strings.CutPrefix(strings.TrimLeft(body, " \t"), testArgsMarker)
+	if !ok || (args != "" && !unicode.IsSpace(rune(args[0]))) {
+		return nil, false
+	}
+	return strings.Fields(args), true
+}
+
+// Checks if the possibly incomplete line comment might be a test arguments marker
+func mayBeTestArgsMarker(comment []byte) bool {
+	body := strings.TrimLeft(string(comment[2:]), " \t")
+	return strings.HasPrefix(body, testArgsMarker) || strings.HasPrefix(testArgsMarker, body)
+}
+
+// Checks if the token starts a conditional preprocessor directive followed by a condition
+func isConditionDirective(token string) bool {
+	switch token {
+	case "#if", "#ifdef", "#ifndef", "#elif":
+		return true
+	default:
+		return false
+	}
+}
+
+// Finds the end of the preprocessor directive line starting at given offset, accounting for line continuations using both LF and CRLF line endings.
+// Returns false if data ends before the end of line.
+func directiveLineEnd(data []byte, offset int) (int, bool) {
+	for i := offset; i < len(data); i++ {
+		if data[i] != '\n' {
+			continue
+		}
+		lineEnd := i
+		if lineEnd > 0 && data[lineEnd-1] == '\r' {
+			lineEnd--
+		}
+		if lineEnd == 0 || data[lineEnd-1] != '\\' {
+			return i, true
+		}
+	}
+	return len(data), false
+}
+
+// Splices continued lines of the directive and removes comments, the remaining text of the directive is kept verbatim
+func normalizeDirective(line []byte) []byte {
+	directive := strings.ReplaceAll(string(line), "\\\r\n", "")
+	directive = strings.ReplaceAll(directive, "\\\n", "")
+	if idx := strings.Index(directive, "//"); idx >= 0 {
+		directive = directive[:idx]
+	}
+	for {
+		start := strings.Index(directive, "/*")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(directive[start:], "*/")
+		if end < 0 {
+			directive = directive[:start]
+			break
+		}
+		directive = directive[:start] + " " + directive[start+end+2:]
+	}
+	return []byte(strings.TrimSpace(directive))
+}
+
+// Wraps the scanner allowing to return the token back to the stream if it was read ahead
+type tokenStream struct {
+	scanner *bufio.Scanner
+	lines   *lineCounter
+	pending []positionedToken
+	// Lines of the recently read tokens, used to restore the position of tokens returned to the stream
+	history []int
+	// 1-based number of the line at which the last read token starts
+	line int
+}
+
+type positionedToken struct {
+	text string
+	line int
+}
+
+// Maximal number of lines of recently read tokens kept to restore positions of tokens returned to the stream
+const tokenHistorySize = 16
+
+func (s *tokenStream) next() (string, bool) {
+	var token positionedToken
+	if n := len(s.pending); n > 0 {
+		token = s.pending[n-1]
+		s.pending = s.pending[:n-1]
+	} else if s.scanner.Scan() {
+		token = positionedToken{text: s.scanner.Text(), line: s.lines.tokenLine}
+	} else {
+		return "", false
+	}
+	s.line = token.line
+	s.history = append(s.history, token.line)
+	if len(s.history) > tokenHistorySize {
+		s.history = s.history[1:]
+	}
+	return token.text, true
+}
+
+// Returns the token to the stream, tokens need to be returned in the reversed order of reading them
+func (s *tokenStream) pushBack(token string) {
+	line := s.line
+	if n := len(s.history); n > 0 {
+		line = s.history[n-1]
+		s.history = s.history[:n-1]
+	}
+	s.pending = append(s.pending, positionedToken{text: token, line: line})
+}
+
+// Initial size of the buffer used to read the source, it grows up to maxTokenSize when needed
+const initialBufferSize = 64 * 1024
+
+// Maximal size of a single token, includes and conditional directives are read as a single token spanning the whole line
+const maxTokenSize = 16 * 1024 * 1024
+
+func extractSourceInfo(input io.Reader, options ParseOptions) (SourceInfo, error) {
 	scanner := bufio.NewScanner(input)
-	scanner.Split(tokenizer)
+	scanner.Buffer(make([]byte, 0, initialBufferSize), maxTokenSize)
+	lines := &lineCounter{}
+	scanner.Split(lines.split)
+	tokens := &tokenStream{scanner: scanner, lines: lines}
 
 	sourceInfo := SourceInfo{}
-	lastToken := ""
-	for scanner.Scan() {
-		prevToken := lastToken
-		token := scanner.Text()
-		lastToken = token
-
-		if token == "#include" && scanner.Scan() {
-			include := scanner.Text()
-			if strings.ContainsAny(include, "<>") {
-				sourceInfo.Includes.Bracket = append(sourceInfo.Includes.Bracket, strings.Trim(include, "<>"))
-			} else if strings.Contains(include, "\"") {
-				sourceInfo.Includes.DoubleQuote = append(sourceInfo.Includes.DoubleQuote, strings.Trim(include, "\""))
+	// Tokens preceding the current one, used to detect the signature of entry points
+	var recentTokens []string
+	// Nesting of curly braces, module declarations are allowed only at the top level
+	depth := 0
+	// Name of the module declared in the source, used to resolve imported partitions
+	moduleName := ""
+	conditions := conditionsStack{}
+	for {
+		token, ok := tokens.next()
+		if !ok {
+			break
+		}
+		if args, ok := parseTestArgsMarker(token); ok {
+			sourceInfo.TestArgs = append(sourceInfo.TestArgs, args...)
+			continue
+		}
+
+		precedingTokens := recentTokens
+		recentTokens = append(recentTokens, token)
+		if len(recentTokens) > mainSignatureWindow {
+			recentTokens = recentTokens[1:]
+		}
+
+		switch token {
+		case "{":
+			depth++
+		case "}":
+			if depth > 0 {
+				depth--
+			}
+		}
+
+		// Objective-C #import directive is an include with implicit include guard
+		if isIncludeDirective(token) {
+			if conditions.isDisabled() {
+				// Code inside `#if 0` blocks is never compiled
+				continue
+			}
+			if include, ok := parseIncludeDirective(token); ok {
+				sourceInfo.addInclude(include, tokens.line)
+				if condition := conditions.condition(); condition != "" {
+					sourceInfo.ConditionalIncludes = append(sourceInfo.ConditionalIncludes, ConditionalInclude{
+						Path:      strings.Trim(include, "<>\""),
+						Condition: condition,
+						IsSystem:  strings.ContainsAny(include, "<>"),
+					})
+				}
 			}
 			continue
 		}
 
-		if token == "main" && scanner.Scan() {
+		if directive, condition := splitDirective(token); isConditionDirective(directive) || directive == "#else" || directive == "#endif" {
+			conditions.apply(directive, condition, tokens)
+			continue
+		}
+
+		if depth == 0 && (token == "import" || token == "module" || token == "export") {
+			if parseModuleDeclaration(tokens, token, &sourceInfo, &moduleName) {
+				recentTokens = append(recentTokens, ";")
+			}
+			continue
+		}
+
+		if options.UsedNamespaces && (token == "using" || token == "namespace") {
+			if parseNamespaceUsage(tokens, token, &sourceInfo) {
+				recentTokens = append(recentTokens, ";")
+			}
+			continue
+		}
+
+		if isEntryPoint(token) {
 			// TOOD: better detection of main signature
 			// We should also check for return type aliases and check if input args
-			if scanner.Text() == "(" {
-				if prevToken == "int" {
+			if next, ok := tokens.next(); ok {
+				if next == "(" && hasIntReturnType(precedingTokens) {
 					sourceInfo.HasMain = true
 				}
-				continue
+				tokens.pushBack(next)
 			}
 		}
 	}
-	return sourceInfo
+	if err := scanner.Err(); err != nil {
+		return sourceInfo, fmt.Errorf("failed to parse source at line %d: %w", lines.consumed+1, err)
+	}
+	return sourceInfo, nil
+}
+
+// Splits the directive token into the directive name and its trimmed argument
+func splitDirective(token string) (string, string) {
+	idx := strings.IndexFunc(token, unicode.IsSpace)
+	if idx < 0 {
+		return token, ""
+	}
+	return token[:idx], strings.TrimSpace(token[idx:])
+}
+
+// Block of conditional preprocessor directives, e.g. #if/#elif/#else/#endif
+type conditionFrame struct {
+	// Conditions of previous branches in the block, e.g. the #if condition when inside #else
+	previous []string
+	// Condition of the current branch, empty for #else branch or include guards
+	current string
+	// True when the current branch is disabled using literal `#if 0`
+	disabled bool
+}
+
+type conditionsStack []conditionFrame
+
+// Updates the stack based on conditional directive, unmatched directives are ignored
+func (s *conditionsStack) apply(directive string, condition string, tokens *tokenStream) {
+	switch directive {
+	case "#if":
+		*s = append(*s, conditionFrame{current: condition, disabled: condition == "0"})
+	case "#ifdef":
+		*s = append(*s, conditionFrame{current: "defined(" + condition + ")"})
+	case "#ifndef":
+		if isIncludeGuard(condition, tokens) {
+			*s = append(*s, conditionFrame{})
+		} else {
+			*s = append(*s, conditionFrame{current: "!defined(" + condition + ")"})
+		}
+	case "#elif", "#else":
+		if len(*s) == 0 {
+			return
+		}
+		top := &(*s)[len(*s)-1]
+		if top.current != "" && !top.disabled {
+			// Negation of the disabled branch is always true, it does not guard the following branches
+			top.previous = append(top.previous, top.current)
+		}
+		top.current = condition
+		top.disabled = false
+	case "#endif":
+		if len(*s) > 0 {
+			*s = (*s)[:len(*s)-1]
+		}
+	}
+}
+
+// Checks if the current position is inside a branch disabled using `#if 0`, other conditions are never evaluated
+func (s conditionsStack) isDisabled() bool {
+	for _, frame := range s {
+		if frame.disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the condition guarding the current position, empty if not guarded.
+// Conditions of the branches are kept verbatim, these are only wrapped in parentheses when joined with other conditions
+func (s conditionsStack) condition() string {
+	type term struct {
+		text string
+		// Verbatim conditions of the branches might contain operators with lower precedence than `&&`
+		needsParens bool
+	}
+	terms := []term{}
+	for _, frame := range s {
+		for _, previous := range frame.previous {
+			terms = append(terms, term{text: negateCondition(previous)})
+		}
+		if frame.current != "" {
+			terms = append(terms, term{text: frame.current, needsParens: !isSimpleCondition(frame.current)})
+		}
+	}
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t.text
+		if len(terms) > 1 && t.needsParens {
+			parts[i] = "(" + t.text + ")"
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+func negateCondition(condition string) string {
+	if isSimpleCondition(condition) {
+		if negated, ok := strings.CutPrefix(condition, "!"); ok {
+			return negated
+		}
+		return "!" + condition
+	}
+	return "!(" + condition + ")"
+}
+
+// Checks if the condition is a single operand, optionally negated, e.g. `FOO`, `!defined(FOO)`, that does not need to be wrapped in parentheses
+func isSimpleCondition(condition string) bool {
+	condition = strings.TrimPrefix(condition, "!")
+	if operand, ok := strings.CutPrefix(condition, "defined("); ok {
+		condition = strings.TrimSuffix(operand, ")")
+	}
+	return condition != "" && strings.IndexFunc(condition, func(r rune) bool { return !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) }) < 0
+}
+
+// Checks if `#ifndef X` is followed by `#define X`, typical for include guards that should not be treated as conditions
+func isIncludeGuard(macro string, tokens *tokenStream) bool {
+	directive, ok := tokens.next()
+	if !ok {
+		return false
+	}
+	if directive != "#define" {
+		tokens.pushBack(directive)
+		return false
+	}
+	name, ok := tokens.next()
+	if ok {
+		tokens.pushBack(name)
+	}
+	tokens.pushBack(directive)
+	return ok && name == macro
+}
+
+// Number of tokens preceding the entry point name inspected when looking for its return type,
+// allows for attributes and calling conventions, e.g. `int WINAPI WinMain(` or `[[noreturn]] int main(`
+const mainSignatureWindow = 4
+
+func isEntryPoint(token string) bool {
+	switch token {
+	case "main", "wmain", "WinMain", "wWinMain":
+		return true
+	default:
+		return false
+	}
+}
+
+// Checks if `int` return type is defined within the same declaration as the entry point
+func hasIntReturnType(precedingTokens []string) bool {
+	for i := len(precedingTokens) - 1; i >= 0; i-- {
+		token := precedingTokens[i]
+		switch {
+		case token == "int":
+			return true
+		case strings.HasSuffix(token, ";") || strings.HasSuffix(token, ","):
+			// End of previous statement or declaration
+			return false
+		case token == "{" || token == "}" || token == "(" || token == ")" || token == "=" || token == "return":
+			// Entry point name used outside of its declaration, e.g. called or declared as parameter
+			return false
+		}
+	}
+	return false
+}
+
+// Records the include, together with its location, based on the include path with its delimiters, e.g. `<vector>`
+func (s *SourceInfo) addInclude(include string, line int) {
+	var path string
+	isSystem := strings.ContainsAny(include, "<>")
+	if isSystem {
+		path = strings.Trim(include, "<>")
+		s.Includes.Bracket = append(s.Includes.Bracket, path)
+	} else if strings.Contains(include, "\"") {
+		path = strings.Trim(include, "\"")
+		s.Includes.DoubleQuote = append(s.Includes.DoubleQuote, path)
+	} else {
+		return
+	}
+	s.IncludeLocations = append(s.IncludeLocations, IncludeLocation{Path: path, Line: line, IsSystem: isSystem})
+}
+
+// Parses C++20 module declarations starting with given keyword:
+// `import foo.bar;`, `import :part;`, `export import foo;`, `export module foo.bar;`, `module foo.bar;`
+// Imports of header units, e.g. `import <vector>;`, are recorded as includes.
+// Returns true if declaration was recognized, otherwise all read ahead tokens are returned to the stream.
+func parseModuleDeclaration(tokens *tokenStream, keyword string, sourceInfo *SourceInfo, moduleName *string) bool {
+	readAhead := []string{}
+	next := func() (string, bool) {
+		token, ok := tokens.next()
+		if ok {
+			readAhead = append(readAhead, token)
+		}
+		return token, ok
+	}
+	// Reads name terminated by ';', allowing for whitespace before the terminator
+	readName := func() (string, bool) {
+		token, ok := next()
+		if !ok {
+			return "", false
+		}
+		name, terminated := strings.CutSuffix(token, ";")
+		if !terminated {
+			if terminator, ok := next(); !ok || terminator != ";" {
+				return "", false
+			}
+		}
+		return name, name != ""
+	}
+
+	isExported := false
+	if keyword == "export" {
+		token, ok := next()
+		if !ok || (token != "import" && token != "module") {
+			return restoreTokens(tokens, readAhead)
+		}
+		keyword = token
+		isExported = true
+	}
+
+	name, ok := readName()
+	if !ok {
+		return restoreTokens(tokens, readAhead)
+	}
+	switch {
+	case keyword == "import" && (strings.HasPrefix(name, "<") || strings.HasPrefix(name, "\"")):
+		sourceInfo.addInclude(name, tokens.line)
+	case keyword == "import" && isModuleName(name):
+		if strings.HasPrefix(name, ":") {
+			// Partition of the current module
+			baseModule, _, _ := strings.Cut(*moduleName, ":")
+			name = baseModule + name
+		}
+		sourceInfo.ModuleImports = append(sourceInfo.ModuleImports, name)
+	case keyword == "module" && isModuleName(name) && !strings.HasPrefix(name, ":"):
+		*moduleName = name
+		if isExported {
+			sourceInfo.ModuleExports = append(sourceInfo.ModuleExports, name)
+		}
+	default:
+		return restoreTokens(tokens, readAhead)
+	}
+	return true
+}
+
+// Parses declarations using namespaces starting with given keyword: `using namespace foo::bar;` or `namespace baz = foo::bar;`
+// Returns true if declaration was recognized, otherwise all read ahead tokens are returned to the stream.
+func parseNamespaceUsage(tokens *tokenStream, keyword string, sourceInfo *SourceInfo) bool {
+	readAhead := []string{}
+	next := func() (string, bool) {
+		token, ok := tokens.next()
+		if ok {
+			readAhead = append(readAhead, token)
+		}
+		return token, ok
+	}
+	if keyword == "using" {
+		if token, ok := next(); !ok || token != "namespace" {
+			return restoreTokens(tokens, readAhead)
+		}
+	} else {
+		// Namespace definitions, e.g. `namespace foo {`, are not usages
+		alias, ok := next()
+		if !ok || !isNamespaceName(alias) || strings.Contains(alias, "::") {
+			return restoreTokens(tokens, readAhead)
+		}
+		if token, ok := next(); !ok || token != "=" {
+			return restoreTokens(tokens, readAhead)
+		}
+	}
+
+	token, ok := next()
+	if !ok {
+		return restoreTokens(tokens, readAhead)
+	}
+	name, terminated := strings.CutSuffix(token, ";")
+	if !terminated {
+		if terminator, ok := next(); !ok || terminator != ";" {
+			return restoreTokens(tokens, readAhead)
+		}
+	}
+	name = strings.TrimPrefix(name, "::")
+	if !isNamespaceName(name) {
+		return restoreTokens(tokens, readAhead)
+	}
+	sourceInfo.UsedNamespaces = append(sourceInfo.UsedNamespaces, name)
+	return true
+}
+
+// Checks if name is a sequence of identifiers separated by `::`, e.g. `foo::bar`
+func isNamespaceName(name string) bool {
+	return !strings.Contains(name, ".") && isModuleIdentifier(strings.ReplaceAll(name, "::", "."))
+}
+
+func restoreTokens(tokens *tokenStream, readAhead []string) bool {
+	for i := len(readAhead) - 1; i >= 0; i-- {
+		tokens.pushBack(readAhead[i])
+	}
+	return false
+}
+
+// Checks if name is a valid module name, optionally with a partition, e.g. `foo.bar`, `foo:part` or `:part`
+func isModuleName(name string) bool {
+	module, partition, hasPartition := strings.Cut(name, ":")
+	if hasPartition && !isModuleIdentifier(partition) {
+		return false
+	}
+	return (module == "" && hasPartition) || isModuleIdentifier(module)
+}
+
+// Checks if name is a sequence of identifiers separated by dots
+func isModuleIdentifier(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return false
+		}
+		for i, char := range part {
+			if !(char == '_' || unicode.IsLetter(char) || (i > 0 && unicode.IsDigit(char))) {
+				return false
+			}
+		}
+	}
+	return true
 }
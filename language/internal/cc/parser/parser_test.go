@@ -15,7 +15,10 @@
 package parser
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -49,6 +52,79 @@ func TestParseIncludes(t *testing.T) {
 				DoubleQuote: []string{"stdio.h", "stdlib.h"},
 			},
 		},
+		{
+			// Include paths containing brackets and includes directly followed by code on the same line
+			input: `
+#include "foo[bar].h"
+#include <vector>[[nodiscard]] int f();
+#include<map>
+#include"baz (copy).h" // comment
+#include MACRO_HEADER
+#import <array>{}
+int g() { return 0; }
+#include <string>
+`,
+			expected: Includes{
+				Bracket:     []string{"vector", "map", "array", "string"},
+				DoubleQuote: []string{"foo[bar].h", "baz (copy).h"},
+			},
+		},
+		{
+			// Template implementation file, e.g. '.ipp', using angle brackets in the code
+			input: `
+#include "memory/alloc.h"
+#include <type_traits>
+
+template <typename T, typename = std::enable_if_t<std::is_trivial_v<T>>>
+void Vector<T>::push_back(const T& value) {
+  allocate(sizeof(T));
+}
+`,
+			expected: Includes{
+				Bracket:     []string{"type_traits"},
+				DoubleQuote: []string{"memory/alloc.h"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		result := ParseSource(tc.input).Includes
+		if fmt.Sprintf("%v", result) != fmt.Sprintf("%v", tc.expected) {
+			t.Errorf("For input: %q, expected %+v, but got %+v", tc.input, tc.expected, result)
+		}
+	}
+}
+
+func TestParseImports(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Includes
+	}{
+		// Parses valid source code
+		{
+			input: `
+#import <Foundation/Foundation.h>
+#import "MyClass.h"
+#include <math.h>
+`,
+			expected: Includes{
+				Bracket:     []string{"Foundation/Foundation.h", "math.h"},
+				DoubleQuote: []string{"MyClass.h"},
+			},
+		},
+		{
+			// Accept malformed import
+			input: `
+#import "stdio.h
+#import stdlib.h"
+#import <UIKit/UIKit.h
+#import exception>
+`,
+			expected: Includes{
+				Bracket:     []string{"UIKit/UIKit.h", "exception"},
+				DoubleQuote: []string{"stdio.h", "stdlib.h"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -137,6 +213,34 @@ func TestParseSourceHasMain(t *testing.T) {
 			expected: true,
 			input:    `/* that our main */ int main(int argCount, char** values){return 0;}`,
 		},
+		{
+			expected: true,
+			input:    `[[noreturn]] int main() { std::abort(); }`,
+		},
+		{
+			expected: true,
+			input:    `extern "C" int main(int argc, char** argv) { return 0; }`,
+		},
+		{
+			expected: true,
+			input:    `int WINAPI WinMain(HINSTANCE instance, HINSTANCE prev, LPSTR cmd, int show) { return 0; }`,
+		},
+		{
+			expected: true,
+			input:    `int __cdecl wmain(int argc, wchar_t* argv[]) { return 0; }`,
+		},
+		{
+			expected: false,
+			input:    `int run() { return main(); }`,
+		},
+		{
+			expected: false,
+			input:    `int x; void main() {}`,
+		},
+		{
+			expected: false,
+			input:    `// int WINAPI WinMain(HINSTANCE instance, HINSTANCE prev, LPSTR cmd, int show) { return 0; }`,
+		},
 	}
 
 	for idx, tc := range testCases {
@@ -146,3 +250,466 @@ func TestParseSourceHasMain(t *testing.T) {
 		}
 	}
 }
+
+func TestParseModules(t *testing.T) {
+	testCases := []struct {
+		input           string
+		expectedImports []string
+		expectedExports []string
+		expectedInclude Includes
+	}{
+		{
+			input: `
+export module foo.bar;
+import std;
+import baz.qux;
+export import other;
+`,
+			expectedImports: []string{"std", "baz.qux", "other"},
+			expectedExports: []string{"foo.bar"},
+		},
+		{
+			// Partitions are resolved relative to the declared module
+			input: `
+export module foo:impl;
+import :part;
+import bar:part;
+`,
+			expectedImports: []string{"foo:part", "bar:part"},
+			expectedExports: []string{"foo:impl"},
+		},
+		{
+			// Implementation units do not export the module, whitespace before terminator is allowed
+			input: `
+module;
+#include "legacy.h"
+module foo ;
+import bar ;
+`,
+			expectedImports: []string{"bar"},
+			expectedInclude: Includes{DoubleQuote: []string{"legacy.h"}},
+		},
+		{
+			// Header units are treated as includes
+			input: `
+import <vector>;
+import "local.h";
+`,
+			expectedInclude: Includes{Bracket: []string{"vector"}, DoubleQuote: []string{"local.h"}},
+		},
+		{
+			// import used as identifier or inside function bodies is ignored
+			input: `
+#include <import>
+int import = 0;
+void f() {
+  import foo;
+}
+export int g(int x) { return x; }
+export { int h(); }
+// import commented;
+`,
+			expectedInclude: Includes{Bracket: []string{"import"}},
+		},
+	}
+
+	for idx, tc := range testCases {
+		result := ParseSource(tc.input)
+		if fmt.Sprintf("%v", result.ModuleImports) != fmt.Sprintf("%v", tc.expectedImports) {
+			t.Errorf("For test case %d input: %q, expected imports %+v, but got %+v", idx, tc.input, tc.expectedImports, result.ModuleImports)
+		}
+		if fmt.Sprintf("%v", result.ModuleExports) != fmt.Sprintf("%v", tc.expectedExports) {
+			t.Errorf("For test case %d input: %q, expected exports %+v, but got %+v", idx, tc.input, tc.expectedExports, result.ModuleExports)
+		}
+		if fmt.Sprintf("%v", result.Includes) != fmt.Sprintf("%v", tc.expectedInclude) {
+			t.Errorf("For test case %d input: %q, expected includes %+v, but got %+v", idx, tc.input, tc.expectedInclude, result.Includes)
+		}
+	}
+}
+
+func TestParseConditionalIncludes(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []ConditionalInclude
+	}{
+		{
+			input: `
+#include <always.h>
+#ifdef _WIN32
+#include <windows.h>
+#elif defined(__APPLE__) && !defined(NO_COCOA) // macOS
+#include "cocoa.h"
+#else
+#include <unistd.h>
+#endif
+`,
+			expected: []ConditionalInclude{
+				{Path: "windows.h", Condition: "defined(_WIN32)", IsSystem: true},
+				{Path: "cocoa.h", Condition: "!defined(_WIN32) && (defined(__APPLE__) && !defined(NO_COCOA))", IsSystem: false},
+				{Path: "unistd.h", Condition: "!defined(_WIN32) && !(defined(__APPLE__) && !defined(NO_COCOA))", IsSystem: true},
+			},
+		},
+		{
+			// Nested conditions and line continuations
+			input: `
+#if FEATURE_A \
+    && FEATURE_B
+#ifndef NDEBUG
+#include "debug.h"
+#endif
+#endif
+`,
+			expected: []ConditionalInclude{
+				{Path: "debug.h", Condition: "(FEATURE_A     && FEATURE_B) && !defined(NDEBUG)"},
+			},
+		},
+		{
+			// Line continuations using CRLF line endings
+			input: "#if FEATURE_A \\\r\n&& FEATURE_B\r\n#include \"feature.h\"\r\n#endif\r\n",
+			expected: []ConditionalInclude{
+				{Path: "feature.h", Condition: "FEATURE_A && FEATURE_B"},
+			},
+		},
+		{
+			// Conditions without whitespace are wrapped in parentheses when joined
+			input: `
+#if A||B
+#include "a_or_b.h"
+#elif !C&&D
+#include "not_c_and_d.h"
+#else
+#ifdef E
+#include "e.h"
+#endif
+#endif
+`,
+			expected: []ConditionalInclude{
+				{Path: "a_or_b.h", Condition: "A||B"},
+				{Path: "not_c_and_d.h", Condition: "!(A||B) && (!C&&D)"},
+				{Path: "e.h", Condition: "!(A||B) && !(!C&&D) && defined(E)"},
+			},
+		},
+		{
+			// Include guards are not treated as conditions
+			input: `
+#ifndef MY_HEADER_H
+#define MY_HEADER_H
+#include <vector>
+#endif
+`,
+			expected: nil,
+		},
+		{
+			// Unmatched directives are ignored
+			input: `
+#endif
+#else
+#include <stdio.h>
+#if FEATURE
+#include <feature.h>
+`,
+			expected: []ConditionalInclude{
+				{Path: "feature.h", Condition: "FEATURE", IsSystem: true},
+			},
+		},
+		{
+			// Branches following disabled `#if 0` are not guarded by it
+			input: `
+#if 0
+#include <never.h>
+#elif FEATURE
+#include <feature.h>
+#else
+#include <fallback.h>
+#endif
+`,
+			expected: []ConditionalInclude{
+				{Path: "feature.h", Condition: "FEATURE", IsSystem: true},
+				{Path: "fallback.h", Condition: "!FEATURE", IsSystem: true},
+			},
+		},
+	}
+
+	for idx, tc := range testCases {
+		result := ParseSource(tc.input).ConditionalIncludes
+		if fmt.Sprintf("%v", result) != fmt.Sprintf("%v", tc.expected) {
+			t.Errorf("For test case %d input: %q, expected %+v, but got %+v", idx, tc.input, tc.expected, result)
+		}
+	}
+
+	// Conditional includes are still reported as regular includes
+	includes := ParseSource(testCases[0].input).Includes
+	expectedIncludes := Includes{Bracket: []string{"always.h", "windows.h", "unistd.h"}, DoubleQuote: []string{"cocoa.h"}}
+	if fmt.Sprintf("%v", includes) != fmt.Sprintf("%v", expectedIncludes) {
+		t.Errorf("Expected %+v, but got %+v", expectedIncludes, includes)
+	}
+}
+
+func TestParseDisabledIncludes(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Includes
+	}{
+		{
+			input: `
+#include <before.h>
+#if 0
+#include <disabled.h>
+#include "disabled.h"
+#endif
+#include <after.h>
+`,
+			expected: Includes{Bracket: []string{"before.h", "after.h"}},
+		},
+		{
+			// Nested blocks inside disabled block
+			input: `
+#if 0
+#ifdef _WIN32
+#include <windows.h>
+#endif
+#include <disabled.h>
+#else
+#include "enabled.h"
+#endif
+`,
+			expected: Includes{DoubleQuote: []string{"enabled.h"}},
+		},
+		{
+			// Only literal '#if 0' is recognized, other conditions are never evaluated
+			input: `
+#if 0 || FEATURE
+#include <feature.h>
+#endif
+#if false
+#include <false.h>
+#endif
+#ifdef DISABLED
+#include <disabled.h>
+#endif
+`,
+			expected: Includes{Bracket: []string{"feature.h", "false.h", "disabled.h"}},
+		},
+	}
+
+	for idx, tc := range testCases {
+		result := ParseSource(tc.input).Includes
+		if fmt.Sprintf("%v", result) != fmt.Sprintf("%v", tc.expected) {
+			t.Errorf("For test case %d input: %q, expected %+v, but got %+v", idx, tc.input, tc.expected, result)
+		}
+	}
+}
+
+func TestParseIncludeLocations(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []IncludeLocation
+	}{
+		{
+			input: `// Copyright
+#include <stdio.h>
+
+/* Block comment
+   spanning multiple lines */
+#include "foo.h" // trailing comment
+#if defined(FOO) && \
+    defined(BAR)
+#include <bar.h>
+#endif
+`,
+			expected: []IncludeLocation{
+				{Path: "stdio.h", Line: 2, IsSystem: true},
+				{Path: "foo.h", Line: 6, IsSystem: false},
+				{Path: "bar.h", Line: 9, IsSystem: true},
+			},
+		},
+		{
+			// Windows line endings and header units
+			input: "#include \"a.h\"\r\n\r\nexport module foo;\r\nimport <vector>;\r\n#import <Foundation/Foundation.h>\r\n",
+			expected: []IncludeLocation{
+				{Path: "a.h", Line: 1, IsSystem: false},
+				{Path: "vector", Line: 4, IsSystem: true},
+				{Path: "Foundation/Foundation.h", Line: 5, IsSystem: true},
+			},
+		},
+		{
+			// Tokens returned to the stream when reading ahead keep their positions
+			input: `
+import
+#include "after_import.h"
+int main() {}
+#include "after_main.h"
+`,
+			expected: []IncludeLocation{
+				{Path: "after_import.h", Line: 3, IsSystem: false},
+				{Path: "after_main.h", Line: 5, IsSystem: false},
+			},
+		},
+	}
+
+	for idx, tc := range testCases {
+		result := ParseSource(tc.input).IncludeLocations
+		if fmt.Sprintf("%v", result) != fmt.Sprintf("%v", tc.expected) {
+			t.Errorf("For test case %d input: %q, expected %+v, but got %+v", idx, tc.input, tc.expected, result)
+		}
+	}
+}
+
+func TestParseTestArgs(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    `int main() {}`,
+			expected: nil,
+		},
+		{
+			input: `// gazelle:test_args --data_dir testdata
+//gazelle:test_args	--verbose
+#include "gtest/gtest.h"
+int main() {} // gazelle:test_args --last`,
+			expected: []string{"--data_dir", "testdata", "--verbose", "--last"},
+		},
+		{
+			// Only line comments starting with the marker are recognized
+			input: `// See gazelle:test_args --ignored
+// gazelle:test_argsx --ignored
+/* gazelle:test_args --ignored */
+// gazelle:test_args
+int main() {}`,
+			expected: nil,
+		},
+	}
+
+	for idx, tc := range testCases {
+		result := ParseSource(tc.input)
+		if fmt.Sprintf("%v", result.TestArgs) != fmt.Sprintf("%v", tc.expected) {
+			t.Errorf("For test case %d input: %q, expected %+v, but got %+v", idx, tc.input, tc.expected, result.TestArgs)
+		}
+		if !result.HasMain {
+			t.Errorf("For test case %d input: %q, expected main function to be detected", idx, tc.input)
+		}
+	}
+}
+
+func TestParseUsedNamespaces(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    `using namespace std;`,
+			expected: []string{"std"},
+		},
+		{
+			input: `using namespace foo::bar;
+using namespace ::baz ;
+namespace fs = std::filesystem;`,
+			expected: []string{"foo::bar", "baz", "std::filesystem"},
+		},
+		{
+			// Namespace definitions and using declarations of single names are not namespace usages
+			input: `namespace foo {
+namespace {
+using std::string;
+using Alias = foo::Type;
+}
+}
+namespace foo::bar { int x; }`,
+			expected: nil,
+		},
+		{
+			// Usages inside functions still refer to the namespace, main function is detected after them
+			input: `int main() {
+  using namespace foo::detail;
+  return run();
+}`,
+			expected: []string{"foo::detail"},
+		},
+	}
+
+	options := ParseOptions{UsedNamespaces: true}
+	for idx, tc := range testCases {
+		result := ParseSourceWithOptions(tc.input, options)
+		if fmt.Sprintf("%v", result.UsedNamespaces) != fmt.Sprintf("%v", tc.expected) {
+			t.Errorf("For test case %d input: %q, expected %+v, but got %+v", idx, tc.input, tc.expected, result.UsedNamespaces)
+		}
+		// Namespaces are extracted only when requested
+		if result := ParseSource(tc.input); result.UsedNamespaces != nil {
+			t.Errorf("For test case %d input: %q, expected no namespaces without options, but got %+v", idx, tc.input, result.UsedNamespaces)
+		}
+	}
+	if !ParseSourceWithOptions(`using namespace std;
+int main() {}`, options).HasMain {
+		t.Errorf("Expected main function to be detected after using namespace declaration")
+	}
+}
+
+func TestParseSourceReaderLongLines(t *testing.T) {
+	// Include directive is read together with the remaining part of the line, exceeding the default bufio.Scanner buffer
+	longComment := "/*" + strings.Repeat("x", 128*1024) + "*/"
+	input := "#include <first.h>\n#include \"long.h\" " + longComment + "\n#include <last.h>\n"
+	result, err := ParseSourceReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := Includes{Bracket: []string{"first.h", "last.h"}, DoubleQuote: []string{"long.h"}}
+	if fmt.Sprintf("%v", result.Includes) != fmt.Sprintf("%v", expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, result.Includes)
+	}
+
+	// Tokens exceeding the maximal size are reported instead of silently truncating the results
+	tooLongComment := "/*" + strings.Repeat("x", maxTokenSize) + "*/"
+	input = "#include <first.h>\n#include \"long.h\" " + tooLongComment + "\n#include <last.h>\n"
+	result, err = ParseSourceReader(strings.NewReader(input))
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("Expected %v error, but got %v", bufio.ErrTooLong, err)
+	}
+	expected = Includes{Bracket: []string{"first.h"}}
+	if fmt.Sprintf("%v", result.Includes) != fmt.Sprintf("%v", expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, result.Includes)
+	}
+}
+
+func FuzzParseSource(f *testing.F) {
+	// Seed corpus derived from inputs of the test cases above
+	for _, seed := range []string{
+		"#include <stdio.h>\n#include \"myheader.h\"\n#include <math.h>\n",
+		"#include \"stdio.h\n#include stdlib.h\"\n#include <math.h\n#include exception>\n",
+		"#include \"foo[bar].h\"\n#include <vector>[[nodiscard]] int f();\n#include<map>\n",
+		"#include MACRO_HEADER\n#import <Foundation/Foundation.h>\n#import \"view.h\"\n",
+		"#if defined(_WIN32)\n#include <windows.h>\n#elif __linux__\n#include <unistd.h>\n#else\n#include \"posix.h\"\n#endif\n",
+		"#if 0\n#include \"disabled.h\"\n#endif\n#ifndef FOO_H\n#define FOO_H\n#include \"foo_impl.h\"\n#endif\n",
+		"#include \\\n  \"continued.h\"\n/* #include \"commented.h\" */\n// #include \"line_comment.h\"\n",
+		"export module foo.bar;\nimport :part;\nexport import baz;\nimport <vector>;\n",
+		"int main(int argc, char** argv) { return 0; }\n",
+		"// gazelle:test_args --data_dir testdata\n#include <gtest/gtest.h>\n",
+		"using namespace foo::bar;\nnamespace fs = std::filesystem;\n",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		result := ParseSource(string(data))
+
+		// Includes serialized back to the source are parsed the same way
+		var serialized strings.Builder
+		expected := []IncludeLocation{}
+		for _, include := range result.IncludeLocations {
+			if include.Path == "" || strings.ContainsAny(include.Path, "<>\"\\\r\n") {
+				// Such paths cannot be represented in a well-formed include directive
+				continue
+			}
+			if include.IsSystem {
+				fmt.Fprintf(&serialized, "#include <%s>\n", include.Path)
+			} else {
+				fmt.Fprintf(&serialized, "#include \"%s\"\n", include.Path)
+			}
+			expected = append(expected, IncludeLocation{Path: include.Path, Line: len(expected) + 1, IsSystem: include.IsSystem})
+		}
+		reparsed := ParseSource(serialized.String())
+		if fmt.Sprintf("%+v", reparsed.IncludeLocations) != fmt.Sprintf("%+v", expected) {
+			t.Errorf("Re-parsed includes differ, expected %+v, but got %+v for serialized source %q", expected, reparsed.IncludeLocations, serialized.String())
+		}
+	})
+}